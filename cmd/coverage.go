@@ -0,0 +1,74 @@
+package cmd
+
+import (
+	"io"
+	"sort"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+	"github.com/jtleniger/go-nexrad-geojson/nexrad"
+)
+
+// convertCoverage writes the coverage ring of each elevation for each
+// product, as a file per elevation or, with --combined, per product.
+func convertCoverage(filename string, base string, ar2 *archive2.Archive2, opts []nexrad.Options, extension string) error {
+	if err := nexrad.CheckElevations(ar2, opts[0].Elevations); err != nil {
+		return err
+	}
+
+	for i := range opts {
+		coverages, err := geo.ArchiveCoverage(ar2, &opts[i])
+
+		if err != nil {
+			return err
+		}
+
+		elevations := make([]int, 0, len(coverages))
+
+		for elevation := range coverages {
+			elevations = append(elevations, elevation)
+		}
+
+		sort.Ints(elevations)
+
+		if len(elevations) == 0 {
+			continue
+		}
+
+		volumeTime := ar2.VolumeTime()
+
+		if combined {
+			all := make([]*geo.Coverage, len(elevations))
+
+			for j, elevation := range elevations {
+				all[j] = coverages[elevation]
+			}
+
+			metadata := geojson.NewMetadata(ar2.ElevationScans[elevations[0]])
+			metadata.ElevationAngle = nil
+			metadata.VolumeTime = &volumeTime
+
+			writeCoverage(outputName(base, filename, ar2, opts[i].Product, elevations, extension), all, metadata)
+			continue
+		}
+
+		for _, elevation := range elevations {
+			name := outputName(base, filename, ar2, opts[i].Product, []int{elevation}, extension)
+			metadata := geojson.NewMetadata(ar2.ElevationScans[elevation])
+			metadata.VolumeTime = &volumeTime
+
+			writeCoverage(name, []*geo.Coverage{coverages[elevation]}, metadata)
+		}
+	}
+
+	return nil
+}
+
+func writeCoverage(filename string, coverages []*geo.Coverage, metadata *geojson.Metadata) {
+	writeOutput(filename, func(w io.Writer) {
+		writeJSON(w, func(w io.Writer) {
+			geojson.WriteCoverage(w, coverages, metadata, precision)
+		})
+	})
+}
@@ -0,0 +1,129 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+	"github.com/jtleniger/go-nexrad-geojson/nexrad"
+)
+
+// dryRunOutput is the estimated feature count of each elevation of the
+// outputs for a product, or for merged products.
+type dryRunOutput struct {
+	product  string
+	features []int
+}
+
+// reportDryRun writes the projection origin of an archive and a table of the
+// files a conversion would write, with their elevations and estimated feature
+// counts. Bins are counted before projection, so no PROJ transforms run, and
+// before --bbox, so counts with a bounding box are upper bounds.
+func reportDryRun(w io.Writer, filename string, ar2 *archive2.Archive2, base string, opts []nexrad.Options, extension string) error {
+	elevations := opts[0].Elevations
+
+	if err := nexrad.CheckElevations(ar2, elevations); err != nil {
+		return err
+	}
+
+	lat, lon, err := ar2.RadarLocation()
+
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "%v: origin %v, %v\n", filename, lat, lon)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	if opts[0].BoundingBox != nil {
+		fmt.Fprintln(tw, "FILE\tELEVATIONS\tFEATURES (AT MOST)")
+	} else {
+		fmt.Fprintln(tw, "FILE\tELEVATIONS\tFEATURES")
+	}
+
+	groups := make([]dryRunOutput, len(opts))
+
+	for i := range opts {
+		groups[i] = dryRunOutput{product: opts[i].Product, features: make([]int, len(elevations))}
+
+		for j, elevation := range elevations {
+			if format == "COVERAGE" {
+				groups[i].features[j] = 1
+				continue
+			}
+
+			bins, err := geo.RelativeScanBins(ar2.ElevationScans[elevation], &opts[i])
+
+			if err != nil {
+				return fmt.Errorf("elevation %d: %s", elevation, err)
+			}
+
+			collection := geojson.NewFeatureCollection(opts[i].Product, bins)
+			collection.BucketSize = bucketSize
+
+			if maxFeatures > 0 {
+				collection.Truncate(maxFeatures)
+			}
+
+			groups[i].features[j] = collection.FeatureCount()
+
+			// a mask outlines every bin as one feature
+			if format == "MASK" && groups[i].features[j] > 0 {
+				groups[i].features[j] = 1
+			}
+		}
+	}
+
+	// a GeoPackage holds every product and elevation
+	if mergeProducts || format == "GPKG" {
+		merged := dryRunOutput{product: mergedProductName(opts), features: make([]int, len(elevations))}
+
+		for _, group := range groups {
+			for j, features := range group.features {
+				merged.features[j] += features
+			}
+		}
+
+		groups = []dryRunOutput{merged}
+	}
+
+	for _, group := range groups {
+		if combined || format == "GPKG" {
+			total := 0
+
+			for _, features := range group.features {
+				total += features
+			}
+
+			name := outputName(base, filename, ar2, group.product, elevations, extension)
+
+			if format == "GPKG" {
+				name = geoPackageName(base, filename, ar2, opts, elevations, extension)
+			}
+
+			fmt.Fprintf(tw, "%v\t%v\t%d\n", name, joinElevations(elevations), total)
+			continue
+		}
+
+		for j, elevation := range elevations {
+			fmt.Fprintf(tw, "%v\t%d\t%d\n", outputName(base, filename, ar2, group.product, []int{elevation}, extension), elevation, group.features[j])
+		}
+	}
+
+	return tw.Flush()
+}
+
+func joinElevations(elevations []int) string {
+	s := make([]string, len(elevations))
+
+	for i, elevation := range elevations {
+		s[i] = fmt.Sprint(elevation)
+	}
+
+	return strings.Join(s, ",")
+}
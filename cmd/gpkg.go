@@ -0,0 +1,106 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+	"github.com/jtleniger/go-nexrad-geojson/internal/gpkg"
+	"github.com/jtleniger/go-nexrad-geojson/nexrad"
+	"github.com/sirupsen/logrus"
+)
+
+// writeGeoPackage writes the collections of every product to one GeoPackage
+// with a layer per product and elevation, e.g. ref_1, or with --combined per
+// product, named after the products as mergedProductName.
+func writeGeoPackage(filename string, base string, ar2 *archive2.Archive2, opts []nexrad.Options, products map[string]map[int]*geojson.FeatureCollection, extension string) {
+	layers := make([]*gpkg.Layer, 0)
+	// the collection and elevations of each layer, for the manifest
+	collections := make([]*geojson.FeatureCollection, 0)
+	layerElevations := make([][]int, 0)
+
+	seen := make(map[int]bool)
+	elevations := make([]int, 0)
+
+	for _, o := range opts {
+		productElevations := make([]int, 0, len(products[o.Product]))
+
+		for elevation := range products[o.Product] {
+			productElevations = append(productElevations, elevation)
+
+			if !seen[elevation] {
+				seen[elevation] = true
+				elevations = append(elevations, elevation)
+			}
+		}
+
+		sort.Ints(productElevations)
+
+		if combined {
+			if len(productElevations) == 0 {
+				continue
+			}
+
+			collection := combineElevations(products[o.Product])
+
+			layers = append(layers, &gpkg.Layer{
+				Name:        strings.ToLower(o.Product),
+				Description: documentName(collection),
+				Bins:        collection.Bins,
+				Props:       &collection.Properties,
+			})
+			collections = append(collections, collection)
+			layerElevations = append(layerElevations, productElevations)
+			continue
+		}
+
+		for _, elevation := range productElevations {
+			collection := products[o.Product][elevation]
+
+			layers = append(layers, &gpkg.Layer{
+				Name:        geoPackageLayer(o.Product, elevation),
+				Description: fmt.Sprintf("%v elevation %d", documentName(collection), elevation),
+				Bins:        collection.Bins,
+				Props:       &collection.Properties,
+			})
+			collections = append(collections, collection)
+			layerElevations = append(layerElevations, []int{elevation})
+		}
+	}
+
+	if len(layers) == 0 {
+		return
+	}
+
+	sort.Ints(elevations)
+
+	name := geoPackageName(base, filename, ar2, opts, elevations, extension)
+
+	writeOutput(name, func(w io.Writer) {
+		if err := gpkg.Write(w, layers); err != nil {
+			logrus.Fatal(err)
+		}
+	})
+
+	for i, collection := range collections {
+		reportWritten(filename, name, layerElevations[i], collection)
+	}
+}
+
+// geoPackageLayer names the layer of a product's elevation.
+func geoPackageLayer(product string, elevation int) string {
+	return fmt.Sprintf("%v_%d", strings.ToLower(product), elevation)
+}
+
+// geoPackageName returns the filename of the GeoPackage holding every product
+// and elevation.
+func geoPackageName(base string, input string, ar2 *archive2.Archive2, opts []nexrad.Options, elevations []int, extension string) string {
+	if nameTemplate != "" {
+		return outputName(base, input, ar2, mergedProductName(opts), elevations, extension)
+	}
+
+	return outputFilename(base, mergedProductName(opts), extension)
+}
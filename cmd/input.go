@@ -0,0 +1,298 @@
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/nexrad"
+	"github.com/sirupsen/logrus"
+)
+
+// s3URL converts an s3://bucket/key path to the public HTTPS URL of the
+// object, e.g. for the NOAA noaa-nexrad-level2 bucket.
+func s3URL(path string) (string, error) {
+	parts := strings.SplitN(strings.TrimPrefix(path, "s3://"), "/", 2)
+
+	if len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+		return "", fmt.Errorf("invalid S3 path %v, expected s3://bucket/key", path)
+	}
+
+	return fmt.Sprintf("https://%s.s3.amazonaws.com/%s", parts[0], parts[1]), nil
+}
+
+// stdinName is the input argument reading the archive from stdin
+const stdinName = "-"
+
+// inputName returns the name of an input without its directory and
+// extension, to name its outputs, or "stdin" for an archive read from stdin.
+func inputName(filename string) string {
+	if filename == stdinName {
+		return "stdin"
+	}
+
+	return strings.TrimSuffix(filepath.Base(filename), filepath.Ext(filename))
+}
+
+// expandDirectories replaces each directory among the inputs with the
+// archives under it, found by their volume headers whatever their extension,
+// in lexical order. Other files under it are skipped. It also returns the
+// path of each archive found relative to its directory, without the
+// extension, to name its outputs after.
+func expandDirectories(inputs []string) ([]string, map[string]string, error) {
+	expanded := make([]string, 0, len(inputs))
+	walked := make(map[string]string)
+
+	for _, input := range inputs {
+		if input == stdinName || isRemote(input) {
+			expanded = append(expanded, input)
+			continue
+		}
+
+		info, err := os.Stat(input)
+
+		if err != nil || !info.IsDir() {
+			// missing files fail when read, as other inputs
+			expanded = append(expanded, input)
+			continue
+		}
+
+		found := 0
+
+		err = filepath.WalkDir(input, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+
+			if !d.Type().IsRegular() || !isArchiveFile(path) {
+				return nil
+			}
+
+			rel, err := filepath.Rel(input, path)
+
+			if err != nil {
+				return err
+			}
+
+			walked[path] = strings.TrimSuffix(rel, filepath.Ext(rel))
+			expanded = append(expanded, path)
+			found++
+
+			return nil
+		})
+
+		if err != nil {
+			return nil, nil, err
+		}
+
+		logrus.Infof("%v: found %d archives", input, found)
+	}
+
+	return expanded, walked, nil
+}
+
+// isArchiveFile returns true if a file starts with an Archive II volume
+// header, compressed or not.
+func isArchiveFile(filename string) bool {
+	f, err := os.Open(filename)
+
+	if err != nil {
+		return false
+	}
+
+	defer f.Close()
+
+	header, err := archive2.ReadVolumeHeader(f)
+
+	return err == nil && header.IsArchive2()
+}
+
+// isBatch returns true if the inputs are converted as a batch, each output
+// named after its input, as with several inputs or any directory, whose
+// archives are walked.
+func isBatch(inputs []string, walked map[string]string) bool {
+	return len(inputs) > 1 || len(walked) > 0
+}
+
+func isRemote(filename string) bool {
+	return strings.HasPrefix(filename, "s3://") || strings.HasPrefix(filename, "http://") || strings.HasPrefix(filename, "https://")
+}
+
+// fetchTimeout bounds a download, including reading the body, so a stalled
+// server fails its input rather than hanging the batch. A volume of tens of
+// megabytes needs well under this on any working link.
+const fetchTimeout = 5 * time.Minute
+
+var httpClient = &http.Client{Timeout: fetchTimeout}
+
+// fetch downloads a remote archive into memory, as extraction needs to seek.
+func fetch(url string) (*bytes.Reader, error) {
+	return fetchRange(url, 0)
+}
+
+// fetchRange downloads the first n bytes of a remote archive, or all of it if
+// n is 0.
+func fetchRange(url string, n int64) (*bytes.Reader, error) {
+	if strings.HasPrefix(url, "s3://") {
+		var err error
+		url, err = s3URL(url)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	logrus.Infof("fetching %v", url)
+
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if n > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=0-%d", n-1))
+	}
+
+	resp, err := httpClient.Do(req)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusPartialContent {
+		return nil, fmt.Errorf("failed to fetch %v: %v", url, resp.Status)
+	}
+
+	var body io.Reader = resp.Body
+
+	// servers may ignore the range and send the whole file
+	if n > 0 {
+		body = io.LimitReader(body, n)
+	}
+
+	data, err := io.ReadAll(body)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return bytes.NewReader(data), nil
+}
+
+// headerPrefix is enough of a file to decompress its volume header from
+const headerPrefix = 64 * 1024
+
+// volumeTime returns the time of the volume in a local or remote archive from
+// its volume header, without reading the rest of the file.
+func volumeTime(filename string) (time.Time, error) {
+	var r io.Reader
+
+	if isRemote(filename) {
+		prefix, err := fetchRange(filename, headerPrefix)
+
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		r = prefix
+	} else {
+		f, err := os.Open(filename)
+
+		if err != nil {
+			return time.Time{}, err
+		}
+
+		defer f.Close()
+
+		r = f
+	}
+
+	header, err := archive2.ReadVolumeHeader(r)
+
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	return header.Date(), nil
+}
+
+// closestVolume returns the file whose volume was scanned closest to t, the
+// first of any tied. Files that can't be read are skipped.
+func closestVolume(filenames []string, t time.Time) (string, time.Time, error) {
+	best := ""
+	var bestTime time.Time
+	var bestDiff time.Duration
+
+	for _, filename := range filenames {
+		scanned, err := volumeTime(filename)
+
+		if err != nil {
+			logrus.Errorf("%v: %s", filename, err)
+			continue
+		}
+
+		diff := scanned.Sub(t)
+
+		if diff < 0 {
+			diff = -diff
+		}
+
+		if best == "" || diff < bestDiff {
+			best, bestTime, bestDiff = filename, scanned, diff
+		}
+	}
+
+	if best == "" {
+		return "", time.Time{}, errors.New("no readable volumes")
+	}
+
+	return best, bestTime, nil
+}
+
+// readArchive reads a local or remote archive, or one from stdin, returning an
+// error if it cannot be read or is corrupt, so one bad file doesn't stop a
+// batch.
+func readArchive(filename string) (*archive2.Archive2, error) {
+	if filename == stdinName {
+		// stdin may be a pipe, so it's read into memory to seek like a
+		// fetched archive
+		data, err := io.ReadAll(os.Stdin)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return nexrad.Read(bytes.NewReader(data))
+	}
+
+	if isRemote(filename) {
+		r, err := fetch(filename)
+
+		if err != nil {
+			return nil, err
+		}
+
+		return nexrad.Read(r)
+	}
+
+	f, err := os.Open(filename)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer f.Close()
+
+	return nexrad.Read(f)
+}
@@ -0,0 +1,64 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
+	"github.com/sirupsen/logrus"
+)
+
+// legendColormap returns the colormap coloring a product's output, the
+// --colormap if set, or the default of png, kml and kmz output. Other formats
+// are only colored by --colormap, so nil is returned for them.
+func legendColormap(product string, colormaps map[string]*colormap.Colormap) *colormap.Colormap {
+	if cm, ok := colormaps[product]; ok {
+		return cm
+	}
+
+	switch format {
+	case "PNG":
+		return colormap.Reflectivity
+	case "KML", "KMZ":
+		if product == "VEL" || product == "SRV" {
+			return colormap.Velocity
+		}
+
+		return colormap.Reflectivity
+	}
+
+	return nil
+}
+
+// writeLegends writes the legend of each product's colormap to --legend, a
+// colorbar image if it ends in .png and JSON otherwise. With several products
+// the product is added to each legend's name, e.g. legend-VEL.json.
+func writeLegends(names []string, colormaps map[string]*colormap.Colormap) {
+	for _, name := range names {
+		filename := legend
+
+		if len(names) > 1 {
+			ext := filepath.Ext(legend)
+			filename = fmt.Sprintf("%v-%v%v", strings.TrimSuffix(legend, ext), name, ext)
+		}
+
+		cm := legendColormap(name, colormaps)
+
+		writeOutput(filename, func(w io.Writer) {
+			var err error
+
+			if strings.EqualFold(filepath.Ext(filename), ".png") {
+				err = colormap.WritePNG(w, cm)
+			} else {
+				err = colormap.NewLegend(cm, name, archive2.ProductUnit(name)).WriteJSON(w)
+			}
+
+			if err != nil {
+				logrus.Fatal(err)
+			}
+		})
+	}
+}
@@ -0,0 +1,42 @@
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+)
+
+// moments lists the products stored as data moments, in listing order.
+var moments = []string{"REF", "VEL", "SW", "ZDR", "PHI", "RHO", "CFP"}
+
+// listElevations writes a table of each elevation in the archive with its
+// angle, number of radials, and the moments present in its first radial.
+func listElevations(w io.Writer, ar2 *archive2.Archive2) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "ELEVATION\tANGLE\tRADIALS\tMOMENTS")
+
+	for _, elevation := range ar2.Elevations() {
+		scan := ar2.ElevationScans[elevation]
+
+		if len(scan) == 0 {
+			fmt.Fprintf(tw, "%d\t-\t0\t-\n", elevation)
+			continue
+		}
+
+		present := make([]string, 0, len(moments))
+
+		for _, moment := range moments {
+			if _, err := scan[0].DataMomentForProduct(moment); err == nil {
+				present = append(present, moment)
+			}
+		}
+
+		fmt.Fprintf(tw, "%d\t%.2f\t%d\t%s\n", elevation, scan[0].Header.ElevationAngle, len(scan), strings.Join(present, ","))
+	}
+
+	return tw.Flush()
+}
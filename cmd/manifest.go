@@ -0,0 +1,114 @@
+package cmd
+
+import (
+	"encoding/json"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+)
+
+// manifestEntry summarizes one output file for --manifest.
+type manifestEntry struct {
+	File       string    `json:"file"`
+	Input      string    `json:"input"`
+	Station    string    `json:"station,omitempty"`
+	Time       time.Time `json:"time"`
+	VCP        int       `json:"vcp,omitempty"`
+	Product    string    `json:"product"`
+	Elevations []int     `json:"elevations"`
+	Features   int       `json:"features"`
+	// Minimum and Maximum are omitted when the file has no features
+	Minimum *float32 `json:"minimum,omitempty"`
+	Maximum *float32 `json:"maximum,omitempty"`
+}
+
+// manifest collects an entry for every output file, safe for use from the
+// writer goroutines.
+type manifest struct {
+	mu      sync.Mutex
+	Entries []manifestEntry
+}
+
+func (m *manifest) add(input string, file string, elevations []int, collection *geojson.FeatureCollection) {
+	entry := newManifestEntry(input, file, elevations, collection)
+	entry.Features = collection.FeatureCount()
+
+	// a mask outlines every bin as one feature
+	if format == "MASK" && entry.Features > 0 {
+		entry.Features = 1
+	}
+
+	entry.addValues(collection.Bins)
+	m.addEntry(entry)
+}
+
+// newManifestEntry returns the entry of a file holding the collection's
+// product, without features.
+func newManifestEntry(input string, file string, elevations []int, collection *geojson.FeatureCollection) manifestEntry {
+	entry := manifestEntry{
+		File:       file,
+		Input:      input,
+		Product:    collection.Properties.Product,
+		Elevations: elevations,
+	}
+
+	if collection.Metadata != nil {
+		entry.Station = collection.Metadata.Station
+		entry.Time = collection.Metadata.Time
+		entry.VCP = collection.Metadata.VCP
+	}
+
+	return entry
+}
+
+// addValues widens the entry's minimum and maximum to the values of the bins,
+// except those flagged as range folded or below threshold, so they are
+// omitted unless some bin has a value.
+func (e *manifestEntry) addValues(bins []*geo.Bin) {
+	for _, bin := range bins {
+		if bin.Flag() != "" {
+			continue
+		}
+
+		if e.Minimum == nil {
+			min, max := bin.Value, bin.Value
+			e.Minimum, e.Maximum = &min, &max
+			continue
+		}
+
+		if bin.Value < *e.Minimum {
+			*e.Minimum = bin.Value
+		}
+
+		if bin.Value > *e.Maximum {
+			*e.Maximum = bin.Value
+		}
+	}
+}
+
+// addEntry records the entry of a written file.
+func (m *manifest) addEntry(entry manifestEntry) {
+	m.mu.Lock()
+	m.Entries = append(m.Entries, entry)
+	m.mu.Unlock()
+}
+
+// write writes the entries, ordered by file name, as a JSON array.
+func (m *manifest) write(filename string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	sort.Slice(m.Entries, func(i, j int) bool { return m.Entries[i].File < m.Entries[j].File })
+
+	data, err := json.MarshalIndent(m.Entries, "", "  ")
+
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(filename, append(data, '\n'), 0644)
+}
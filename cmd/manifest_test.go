@@ -0,0 +1,112 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// manifestCollection returns a REF collection of bins with the values, the
+// last bin flagged as range folded, as --keep-folded keeps it.
+func manifestCollection(values ...float32) *geojson.FeatureCollection {
+	bins := make([]*geo.Bin, len(values))
+
+	for i, value := range values {
+		bins[i] = geo.NewBin(proj.NewCoord(0, 0, 0, 0), proj.NewCoord(1, 0, 0, 0), proj.NewCoord(0, 1, 0, 0), proj.NewCoord(1, 1, 0, 0), value)
+	}
+
+	if len(bins) > 0 {
+		bins[len(bins)-1].GateFlag = archive2.GateFolded
+	}
+
+	return geojson.NewFeatureCollection("REF", bins)
+}
+
+func TestManifest(t *testing.T) {
+	defer func(f string) { format = f }(format)
+
+	format = "GEOJSON"
+
+	var m manifest
+
+	// the folded bin's value, 99, is outside the range of the others
+	m.add("b.ar2", "b-REF-1.json", []int{1}, manifestCollection(10, -5, 30, 99))
+	m.add("a.ar2", "a-REF-1.json", []int{1}, manifestCollection(99))
+
+	format = "MASK"
+
+	m.add("c.ar2", "c-REF-1.json", []int{1}, manifestCollection(10, 20, 99))
+
+	format = "GEOJSON"
+
+	// writers finishing at once, as the writer goroutines do
+	var wg sync.WaitGroup
+
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+
+		go func(i int) {
+			defer wg.Done()
+
+			m.add("d.ar2", fmt.Sprintf("d-REF-%02d.json", i), []int{i}, manifestCollection(1, 99))
+		}(i)
+	}
+
+	wg.Wait()
+
+	filename := filepath.Join(t.TempDir(), "manifest.json")
+
+	if err := m.write(filename); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filename)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []manifestEntry
+
+	if err := json.Unmarshal(data, &entries); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 23 {
+		t.Fatalf("expected 23 entries, got %d", len(entries))
+	}
+
+	for i := 1; i < len(entries); i++ {
+		if entries[i-1].File >= entries[i].File {
+			t.Errorf("expected entries sorted by file, got %v before %v", entries[i-1].File, entries[i].File)
+		}
+	}
+
+	// a single flagged bin has no range
+	if a := entries[0]; a.File != "a-REF-1.json" || a.Features != 1 || a.Minimum != nil || a.Maximum != nil {
+		t.Errorf("expected a feature without a range, got %+v", a)
+	}
+
+	if b := entries[1]; b.Features != 4 || b.Minimum == nil || *b.Minimum != -5 || b.Maximum == nil || *b.Maximum != 30 {
+		t.Errorf("expected 4 features from -5 to 30, got %+v", b)
+	}
+
+	// a mask is one feature of every bin, over their range
+	if c := entries[2]; c.Features != 1 || c.Minimum == nil || *c.Minimum != 10 || c.Maximum == nil || *c.Maximum != 20 {
+		t.Errorf("expected 1 mask feature from 10 to 20, got %+v", c)
+	}
+
+	for _, d := range entries[3:] {
+		if d.Input != "d.ar2" || d.Features != 2 || d.Minimum == nil || *d.Minimum != 1 || *d.Maximum != 1 {
+			t.Errorf("expected 2 features of 1, got %+v", d)
+		}
+	}
+}
@@ -0,0 +1,97 @@
+package cmd
+
+import (
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+	"github.com/jtleniger/go-nexrad-geojson/nexrad"
+)
+
+// writeMergedProducts writes the collections of every product to one file
+// per elevation, or with --combined one file, named after the products as
+// mergedProductName, e.g. radar-REF_VEL_RHO-1.json.
+func writeMergedProducts(filename string, base string, ar2 *archive2.Archive2, opts []nexrad.Options, products map[string]map[int]*geojson.FeatureCollection, extension string) {
+	names := make([]string, len(opts))
+
+	for i, o := range opts {
+		names[i] = o.Product
+	}
+
+	product := mergedProductName(opts)
+
+	// each elevation with any of the products
+	seen := make(map[int]bool)
+	elevations := make([]int, 0)
+
+	for _, name := range names {
+		for elevation := range products[name] {
+			if !seen[elevation] {
+				seen[elevation] = true
+				elevations = append(elevations, elevation)
+			}
+		}
+	}
+
+	sort.Ints(elevations)
+
+	if len(elevations) == 0 {
+		return
+	}
+
+	if combined {
+		collections := make([]*geojson.FeatureCollection, 0, len(names))
+
+		for _, name := range names {
+			if len(products[name]) > 0 {
+				collections = append(collections, combineElevations(products[name]))
+			}
+		}
+
+		writeMerged(filename, outputName(base, filename, ar2, product, elevations, extension), elevations, geojson.Merge(collections))
+		return
+	}
+
+	for _, elevation := range elevations {
+		collections := make([]*geojson.FeatureCollection, 0, len(names))
+
+		for _, name := range names {
+			if collection, ok := products[name][elevation]; ok {
+				collections = append(collections, collection)
+			}
+		}
+
+		writeMerged(filename, outputName(base, filename, ar2, product, []int{elevation}, extension), []int{elevation}, geojson.Merge(collections))
+	}
+}
+
+// writeMerged writes a merged collection, recording each of its products for
+// the manifest.
+func writeMerged(input string, name string, elevations []int, merged *geojson.MergedCollection) {
+	writeOutput(name, func(w io.Writer) {
+		if format == "GEOJSONSEQ" {
+			merged.WriteSeq(w)
+			return
+		}
+
+		writeJSON(w, merged.Write)
+	})
+
+	for _, collection := range merged.Collections {
+		reportWritten(input, name, elevations, collection)
+	}
+}
+
+// mergedProductName names the outputs of merged products after the products
+// joined by underscores.
+func mergedProductName(opts []nexrad.Options) string {
+	names := make([]string, len(opts))
+
+	for i, o := range opts {
+		names[i] = o.Product
+	}
+
+	return strings.Join(names, "_")
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+)
+
+// templateTimeFormat formats {time} in a --name-template, compact and free of
+// characters that are invalid in filenames
+const templateTimeFormat = "20060102T150405Z"
+
+var placeholder = regexp.MustCompile(`\{[^{}]*\}`)
+
+var validPlaceholders = map[string]bool{
+	"{station}":   true,
+	"{time}":      true,
+	"{product}":   true,
+	"{elev}":      true,
+	"{elevAngle}": true,
+	"{input}":     true,
+}
+
+// checkNameTemplate returns an error if the template has an unknown
+// placeholder, or would give the same name to the files of several
+// elevations or products of an input: without {elev} when writing a file per
+// elevation, as angles repeat within a volume, or without {product} when
+// writing a file per product.
+func checkNameTemplate(template string, elevationFiles int, productFiles int) error {
+	for _, p := range placeholder.FindAllString(template, -1) {
+		if !validPlaceholders[p] {
+			return fmt.Errorf("unknown placeholder %v in name template, expected one of {station}, {time}, {product}, {elev}, {elevAngle}, {input}", p)
+		}
+	}
+
+	if elevationFiles > 1 && !strings.Contains(template, "{elev}") {
+		return errors.New("name template must include {elev} to name the file of each elevation, or set --combined")
+	}
+
+	if productFiles > 1 && !strings.Contains(template, "{product}") {
+		return errors.New("name template must include {product} to name the file of each product")
+	}
+
+	return nil
+}
+
+// outputName returns the filename of the output for a product and its
+// elevations, a single elevation unless --combined is set. Without a
+// --name-template, the product and elevation are appended to the base output
+// name, or "-" is returned when writing to stdout.
+func outputName(base string, input string, ar2 *archive2.Archive2, product string, elevations []int, extension string) string {
+	if nameTemplate == "" {
+		if combined {
+			return outputFilename(base, product, extension)
+		}
+
+		return outputFilename(base, fmt.Sprintf("%v-%v", product, elevations[0]), extension)
+	}
+
+	first := ar2.ElevationScans[elevations[0]]
+	last := ar2.ElevationScans[elevations[len(elevations)-1]]
+
+	station := ""
+
+	if metadata := geojson.NewMetadata(first); metadata != nil {
+		station = metadata.Station
+	}
+
+	elev := fmt.Sprint(elevations[0])
+	elevAngle := scanAngle(first)
+
+	if len(elevations) > 1 {
+		elev = fmt.Sprintf("%v-%v", elevations[0], elevations[len(elevations)-1])
+		elevAngle = fmt.Sprintf("%v-%v", elevAngle, scanAngle(last))
+	}
+
+	name := strings.NewReplacer(
+		"{station}", station,
+		"{time}", ar2.VolumeTime().UTC().Format(templateTimeFormat),
+		"{product}", product,
+		"{elev}", elev,
+		"{elevAngle}", elevAngle,
+		"{input}", inputName(input),
+	).Replace(nameTemplate)
+
+	// --output only names the directory when a template names the files
+	dir := ""
+
+	if strings.HasSuffix(output, string(filepath.Separator)) {
+		dir = output
+	}
+
+	return filepath.Join(dir, name) + "." + extension
+}
+
+// scanAngle formats the elevation angle of a scan to a tenth of a degree.
+func scanAngle(scan []*archive2.Message31) string {
+	if len(scan) == 0 {
+		return ""
+	}
+
+	return fmt.Sprintf("%.1f", scan[0].Header.ElevationAngle)
+}
@@ -0,0 +1,92 @@
+package cmd
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+)
+
+func TestCheckNameTemplate(t *testing.T) {
+	tests := []struct {
+		template       string
+		elevationFiles int
+		productFiles   int
+		valid          bool
+	}{
+		{"{station}_{time}", 1, 1, true},
+		{"{station}_{time}_{elev}", 3, 1, true},
+		{"{station}_{time}_{elevAngle}", 3, 1, false},
+		{"{station}_{time}", 3, 1, false},
+		{"{station}_{product}", 1, 2, true},
+		{"{station}_{elev}", 1, 2, false},
+		{"{product}_{elev}", 3, 2, true},
+		{"{product}", 3, 2, false},
+		{"{station}_{level}", 1, 1, false},
+		{"{station", 1, 1, true},
+	}
+
+	for _, test := range tests {
+		err := checkNameTemplate(test.template, test.elevationFiles, test.productFiles)
+
+		if test.valid && err != nil {
+			t.Errorf("%v with %d elevations and %d products: unexpected error %v", test.template, test.elevationFiles, test.productFiles, err)
+		}
+
+		if !test.valid && err == nil {
+			t.Errorf("%v with %d elevations and %d products: expected an error", test.template, test.elevationFiles, test.productFiles)
+		}
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	// 2023-06-15 21:30:00 UTC, as a modified Julian date where 1970/1/1 = 1
+	radial := func(elevationAngle float32) *archive2.Message31 {
+		return &archive2.Message31{
+			Header: archive2.Message31Header{
+				RadarIdentifier: [4]byte{'K', 'F', 'T', 'G'},
+				CollectionDate:  19524,
+				CollectionTime:  (21*60 + 30) * 60 * 1000,
+				ElevationAngle:  elevationAngle,
+			},
+		}
+	}
+
+	ar2 := &archive2.Archive2{
+		ElevationScans: map[int][]*archive2.Message31{
+			1: {radial(0.48)},
+			2: {radial(0.48)},
+			3: {radial(1.45)},
+		},
+	}
+
+	tests := []struct {
+		template   string
+		output     string
+		combined   bool
+		elevations []int
+		expected   string
+	}{
+		{"", "out", false, []int{2}, "out-REF-2.json"},
+		{"", "out", true, []int{1, 2, 3}, "out-REF.json"},
+		{"", "-", false, []int{1}, "-"},
+		{"{station}_{time}_{product}_{elev}", "out", false, []int{2}, "KFTG_20230615T213000Z_REF_2.json"},
+		{"{station}_{elevAngle}", "out", false, []int{3}, "KFTG_1.5.json"},
+		{"{elev}_{elevAngle}", "out", true, []int{1, 2, 3}, "1-3_0.5-1.5.json"},
+		{"{input}_{elev}", "dir" + string(filepath.Separator), false, []int{1}, filepath.Join("dir", "KFTG20230615_213000_V06_1.json")},
+	}
+
+	defer func(template string, o string, c bool) {
+		nameTemplate, output, combined = template, o, c
+	}(nameTemplate, output, combined)
+
+	for _, test := range tests {
+		nameTemplate, output, combined = test.template, test.output, test.combined
+
+		name := outputName(test.output, "archives/KFTG20230615_213000_V06.ar2", ar2, "REF", test.elevations, "json")
+
+		if name != test.expected {
+			t.Errorf("%q to %v, elevations %v: expected %v, got %v", test.template, test.output, test.elevations, test.expected, name)
+		}
+	}
+}
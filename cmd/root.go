@@ -1,36 +1,114 @@
 package cmd
 
 import (
+	"bufio"
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
+	"github.com/jtleniger/go-nexrad-geojson/internal/csv"
 	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
 	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+	"github.com/jtleniger/go-nexrad-geojson/internal/kml"
+	"github.com/jtleniger/go-nexrad-geojson/internal/mvt"
+	"github.com/jtleniger/go-nexrad-geojson/internal/raster"
+	"github.com/jtleniger/go-nexrad-geojson/internal/shapefile"
+	"github.com/jtleniger/go-nexrad-geojson/nexrad"
 	"github.com/sirupsen/logrus"
 	"github.com/spf13/cobra"
+	"github.com/twpayne/go-proj/v10"
 )
 
 var (
 	logLevel       string
 	minimum        float32
 	maximum        float32
+	valueScale     float32
+	valueOffset    float32
 	product        string
 	elevationRange string
 	output         string
+	bbox           string
+	format         string
+	combined       bool
+	mergeToMax     bool
+	maxRange       float32
+	firstGate      int
+	lastGate       int
+	maxFeatures    int
+	resolution     float64
+	colormapName   string
+	quiet          bool
+	dealias        bool
+	bucketSize     float32
+	precision      int
+	height         bool
+	list           bool
+	zoom           int
+	thin           int
+	sortAzimuth    bool
+	dropAnomalous  bool
+	noCurvature    bool
+	sampleRate     float64
+	threads        int
+	progress       bool
+	center         string
+	manifestName   string
+	geometry       string
+	radarLocation  string
+	pretty         bool
+	products       string
+	keepFolded     bool
+	keepBelow      bool
+	at             string
+	dryRun         bool
+	nameTemplate   string
+	crs            string
+	projPipeline   string
+	mergeProducts  bool
+	simplify       float64
+	legend         string
+	failOnEmpty    bool
+	angle          float32
+	raw            bool
+	overwrite      bool
+	includeStation bool
+	includeAngle   bool
+	threeD         bool
+	stormMotion    string
 )
 
-var validProducts = map[string]interface{}{"REF": "", "VEL": "", "SW": "", "ZDR": "", "PHI": "", "RHO": ""}
+// location overrides the radar location of every archive, if set
+var location *geo.Center
+
+// byAngle is set when --angle selects each input's elevation in place of
+// --elevations
+var byAngle bool
+
+// outputs records every file written, for --manifest
+var outputs manifest
+
+var validProducts = map[string]interface{}{"REF": "", "VEL": "", "SW": "", "ZDR": "", "PHI": "", "KDP": "", "RHO": "", "REFGRAD": "", "SRV": ""}
+
+var validFormats = map[string]string{"GEOJSON": "json", "GEOJSONSEQ": "geojsons", "TOPOJSON": "topojson", "MVT": "mvt", "SHAPEFILE": "shp", "KML": "kml", "KMZ": "kmz", "COVERAGE": "json", "GEOTIFF": "tif", "PNG": "png", "GPKG": "gpkg", "CSV": "csv", "MASK": "json"}
 
 var rootCmd = &cobra.Command{
-	Use:   "go-nexrad-json [NEXRAD archive file]",
+	Use:   "go-nexrad-json [NEXRAD archive files, s3://bucket/key, URLs, or - for stdin]",
 	Short: "Create GeoJSON from NEXRAD data.",
 	Run:   run,
-	Args:  cobra.ExactArgs(1),
+	Args:  cobra.MinimumNArgs(1),
 }
 
 func Execute() {
@@ -43,23 +121,188 @@ func Execute() {
 func init() {
 	rootCmd.CompletionOptions.DisableDefaultCmd = true
 	rootCmd.PersistentFlags().StringVarP(&logLevel, "log-level", "l", "warn", "set log level: debug, info, warn, error")
-	rootCmd.PersistentFlags().Float32Var(&minimum, "minimum", 0, "minimum product value to include in the output")
-	rootCmd.PersistentFlags().Float32Var(&maximum, "maximum", 0, "maximum prodct value to include in the output")
-	rootCmd.PersistentFlags().StringVarP(&product, "product", "p", "REF", "product to output, one of REF, VEL, SW, ZDR, PHI, RHO, CFP")
-	rootCmd.PersistentFlags().StringVarP(&elevationRange, "elevations", "e", "1", "elevation or range of elevations, can be N, or N-M (inclusive)")
-	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "radar", "base filename for output; elevation, product, and extension are appended")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "only log errors, overrides --log-level")
+	rootCmd.PersistentFlags().BoolVar(&progress, "progress", false, "report elevations completed and features written to stderr")
+	rootCmd.PersistentFlags().StringVar(&at, "at", "", "only convert the input file whose volume was scanned closest to this RFC 3339 time, e.g. 2023-06-15T21:30:00Z")
+	rootCmd.PersistentFlags().StringVar(&nameTemplate, "name-template", "", "name outputs from a template of {station}, {time}, {product}, {elev}, {elevAngle} and {input} (the input file's name), with {elev} for a file per elevation and {product} per product; the extension is appended, and --output sets the directory if it ends with /")
+	rootCmd.PersistentFlags().BoolVar(&dryRun, "dry-run", false, "print the projection origin and the files that would be written with estimated feature counts, without writing anything or projecting bins")
+	rootCmd.PersistentFlags().BoolVar(&list, "list-elevations", false, "print each elevation's angle, radial count, and moments, then exit without writing output")
+	rootCmd.PersistentFlags().Float32Var(&minimum, "minimum", 0, "minimum product value to include in the output; unbounded if unset, except RHO defaults to 0.8")
+	rootCmd.PersistentFlags().Float32Var(&maximum, "maximum", 0, "maximum product value to include in the output, unbounded if unset")
+	rootCmd.PersistentFlags().Float32Var(&valueScale, "value-scale", 1, "multiply each value by this after --minimum and --maximum, e.g. for a client expecting another range; the unit is then \"scaled\", with value_scale and value_offset in the collection properties")
+	rootCmd.PersistentFlags().Float32Var(&valueOffset, "value-offset", 0, "add this to each value after --value-scale")
+	rootCmd.PersistentFlags().StringVarP(&product, "product", "p", "REF", "product to output, one of REF, VEL, SW, ZDR, PHI, KDP, RHO, REFGRAD, SRV (storm relative velocity, requires --storm-motion)")
+	rootCmd.PersistentFlags().BoolVar(&mergeProducts, "merge-products", false, "with --products, write every product to one FeatureCollection per elevation, tagging each feature with a product property; geojson and geojsonseq only")
+	rootCmd.PersistentFlags().StringVar(&products, "products", "", "comma separated products to output in a single pass, e.g. REF,VEL,RHO, writing a file per product; replaces --product")
+	rootCmd.PersistentFlags().StringVarP(&elevationRange, "elevations", "e", "1", "elevations to convert, comma separated elevations N and inclusive ranges N-M, e.g. 1,3,5-7; available elevations depend on the VCP")
+	rootCmd.PersistentFlags().Float32Var(&angle, "angle", 0, "convert the elevation scan whose angle is closest to these degrees, e.g. 0.5, instead of an elevation number, as numbers differ between VCPs; replaces --elevations")
+	rootCmd.PersistentFlags().BoolVar(&raw, "raw", false, "write the unscaled data level of each gate, with the moment's scale and offset in the collection properties, rather than the physical value; --minimum and --maximum are then levels")
+	rootCmd.PersistentFlags().BoolVar(&dealias, "dealias", false, "unfold aliased velocities along each radial, VEL and SRV only")
+	rootCmd.PersistentFlags().StringVar(&stormMotion, "storm-motion", "", "storm motion subtracted from velocities for SRV, as direction,speed: the direction it moves from in degrees and its speed in m/s, e.g. 240,15")
+	rootCmd.PersistentFlags().BoolVar(&keepFolded, "keep-folded", false, "keep range folded gates as features with a null value and \"flag\": \"range_folded\", rather than dropping them; geojson, geojsonseq and topojson only")
+	rootCmd.PersistentFlags().BoolVar(&keepBelow, "keep-below-threshold", false, "keep gates below the signal threshold as features with a null value and \"flag\": \"below_threshold\", as --keep-folded")
+	rootCmd.PersistentFlags().Float32Var(&maxRange, "max-range", 0, "maximum ground range from the radar in km to include in the output")
+	rootCmd.PersistentFlags().IntVar(&firstGate, "first-gate", 0, "index of the first gate of each radial to include, counted from 0 at the radar, e.g. to drop near-radar clutter")
+	rootCmd.PersistentFlags().IntVar(&lastGate, "last-gate", 0, "index of the last gate of each radial to include, unbounded if unset")
+	rootCmd.PersistentFlags().IntVar(&maxFeatures, "max-features", 0, "maximum number of bins written per product and elevation, or per product with --combined, the first in radial order, marking the output as truncated, unbounded if unset; counted in bins, not features, so --bucket writes the buckets of the bins kept, and georeferencing stops once every product has them")
+	rootCmd.PersistentFlags().IntVar(&threads, "threads", runtime.NumCPU(), "maximum number of output files written at once, each holding its collection in memory, and of goroutines georeferencing the elevations, split across the radials of each")
+	rootCmd.PersistentFlags().IntVar(&thin, "thin", 1, "keep every Nth radial and gate, widening bins to preserve coverage")
+	rootCmd.PersistentFlags().BoolVar(&noCurvature, "no-curvature", false, "place bins along a straight beam over a flat earth exactly where earlier versions did, rather than modeling the earth's curvature and refraction")
+	rootCmd.PersistentFlags().BoolVar(&sortAzimuth, "sort-azimuth", false, "order each scan's radials by azimuth before converting, rather than as recorded")
+	rootCmd.PersistentFlags().BoolVar(&dropAnomalous, "drop-anomalous-azimuths", false, "skip radials whose azimuth is corrupt, far out of place among the radials recorded before and after them, logging how many were dropped")
+	rootCmd.PersistentFlags().Float64Var(&sampleRate, "sample", 1, "keep a random fraction of bins for previews, e.g. 0.05 for 5%, the same subset on every run")
+	rootCmd.PersistentFlags().StringVar(&bbox, "bbox", "", "only include bins within minLon,minLat,maxLon,maxLat")
+	rootCmd.PersistentFlags().StringVar(&center, "center", "", "write coordinates in meters on the plane tangent at lat,lon instead of longitude and latitude, giving several radars a shared frame")
+	rootCmd.PersistentFlags().StringVar(&radarLocation, "radar-location", "", "radar lat,lon, overriding the recorded location; required for legacy (Message 1) archives, which don't record it")
+	rootCmd.PersistentFlags().StringVarP(&format, "format", "f", "geojson", "output format, one of geojson, geojsonseq (newline delimited, RFC 8142, written as the radials are georeferenced, in memory with --bucket, --merge-products, --merge-elevations-to-max or --fail-on-empty), topojson, mvt (directory of vector tiles), shapefile, gpkg (one GeoPackage with a layer per product and elevation), kml, kmz, csv (bin centers, values, elevation angle, azimuth and range), geotiff, png, coverage (a GeoJSON polygon of each elevation's farthest range), mask (a GeoJSON MultiPolygon of the kept bins dissolved, with holes where data is missing)")
+	rootCmd.PersistentFlags().StringVar(&crs, "crs", "", "write coordinates in this CRS, e.g. EPSG:3857 or a PROJ string, instead of WGS84 longitude and latitude")
+	rootCmd.PersistentFlags().StringVar(&projPipeline, "proj-pipeline", "", "transform bins from meters on the radar's tangent plane with this PROJ pipeline, {lat} and {lon} replaced by the radar's location, instead of to longitude and latitude")
+	rootCmd.PersistentFlags().StringVar(&geometry, "geometry", "polygon", "feature geometry for geojson and geojsonseq output, polygon or point (bin centers)")
+	rootCmd.PersistentFlags().BoolVar(&pretty, "pretty", false, "indent geojson, topojson and coverage output for reading, rather than the default compact form")
+	rootCmd.PersistentFlags().StringVar(&colormapName, "colormap", "", "add fill and stroke colors to features and color PNG output, one of reflectivity, velocity, grayscale")
+	rootCmd.PersistentFlags().StringVar(&legend, "legend", "", "write the value to color breaks and labels of the colormap to this JSON file, or a colorbar image if it ends in .png; with several products, the product is added to each name")
+	rootCmd.PersistentFlags().IntVar(&precision, "precision", geo.DefaultPrecision, "number of decimals written for coordinates")
+	rootCmd.PersistentFlags().BoolVar(&includeAngle, "include-angle", false, "include the elevation angle in degrees of each bin's radial, as --combined does, in single elevation output")
+	rootCmd.PersistentFlags().BoolVar(&threeD, "3d", false, "write the beam center height above radar level in meters as the z of each position, for 3D clients; positions are 2D otherwise; geojson and geojsonseq only")
+	rootCmd.PersistentFlags().BoolVar(&height, "height", false, "include the beam center height above radar level in meters for each bin; kml and kmz draw bins at it above sea level")
+	rootCmd.PersistentFlags().Float64Var(&simplify, "simplify", 0, "with --bucket, merge bins along each radial and simplify the outlines by Douglas-Peucker at this tolerance, in degrees, or meters with --center or a projected --crs")
+	rootCmd.PersistentFlags().Float32Var(&bucketSize, "bucket", 0, "group bins into one MultiPolygon feature per range of this many product units, e.g. 5 for 5 dBZ buckets")
+	rootCmd.PersistentFlags().IntVar(&zoom, "zoom", 8, "zoom level of vector tiles for the mvt format")
+	rootCmd.PersistentFlags().Float64Var(&resolution, "resolution", 0.01, "cell size in degrees for raster formats")
+	rootCmd.PersistentFlags().BoolVar(&combined, "combined", false, "write all elevations to a single file, tagging each feature with its elevation")
+	rootCmd.PersistentFlags().BoolVar(&mergeToMax, "merge-elevations-to-max", false, "write a single composite of the elevations, the largest value of any elevation in each --resolution cell, e.g. composite reflectivity; implies --combined")
+	rootCmd.PersistentFlags().BoolVar(&failOnEmpty, "fail-on-empty", false, "fail an input file, writing nothing for it, if any elevation's collection has no features, rather than only warning")
+	rootCmd.PersistentFlags().StringVar(&manifestName, "manifest", "", "write a JSON summary of each output file's station, time, VCP, product, elevations, feature count and value range to this file")
+	rootCmd.PersistentFlags().BoolVar(&overwrite, "overwrite", false, "replace existing output files, which are otherwise an error")
+	rootCmd.PersistentFlags().BoolVar(&includeStation, "include-station", false, "add a Point feature of the radar, and its position to the metadata as radar_location; geojson and geojsonseq only")
+	rootCmd.PersistentFlags().StringVarP(&output, "output", "o", "radar", "base filename for output; elevation, product, and extension are appended. Use - for stdout. With several input files, each file's name is also appended, or end with / to name outputs after the input files in that directory")
+}
+
+// elevationPattern matches an elevation, N, or an inclusive range, N-M
+var elevationPattern = regexp.MustCompile(`^(\d\d?)(-(\d\d?))?$`)
+
+// parseElevations parses comma separated elevations and ranges, e.g. 1,3,5-7,
+// into sorted elevation numbers, each once.
+func parseElevations(s string) ([]int, error) {
+	seen := make(map[int]bool)
+	elevations := make([]int, 0)
+
+	for _, part := range strings.Split(s, ",") {
+		match := elevationPattern.FindStringSubmatch(strings.TrimSpace(part))
+
+		if match == nil {
+			return nil, fmt.Errorf("expected N or N-M, got %q", part)
+		}
+
+		start, _ := strconv.Atoi(match[1])
+		stop := start
+
+		if match[3] != "" {
+			stop, _ = strconv.Atoi(match[3])
+
+			if start > stop {
+				return nil, fmt.Errorf("range %v ends before it starts", part)
+			}
+		}
+
+		for i := start; i <= stop; i++ {
+			if !seen[i] {
+				seen[i] = true
+				elevations = append(elevations, i)
+			}
+		}
+	}
+
+	sort.Ints(elevations)
+
+	return elevations, nil
+}
+
+func parseBoundingBox(s string) (*geo.BoundingBox, error) {
+	parts := strings.Split(s, ",")
+
+	if len(parts) != 4 {
+		return nil, fmt.Errorf("expected 4 comma separated values, got %d", len(parts))
+	}
+
+	values := make([]float64, 4)
+
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(strings.TrimSpace(part), 64)
+
+		if err != nil {
+			return nil, err
+		}
+
+		values[i] = v
+	}
+
+	bb := &geo.BoundingBox{
+		MinLon: values[0],
+		MinLat: values[1],
+		MaxLon: values[2],
+		MaxLat: values[3],
+	}
+
+	if bb.MinLon >= bb.MaxLon || bb.MinLat >= bb.MaxLat {
+		return nil, fmt.Errorf("minimums must be less than maximums")
+	}
+
+	return bb, nil
 }
 
-func readArchive(filename string) *archive2.Archive2 {
-	f, err := os.Open(filename)
+// parseStormMotion parses a storm motion of direction,speed, the direction in
+// degrees it moves from and its speed in m/s.
+func parseStormMotion(s string) (*geo.StormMotion, error) {
+	parts := strings.Split(s, ",")
+
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected direction,speed, got %d values", len(parts))
+	}
+
+	direction, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 32)
 
 	if err != nil {
-		logrus.Fatal(err)
+		return nil, err
+	}
+
+	speed, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 32)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if direction < 0 || direction > 360 || speed < 0 {
+		return nil, fmt.Errorf("direction or speed out of range")
+	}
+
+	return &geo.StormMotion{Direction: float32(direction), Speed: float32(speed)}, nil
+}
+
+func parseCenter(s string) (*geo.Center, error) {
+	parts := strings.Split(s, ",")
+
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("expected lat,lon, got %d values", len(parts))
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 32)
+
+	if err != nil {
+		return nil, err
 	}
 
-	defer f.Close()
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 32)
 
-	return archive2.Extract(f)
+	if err != nil {
+		return nil, err
+	}
+
+	if lat < -90 || lat > 90 || lon < -180 || lon > 180 {
+		return nil, fmt.Errorf("latitude or longitude out of range")
+	}
+
+	return &geo.Center{Lat: float32(lat), Lon: float32(lon)}, nil
 }
 
 func run(cmd *cobra.Command, args []string) {
@@ -69,9 +312,91 @@ func run(cmd *cobra.Command, args []string) {
 		logrus.Fatalf("failed to parse level: %s", err)
 	}
 
+	if quiet {
+		lvl = logrus.ErrorLevel
+	}
+
+	// diagnostics always go to stderr, leaving stdout for output
+	logrus.SetOutput(os.Stderr)
 	logrus.SetLevel(lvl)
 
-	opts := geo.RadarToJSONOptions{}
+	handleInterrupts()
+
+	stdinArgs := 0
+
+	for _, filename := range args {
+		if filename == stdinName {
+			stdinArgs++
+		}
+	}
+
+	if stdinArgs > 1 {
+		logrus.Fatalf("stdin can only be read once")
+	}
+
+	args, walked, err := expandDirectories(args)
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if len(args) == 0 {
+		logrus.Fatalf("no archives found")
+	}
+
+	if at != "" {
+		if stdinArgs > 0 {
+			logrus.Fatalf("--at cannot select from stdin")
+		}
+
+		t, err := time.Parse(time.RFC3339, at)
+
+		if err != nil {
+			logrus.Fatalf("invalid time %v: %s", at, err)
+		}
+
+		filename, scanned, err := closestVolume(args, t)
+
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		logrus.Infof("selected %v, scanned at %v", filename, scanned.Format(time.RFC3339))
+		args = []string{filename}
+
+		// the selected volume is named as a single input
+		walked = nil
+	}
+
+	if list {
+		failed := 0
+
+		for _, filename := range args {
+			if isBatch(args, walked) {
+				fmt.Printf("%v\n", filename)
+			}
+
+			ar2, err := readArchive(filename)
+
+			if err != nil {
+				logrus.Errorf("%v: %s", filename, err)
+				failed++
+				continue
+			}
+
+			if err := listElevations(os.Stdout, ar2); err != nil {
+				logrus.Fatal(err)
+			}
+		}
+
+		if failed > 0 {
+			logrus.Fatalf("%d of %d files could not be read", failed, len(args))
+		}
+
+		return
+	}
+
+	opts := nexrad.Options{}
 
 	if cmd.PersistentFlags().Changed("minimum") {
 		opts.Minimum = &minimum
@@ -81,68 +406,1098 @@ func run(cmd *cobra.Command, args []string) {
 		opts.Maximum = &maximum
 	}
 
-	product = strings.ToUpper(product)
+	if cmd.PersistentFlags().Changed("max-range") {
+		opts.MaxRange = &maxRange
+	}
 
-	if _, ok := validProducts[product]; !ok {
-		logrus.Fatalf("invalid product %v", product)
+	if firstGate < 0 {
+		logrus.Fatalf("invalid first gate %v", firstGate)
 	}
 
-	opts.Product = product
+	opts.FirstGate = firstGate
 
-	elevationRegex, _ := regexp.Compile(`^(\d\d?|(\d\d?\-\d\d?))$`)
+	if cmd.PersistentFlags().Changed("last-gate") {
+		if lastGate < firstGate {
+			logrus.Fatalf("invalid last gate %v, expected at least the first gate %v", lastGate, firstGate)
+		}
+
+		opts.LastGate = &lastGate
+	}
 
-	if !elevationRegex.Match([]byte(elevationRange)) {
-		logrus.Fatalf("invalid elevations %v", elevationRange)
+	if maxFeatures < 0 {
+		logrus.Fatalf("invalid max features %v", maxFeatures)
 	}
 
-	elevations := strings.Split(elevationRange, "-")
+	opts.MaxFeatures = maxFeatures
 
-	if len(elevations) == 1 {
-		elevation, _ := strconv.Atoi(elevations[0])
-		opts.Elevations = []int{elevation}
-	} else {
-		start, _ := strconv.Atoi(elevations[0])
-		stop, _ := strconv.Atoi(elevations[1])
+	names := []string{product}
 
-		if start >= stop {
-			logrus.Fatalf("invalid elevations %v", elevationRange)
+	if cmd.PersistentFlags().Changed("products") {
+		if cmd.PersistentFlags().Changed("product") {
+			logrus.Fatalf("--product and --products cannot be combined")
 		}
 
-		opts.Elevations = make([]int, 0)
+		names = strings.Split(products, ",")
+	}
+
+	seen := make(map[string]bool, len(names))
 
-		for i := start; i <= stop; i++ {
-			opts.Elevations = append(opts.Elevations, i)
+	for i, name := range names {
+		names[i] = strings.ToUpper(strings.TrimSpace(name))
+
+		if _, ok := validProducts[names[i]]; !ok {
+			logrus.Fatalf("invalid product %v", name)
+		}
+
+		if seen[names[i]] {
+			logrus.Fatalf("product %v given more than once", names[i])
 		}
+
+		seen[names[i]] = true
 	}
 
-	archive2 := readArchive(args[0])
+	if opts.Minimum != nil && opts.Maximum != nil && *opts.Minimum > *opts.Maximum {
+		logrus.Fatalf("minimum %v is greater than maximum %v", *opts.Minimum, *opts.Maximum)
+	}
 
-	bins := geo.RadarToBins(archive2, &opts)
+	if cmd.PersistentFlags().Changed("value-scale") {
+		if valueScale == 0 {
+			logrus.Fatalf("invalid value scale %v", valueScale)
+		}
 
-	var wg sync.WaitGroup
+		opts.ValueScale = &valueScale
+	}
 
-	for elevation, scan := range bins {
-		wg.Add(1)
-		go func(elevation int, scan []*geo.Bin) {
-			builder := geojson.BinsToString(scan)
+	opts.ValueOffset = valueOffset
 
-			o, err := os.Create(fmt.Sprintf("%v-%v-%v.json", output, opts.Product, elevation))
+	if dealias && !seen["VEL"] && !seen["SRV"] {
+		logrus.Fatalf("--dealias only applies to VEL and SRV")
+	}
 
-			if err != nil {
-				logrus.Fatal(err)
-			}
+	if seen["SRV"] != (stormMotion != "") {
+		logrus.Fatalf("SRV requires --storm-motion, which only applies to SRV")
+	}
 
-			o.WriteString(builder.String())
+	if stormMotion != "" {
+		motion, err := parseStormMotion(stormMotion)
 
-			err = o.Close()
+		if err != nil {
+			logrus.Fatalf("invalid storm motion %v: %s", stormMotion, err)
+		}
 
-			if err != nil {
-				logrus.Fatal(err)
-			}
+		opts.StormMotion = motion
+	}
 
-			wg.Done()
-		}(elevation, scan)
+	if thin < 1 {
+		logrus.Fatalf("invalid thin %v", thin)
 	}
 
-	wg.Wait()
+	opts.Thin = thin
+	opts.SortAzimuth = sortAzimuth
+	opts.DropAnomalousAzimuths = dropAnomalous
+	opts.NoCurvature = noCurvature
+
+	if sampleRate <= 0 || sampleRate > 1 {
+		logrus.Fatalf("invalid sample %v, expected a fraction greater than 0 and at most 1", sampleRate)
+	}
+
+	opts.Sample = sampleRate
+
+	if threads < 1 {
+		logrus.Fatalf("invalid threads %v", threads)
+	}
+
+	format = strings.ToUpper(format)
+
+	extension, ok := validFormats[format]
+
+	if !ok {
+		logrus.Fatalf("invalid format %v", format)
+	}
+
+	// colormaps are in the product's unit
+	if (opts.ValueScale != nil || opts.ValueOffset != 0) && (colormapName != "" || format == "PNG" || format == "KML" || format == "KMZ") {
+		logrus.Fatalf("--value-scale and --value-offset cannot be combined with --colormap, or png, kml or kmz output, which are colored by value")
+	}
+
+	if nameTemplate != "" {
+		if cmd.PersistentFlags().Changed("output") && !strings.HasSuffix(output, string(filepath.Separator)) {
+			logrus.Fatalf("--name-template names the output files, --output can only set their directory, ending with %v", string(filepath.Separator))
+		}
+	}
+
+	if format == "COVERAGE" && manifestName != "" {
+		logrus.Fatalf("--manifest does not apply to coverage output")
+	}
+
+	if format == "MVT" && output == "-" {
+		logrus.Fatalf("mvt output is a directory of tiles and cannot be written to stdout")
+	}
+
+	if format == "SHAPEFILE" && output == "-" {
+		logrus.Fatalf("shapefile output is a set of files and cannot be written to stdout")
+	}
+
+	if zoom < 0 || zoom > mvt.MaxZoom {
+		logrus.Fatalf("invalid zoom %v", zoom)
+	}
+
+	geometry = strings.ToLower(geometry)
+
+	if geometry != "polygon" && geometry != "point" {
+		logrus.Fatalf("invalid geometry %v", geometry)
+	}
+
+	if geometry == "point" && format != "GEOJSON" && format != "GEOJSONSEQ" {
+		logrus.Fatalf("--geometry point requires geojson or geojsonseq output")
+	}
+
+	if includeStation && format != "GEOJSON" && format != "GEOJSONSEQ" {
+		logrus.Fatalf("--include-station requires geojson or geojsonseq output")
+	}
+
+	if threeD && format != "GEOJSON" && format != "GEOJSONSEQ" {
+		logrus.Fatalf("--3d requires geojson or geojsonseq output")
+	}
+
+	if pretty && format != "GEOJSON" && format != "TOPOJSON" && format != "COVERAGE" && format != "MASK" {
+		logrus.Fatalf("--pretty requires geojson, topojson, coverage or mask output")
+	}
+
+	if keepFolded || keepBelow {
+		switch format {
+		case "GEOJSON", "GEOJSONSEQ", "TOPOJSON":
+		default:
+			logrus.Fatalf("--keep-folded and --keep-below-threshold require geojson, geojsonseq or topojson output")
+		}
+
+		if bucketSize > 0 {
+			logrus.Fatalf("--keep-folded and --keep-below-threshold cannot be combined with --bucket")
+		}
+	}
+
+	opts.KeepFolded = keepFolded
+	opts.KeepBelowThreshold = keepBelow
+
+	if precision < 0 {
+		logrus.Fatalf("invalid precision %v", precision)
+	}
+
+	if simplify < 0 {
+		logrus.Fatalf("invalid simplify %v", simplify)
+	}
+
+	if simplify > 0 && (bucketSize == 0 || (format != "GEOJSON" && format != "GEOJSONSEQ")) {
+		logrus.Fatalf("--simplify requires --bucket with geojson or geojsonseq output")
+	}
+
+	if bucketSize < 0 {
+		logrus.Fatalf("invalid bucket %v", bucketSize)
+	}
+
+	if bucketSize > 0 && threeD {
+		logrus.Fatalf("--3d cannot be combined with --bucket, which merges bins of different heights")
+	}
+
+	if bucketSize > 0 && format == "CSV" {
+		logrus.Fatalf("--bucket does not apply to csv output, a row per bin")
+	}
+
+	if bucketSize > 0 && format == "MASK" {
+		logrus.Fatalf("--bucket does not apply to mask output, one outline of every bin")
+	}
+
+	if resolution <= 0 {
+		logrus.Fatalf("invalid resolution %v", resolution)
+	}
+
+	if mergeToMax {
+		if center != "" || crs != "" || projPipeline != "" {
+			logrus.Fatalf("--merge-elevations-to-max requires longitude and latitude, it cannot be combined with --center, --crs or --proj-pipeline")
+		}
+
+		switch format {
+		case "CSV", "COVERAGE", "MASK":
+			logrus.Fatalf("--merge-elevations-to-max does not apply to %v output", strings.ToLower(format))
+		}
+
+		if height || includeAngle || threeD || keepFolded || keepBelow || dryRun {
+			logrus.Fatalf("--merge-elevations-to-max cannot be combined with --height, --include-angle, --3d, --keep-folded, --keep-below-threshold or --dry-run")
+		}
+
+		combined = true
+	}
+
+	elevations, err := parseElevations(elevationRange)
+
+	if err != nil {
+		logrus.Fatalf("invalid elevations %v: %s", elevationRange, err)
+	}
+
+	opts.Elevations = elevations
+
+	if cmd.PersistentFlags().Changed("angle") {
+		if cmd.PersistentFlags().Changed("elevations") {
+			logrus.Fatalf("--angle and --elevations cannot both be set")
+		}
+
+		if angle < 0 || angle > 90 {
+			logrus.Fatalf("invalid angle %v", angle)
+		}
+
+		byAngle = true
+	}
+
+	// elevations are georeferenced at once, sharing the threads between
+	// their radials
+	opts.Workers = threads / len(opts.Elevations)
+
+	if opts.Workers < 1 {
+		opts.Workers = 1
+	}
+
+	if output == "-" && len(opts.Elevations) > 1 && !combined && format != "GPKG" {
+		logrus.Fatalf("writing multiple elevations to stdout requires --combined")
+	}
+
+	// the bins of different elevations overlap, which dissolving can't union
+	if format == "MASK" && combined && len(opts.Elevations) > 1 {
+		logrus.Fatalf("--combined cannot be combined with mask output of more than one elevation, which overlap")
+	}
+
+	if bbox != "" {
+		bb, err := parseBoundingBox(bbox)
+
+		if err != nil {
+			logrus.Fatalf("invalid bbox %v: %s", bbox, err)
+		}
+
+		opts.BoundingBox = bb
+	}
+
+	if center != "" {
+		c, err := parseCenter(center)
+
+		if err != nil {
+			logrus.Fatalf("invalid center %v: %s", center, err)
+		}
+
+		opts.Center = c
+
+		switch format {
+		case "GEOTIFF", "PNG", "MVT", "KML", "KMZ", "GPKG", "CSV":
+			logrus.Fatalf("--center is not supported with %v output, which requires longitude and latitude", strings.ToLower(format))
+		}
+
+		if opts.BoundingBox != nil {
+			logrus.Fatalf("--center cannot be combined with --bbox")
+		}
+	}
+
+	if crs != "" {
+		if err := geo.CheckCRS(crs); err != nil {
+			logrus.Fatalf("invalid crs %v: %s", crs, err)
+		}
+
+		opts.CRS = crs
+
+		switch format {
+		case "GEOTIFF", "PNG", "MVT", "KML", "KMZ", "SHAPEFILE", "GPKG", "CSV":
+			logrus.Fatalf("--crs is not supported with %v output, which requires longitude and latitude", strings.ToLower(format))
+		}
+
+		if opts.BoundingBox != nil {
+			logrus.Fatalf("--crs cannot be combined with --bbox")
+		}
+
+		if opts.Center != nil {
+			logrus.Fatalf("--crs cannot be combined with --center")
+		}
+	}
+
+	if projPipeline != "" {
+		if err := geo.CheckPipeline(projPipeline); err != nil {
+			logrus.Fatalf("invalid proj pipeline %v: %s", projPipeline, err)
+		}
+
+		opts.Pipeline = projPipeline
+
+		switch format {
+		case "GEOTIFF", "PNG", "MVT", "KML", "KMZ", "SHAPEFILE", "GPKG", "CSV":
+			logrus.Fatalf("--proj-pipeline is not supported with %v output, which requires longitude and latitude", strings.ToLower(format))
+		}
+
+		if opts.BoundingBox != nil || opts.Center != nil || opts.CRS != "" {
+			logrus.Fatalf("--proj-pipeline cannot be combined with --bbox, --center or --crs")
+		}
+	}
+
+	if radarLocation != "" {
+		l, err := parseCenter(radarLocation)
+
+		if err != nil {
+			logrus.Fatalf("invalid radar location %v: %s", radarLocation, err)
+		}
+
+		location = l
+	}
+
+	if output == "-" && isBatch(args, walked) {
+		logrus.Fatalf("writing multiple input files to stdout is not supported")
+	}
+
+	if mergeProducts {
+		if !cmd.PersistentFlags().Changed("products") {
+			logrus.Fatalf("--merge-products requires --products")
+		}
+
+		if format != "GEOJSON" && format != "GEOJSONSEQ" {
+			logrus.Fatalf("--merge-products requires geojson or geojsonseq output")
+		}
+	}
+
+	if raw {
+		for _, name := range names {
+			if name == "KDP" || name == "REFGRAD" || name == "SRV" {
+				logrus.Fatalf("--raw does not apply to %v, which is derived from scaled values", name)
+			}
+		}
+
+		if dealias || colormapName != "" || mergeProducts {
+			logrus.Fatalf("--raw cannot be combined with --dealias, --colormap or --merge-products")
+		}
+
+		// levels are recovered from the scale and offset in the metadata
+		if opts.ValueScale != nil || opts.ValueOffset != 0 {
+			logrus.Fatalf("--raw cannot be combined with --value-scale or --value-offset")
+		}
+
+		if format == "PNG" || format == "KML" || format == "KMZ" {
+			logrus.Fatalf("--raw does not support %v output, which is colored by value", strings.ToLower(format))
+		}
+
+		opts.Raw = true
+	}
+
+	if output == "-" && len(names) > 1 && !mergeProducts && format != "GPKG" {
+		logrus.Fatalf("writing multiple products to stdout is not supported")
+	}
+
+	if nameTemplate != "" {
+		// one file per elevation and product, unless combined or merged
+		elevationFiles, productFiles := len(opts.Elevations), len(names)
+
+		if combined || byAngle || format == "GPKG" {
+			elevationFiles = 1
+		}
+
+		if mergeProducts || format == "GPKG" {
+			productFiles = 1
+		}
+
+		if err := checkNameTemplate(nameTemplate, elevationFiles, productFiles); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	// each product shares the elevations and geometry, with its own value
+	// filters and colormap
+	productOpts := make([]nexrad.Options, len(names))
+	colormaps := make(map[string]*colormap.Colormap, len(names))
+
+	for i, name := range names {
+		productOpts[i] = opts
+		productOpts[i].Product = name
+		productOpts[i].Dealias = dealias && (name == "VEL" || name == "SRV")
+
+		// default minimums are values, not levels
+		if m := geo.DefaultMinimum(name); m != nil && opts.Minimum == nil && !raw {
+			logrus.Debugf("using default minimum %v for %v", *m, name)
+			productOpts[i].Minimum = m
+
+			if opts.Maximum != nil && *m > *opts.Maximum {
+				logrus.Fatalf("default minimum %v for %v is greater than maximum %v", *m, name, *opts.Maximum)
+			}
+		}
+
+		// --minimum applies to every product, so one meant for REF can drop
+		// the inbound half of the velocity field
+		if (name == "VEL" || name == "SRV") && opts.Minimum != nil && *opts.Minimum >= 0 && !raw {
+			logrus.Warnf("--minimum %v drops all inbound (negative) %v velocities", *opts.Minimum, name)
+		}
+
+		if colormapName != "" {
+			cm, err := colormap.ForName(strings.ToLower(colormapName), name)
+
+			if err != nil {
+				logrus.Fatal(err)
+			}
+
+			colormaps[name] = cm
+		}
+	}
+
+	// checked before converting anything, as the manifest is written last
+	if manifestName != "" && !dryRun {
+		if err := checkOverwrite(manifestName); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	if legend != "" {
+		if legendColormap(names[0], colormaps) == nil {
+			logrus.Fatalf("--legend requires --colormap, or png, kml or kmz output")
+		}
+
+		if legend == "-" && output == "-" {
+			logrus.Fatalf("--legend and the output cannot both be written to stdout")
+		}
+
+		if !dryRun {
+			writeLegends(names, colormaps)
+		}
+	}
+
+	failed := 0
+	converted := 0
+
+	for _, filename := range args {
+		if interrupted() {
+			break
+		}
+
+		base := output
+
+		if isBatch(args, walked) {
+			base = outputBase(filename, walked[filename])
+		}
+
+		// skip files that are corrupt or lack the requested data, converting
+		// the rest of the batch
+		if err := convert(filename, base, productOpts, extension, colormaps); err != nil {
+			logrus.Errorf("%v: %s", filename, err)
+			failed++
+			continue
+		}
+
+		converted++
+	}
+
+	if manifestName != "" && !dryRun {
+		if err := outputs.write(manifestName); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	if interrupted() {
+		logrus.Fatalf("interrupted after converting %d of %d files, %d failed", converted, len(args), failed)
+	}
+
+	if failed > 0 {
+		logrus.Fatalf("%d of %d files failed to convert", failed, len(args))
+	}
+}
+
+// kmlColormap returns the colormap styling KML placemarks, the --colormap if
+// set, or the velocity table for VEL and SRV and the reflectivity table
+// otherwise.
+func kmlColormap(collection *geojson.FeatureCollection) *colormap.Colormap {
+	if collection.Properties.Colormap != nil {
+		return collection.Properties.Colormap
+	}
+
+	if collection.Properties.Product == "VEL" || collection.Properties.Product == "SRV" {
+		return colormap.Velocity
+	}
+
+	return colormap.Reflectivity
+}
+
+// documentName names a KML document after the station, product and time of
+// the collection.
+func documentName(collection *geojson.FeatureCollection) string {
+	if collection.Metadata == nil {
+		return collection.Properties.Product
+	}
+
+	return fmt.Sprintf("%v %v %v", collection.Metadata.Station, collection.Properties.Product, collection.Metadata.Time.Format(time.RFC3339))
+}
+
+// writeJSON writes the JSON encoded by write to w, indented if --pretty is
+// set. Indenting needs the whole document, so it is buffered in memory.
+func writeJSON(w io.Writer, write func(io.Writer)) {
+	if !pretty {
+		write(w)
+		return
+	}
+
+	var compact bytes.Buffer
+
+	write(&compact)
+
+	var indented bytes.Buffer
+
+	if err := json.Indent(&indented, compact.Bytes(), "", "  "); err != nil {
+		logrus.Fatal(err)
+	}
+
+	indented.WriteString("\n")
+
+	if _, err := indented.WriteTo(w); err != nil {
+		logrus.Fatal(err)
+	}
+}
+
+// outputBase returns the base output name for one of several input files,
+// placing outputs in the output directory when it ends in a separator, or
+// appending the input file's name to the base output name otherwise. Archives
+// found under an input directory keep their path within it, mirroring its
+// tree in the output directory, or joined by dashes otherwise. rel is the
+// archive's path within its input directory, or empty for other inputs.
+func outputBase(filename string, rel string) string {
+	name := inputName(filename)
+
+	if strings.HasSuffix(output, string(filepath.Separator)) {
+		if rel != "" {
+			return filepath.Join(output, rel)
+		}
+
+		return filepath.Join(output, name)
+	}
+
+	if rel != "" {
+		name = strings.Replace(rel, string(filepath.Separator), "-", -1)
+	}
+
+	return fmt.Sprintf("%v-%v", output, name)
+}
+
+// convert converts one input file, returning an error if it cannot be read
+// or converted. Errors writing output are fatal.
+func convert(filename string, base string, opts []nexrad.Options, extension string, colormaps map[string]*colormap.Colormap) error {
+	archive2, err := readArchive(filename)
+
+	if err != nil {
+		return err
+	}
+
+	if location != nil {
+		archive2.SetRadarLocation(location.Lat, location.Lon)
+	}
+
+	if byAngle {
+		opts, err = angleOptions(filename, archive2, opts)
+
+		if err != nil {
+			return err
+		}
+	}
+
+	if format == "COVERAGE" && !dryRun {
+		return convertCoverage(filename, base, archive2, opts, extension)
+	}
+
+	if dryRun {
+		return reportDryRun(os.Stdout, filename, archive2, base, opts, extension)
+	}
+
+	if progress {
+		completed := 0
+
+		opts[0].Progress = func(elevation int, bins int) {
+			completed++
+			fmt.Fprintf(os.Stderr, "%v: georeferenced elevation %d, %d bins (%d/%d)\n", filename, elevation, bins, completed, len(opts[0].Elevations))
+		}
+	}
+
+	if streamSequence() {
+		return streamSequences(filename, base, archive2, opts, extension, colormaps)
+	}
+
+	products, err := nexrad.ConvertArchiveProducts(archive2, opts)
+
+	if err != nil {
+		return err
+	}
+
+	counts := binCounts(products)
+
+	reportMissingProducts(filename, opts, counts)
+
+	if err := checkEmpty(filename, opts, counts); err != nil {
+		return err
+	}
+
+	station, err := stationPosition(archive2, opts)
+
+	if err != nil {
+		return err
+	}
+
+	for _, o := range opts {
+		for _, collection := range products[o.Product] {
+			setProperties(collection, colormaps)
+
+			if station != nil {
+				collection.SetStation(stationID(archive2), *station)
+			}
+		}
+	}
+
+	if mergeProducts {
+		writeMergedProducts(filename, base, archive2, opts, products, extension)
+		return nil
+	}
+
+	if format == "GPKG" {
+		writeGeoPackage(filename, base, archive2, opts, products, extension)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+
+	// bounds the writers, each of which marshals a whole collection
+	writers := make(chan struct{}, threads)
+
+	for _, o := range opts {
+		if interrupted() {
+			break
+		}
+
+		collections := products[o.Product]
+
+		if combined {
+			if len(collections) == 0 {
+				continue
+			}
+
+			all := combineElevations(collections)
+			elevations := make([]int, 0, len(collections))
+
+			for elevation := range collections {
+				elevations = append(elevations, elevation)
+			}
+
+			sort.Ints(elevations)
+
+			name := outputName(base, filename, archive2, o.Product, elevations, extension)
+			writeCollection(name, all)
+			reportWritten(filename, name, elevations, all)
+			continue
+		}
+
+		for elevation, collection := range collections {
+			if interrupted() {
+				break
+			}
+
+			wg.Add(1)
+			writers <- struct{}{}
+
+			go func(product string, elevation int, collection *geojson.FeatureCollection) {
+				defer func() { <-writers }()
+
+				name := outputName(base, filename, archive2, product, []int{elevation}, extension)
+				writeCollection(name, collection)
+				reportWritten(filename, name, []int{elevation}, collection)
+				wg.Done()
+			}(o.Product, elevation, collection)
+		}
+	}
+
+	wg.Wait()
+
+	return nil
+}
+
+// combineElevations combines the collections of a product's elevations into
+// one for --combined, or with --merge-elevations-to-max into a composite, a
+// cell of --resolution degrees holding the largest value of any elevation's
+// bins covering it, e.g. composite reflectivity.
+func combineElevations(collections map[int]*geojson.FeatureCollection) *geojson.FeatureCollection {
+	combined := geojson.Combine(collections)
+
+	if mergeToMax {
+		combined.Bins = raster.Rasterize(combined.Bins, resolution).Bins()
+		combined.Properties.Elevation = false
+	}
+
+	if maxFeatures > 0 {
+		combined.Truncate(maxFeatures)
+	}
+
+	return combined
+}
+
+// angleOptions returns a copy of the options converting the elevation of the
+// archive closest to --angle, preferring a scan with every product's moment.
+func angleOptions(filename string, ar2 *archive2.Archive2, opts []nexrad.Options) ([]nexrad.Options, error) {
+	names := make([]string, len(opts))
+
+	for i, o := range opts {
+		names[i] = o.Product
+	}
+
+	elevation, err := ar2.ClosestElevation(angle, names...)
+
+	if err != nil {
+		return nil, err
+	}
+
+	logrus.Infof("%v: selected elevation %d at %v degrees", filename, elevation, scanAngle(ar2.ElevationScans[elevation]))
+
+	selected := make([]nexrad.Options, len(opts))
+
+	for i, o := range opts {
+		selected[i] = o
+		selected[i].Elevations = []int{elevation}
+	}
+
+	return selected, nil
+}
+
+// stationPosition returns the radar's position in the output coordinates if
+// --include-station is set, or nil otherwise.
+func stationPosition(ar2 *archive2.Archive2, opts []nexrad.Options) (*proj.Coord, error) {
+	if !includeStation {
+		return nil, nil
+	}
+
+	lat, lon, err := ar2.RadarLocation()
+
+	if err != nil {
+		return nil, err
+	}
+
+	position, err := geo.RadarPosition(lat, lon, &opts[0])
+
+	if err != nil {
+		return nil, err
+	}
+
+	return &position, nil
+}
+
+// stationID returns the ICAO identifier of the radar recording the archive.
+func stationID(ar2 *archive2.Archive2) string {
+	return strings.TrimRight(string(ar2.VolumeHeader.ICAO[:]), "\x00 ")
+}
+
+// setProperties sets the feature properties chosen by the flags on a
+// collection of a product.
+func setProperties(collection *geojson.FeatureCollection, colormaps map[string]*colormap.Colormap) {
+	collection.Properties.Colormap = colormaps[collection.Properties.Product]
+	collection.BucketSize = bucketSize
+	collection.Properties.Simplify = simplify
+	collection.Properties.Precision = precision
+	collection.Properties.Height = height
+	collection.Properties.Angle = includeAngle
+	collection.Properties.Z = threeD
+	collection.Properties.Point = geometry == "point"
+}
+
+// binCounts returns the number of bins of each product's collections, by
+// product and elevation.
+func binCounts(products map[string]map[int]*geojson.FeatureCollection) map[string]map[int]int {
+	counts := make(map[string]map[int]int, len(products))
+
+	for product, collections := range products {
+		counts[product] = make(map[int]int, len(collections))
+
+		for elevation, collection := range collections {
+			counts[product][elevation] = len(collection.Bins)
+		}
+	}
+
+	return counts
+}
+
+// reportMissingProducts warns which elevations had each product, for
+// products missing from some of the requested elevations, given the bins of
+// each product and elevation converted.
+func reportMissingProducts(filename string, opts []nexrad.Options, counts map[string]map[int]int) {
+	for _, o := range opts {
+		present := make([]int, 0, len(o.Elevations))
+		missing := make([]int, 0)
+
+		for _, elevation := range o.Elevations {
+			if _, ok := counts[o.Product][elevation]; ok {
+				present = append(present, elevation)
+			} else {
+				missing = append(missing, elevation)
+			}
+		}
+
+		if len(missing) == 0 {
+			continue
+		}
+
+		if len(present) == 0 {
+			logrus.Warnf("%v: no elevations have %v data, nothing written for it", filename, o.Product)
+			continue
+		}
+
+		logrus.Warnf("%v: %v data present in elevations %s, missing from %s", filename, o.Product, joinElevations(present), joinElevations(missing))
+	}
+}
+
+// checkEmpty warns of each elevation of a product with no features, e.g. when
+// --minimum drops every gate, returning an error for the first if
+// --fail-on-empty is set.
+func checkEmpty(filename string, opts []nexrad.Options, counts map[string]map[int]int) error {
+	for _, o := range opts {
+		for _, elevation := range o.Elevations {
+			bins, ok := counts[o.Product][elevation]
+
+			if !ok || bins > 0 {
+				continue
+			}
+
+			if failOnEmpty {
+				return fmt.Errorf("elevation %d has no %v features", elevation, o.Product)
+			}
+
+			logrus.Warnf("%v: elevation %d has no %v features, check --minimum, --maximum, --bbox and --max-range", filename, elevation, o.Product)
+		}
+	}
+
+	return nil
+}
+
+// reportWritten records a finished output file for the manifest, and reports
+// it to stderr if --progress is set.
+func reportWritten(input string, filename string, elevations []int, collection *geojson.FeatureCollection) {
+	outputs.add(input, filename, elevations, collection)
+	reportProgress(filename, len(collection.Bins))
+}
+
+// reportProgress reports a finished output file of bins to stderr if
+// --progress is set.
+func reportProgress(filename string, bins int) {
+	if progress {
+		fmt.Fprintf(os.Stderr, "wrote %v, %d bins\n", filename, bins)
+	}
+}
+
+// outputFilename appends the suffix and extension to the base output name, or
+// returns "-" when writing to stdout.
+func outputFilename(base string, suffix string, extension string) string {
+	if base == "-" {
+		return "-"
+	}
+
+	return fmt.Sprintf("%v-%v.%v", base, suffix, extension)
+}
+
+// checkOverwrite returns an error if an output file or directory exists,
+// unless --overwrite is set, so a rerun can't silently replace earlier
+// results.
+func checkOverwrite(filename string) error {
+	if overwrite {
+		return nil
+	}
+
+	if _, err := os.Stat(filename); err == nil {
+		return fmt.Errorf("%v already exists, use --overwrite to replace it", filename)
+	}
+
+	return nil
+}
+
+func writeWorldFile(filename string, grid *raster.Grid) {
+	if err := checkOverwrite(filename); err != nil {
+		logrus.Fatal(err)
+	}
+
+	o, err := os.Create(filename)
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	startOutput(filename)
+
+	err = raster.WriteWorldFile(o, grid)
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	err = o.Close()
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	finishOutput(filename)
+}
+
+// writeShapefile writes the .shp, .shx, .dbf and .prj files of a shapefile
+// named base.
+func writeShapefile(base string, collection *geojson.FeatureCollection) {
+	if err := os.MkdirAll(filepath.Dir(base), 0755); err != nil {
+		logrus.Fatal(err)
+	}
+
+	files := make(map[string]*os.File)
+
+	for _, ext := range []string{"shp", "shx", "dbf", "prj"} {
+		if err := checkOverwrite(base + "." + ext); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	for _, ext := range []string{"shp", "shx", "dbf", "prj"} {
+		f, err := os.Create(base + "." + ext)
+
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		files[ext] = f
+		startOutput(base + "." + ext)
+	}
+
+	shp := bufio.NewWriter(files["shp"])
+	shx := bufio.NewWriter(files["shx"])
+	dbf := bufio.NewWriter(files["dbf"])
+
+	if err := shapefile.Write(shp, shx, dbf, collection.Bins, &collection.Properties); err != nil {
+		logrus.Fatal(err)
+	}
+
+	for _, w := range []*bufio.Writer{shp, shx, dbf} {
+		if err := w.Flush(); err != nil {
+			logrus.Fatal(err)
+		}
+	}
+
+	if _, err := files["prj"].WriteString(shapefile.Projection); err != nil {
+		logrus.Fatal(err)
+	}
+
+	for ext, f := range files {
+		if err := f.Close(); err != nil {
+			logrus.Fatal(err)
+		}
+
+		finishOutput(base + "." + ext)
+	}
+}
+
+func writeCollection(filename string, collection *geojson.FeatureCollection) {
+	if format == "MVT" {
+		// tiles are written to a directory named after the output, z/x/y.pbf
+		dir := strings.TrimSuffix(filename, ".mvt")
+
+		if err := checkOverwrite(dir); err != nil {
+			logrus.Fatal(err)
+		}
+
+		err := mvt.WriteTiles(dir, collection.Bins, &collection.Properties, zoom)
+
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		return
+	}
+
+	if format == "SHAPEFILE" {
+		writeShapefile(strings.TrimSuffix(filename, ".shp"), collection)
+		return
+	}
+
+	writeOutput(filename, func(w io.Writer) {
+		switch format {
+		case "GEOJSONSEQ":
+			collection.WriteSeq(w)
+		case "TOPOJSON":
+			writeJSON(w, collection.WriteTopo)
+		case "MASK":
+			writeJSON(w, collection.WriteMask)
+		case "KML":
+			if err := kml.Write(w, collection.Bins, &collection.Properties, kmlColormap(collection), documentName(collection)); err != nil {
+				logrus.Fatal(err)
+			}
+		case "KMZ":
+			if err := kml.WriteKMZ(w, collection.Bins, &collection.Properties, kmlColormap(collection), documentName(collection)); err != nil {
+				logrus.Fatal(err)
+			}
+		case "CSV":
+			if err := csv.Write(w, collection.Bins, &collection.Properties); err != nil {
+				logrus.Fatal(err)
+			}
+		case "GEOTIFF":
+			if err := raster.WriteGeoTIFF(w, raster.Rasterize(collection.Bins, resolution)); err != nil {
+				logrus.Fatal(err)
+			}
+		case "PNG":
+			grid := raster.Rasterize(collection.Bins, resolution)
+
+			cm := collection.Properties.Colormap
+
+			if cm == nil {
+				cm = colormap.Reflectivity
+			}
+
+			if err := raster.WritePNG(w, grid, cm); err != nil {
+				logrus.Fatal(err)
+			}
+
+			if filename != "-" {
+				writeWorldFile(strings.TrimSuffix(filename, ".png")+".pgw", grid)
+			}
+		default:
+			writeJSON(w, collection.Write)
+		}
+	})
+}
+
+// writeOutput creates the output file, or uses stdout for "-", and writes it
+// through a buffer with write. Errors are fatal.
+func writeOutput(filename string, write func(w io.Writer)) {
+	o := os.Stdout
+
+	if filename != "-" {
+		if err := checkOverwrite(filename); err != nil {
+			logrus.Fatal(err)
+		}
+
+		err := os.MkdirAll(filepath.Dir(filename), 0755)
+
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		o, err = os.Create(filename)
+
+		if err != nil {
+			logrus.Fatal(err)
+		}
+
+		startOutput(filename)
+	}
+
+	w := bufio.NewWriter(o)
+
+	write(w)
+
+	err := w.Flush()
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	if filename == "-" {
+		return
+	}
+
+	err = o.Close()
+
+	if err != nil {
+		logrus.Fatal(err)
+	}
+
+	finishOutput(filename)
 }
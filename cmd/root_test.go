@@ -0,0 +1,52 @@
+package cmd
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestParseElevations(t *testing.T) {
+	tests := []struct {
+		s        string
+		expected []int
+		valid    bool
+	}{
+		{"1", []int{1}, true},
+		{"1,3,5-7", []int{1, 3, 5, 6, 7}, true},
+		{"5-7,6,1,1", []int{1, 5, 6, 7}, true},
+		{" 1 , 3-4 ", []int{1, 3, 4}, true},
+		{"5-5", []int{5}, true},
+		{"5-3", nil, false},
+		{"1,,3", nil, false},
+		{"1,", nil, false},
+		{"", nil, false},
+		{"a", nil, false},
+	}
+
+	for _, test := range tests {
+		elevations, err := parseElevations(test.s)
+
+		if test.valid && err != nil {
+			t.Errorf("%q: unexpected error %v", test.s, err)
+			continue
+		}
+
+		if !test.valid {
+			if err == nil {
+				t.Errorf("%q: expected an error, got %v", test.s, elevations)
+			}
+
+			continue
+		}
+
+		if !reflect.DeepEqual(elevations, test.expected) {
+			t.Errorf("%q: expected %v, got %v", test.s, test.expected, elevations)
+		}
+	}
+
+	// only a range ending below its start is reversed
+	if _, err := parseElevations("5-3"); err == nil || !strings.Contains(err.Error(), "ends before it starts") {
+		t.Errorf("expected 5-3 to end before it starts, got %v", err)
+	}
+}
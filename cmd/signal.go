@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"os"
+	"os/signal"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"github.com/sirupsen/logrus"
+)
+
+// interruptExitCode is the exit status after a second SIGINT or SIGTERM, as
+// shells report a process killed by SIGINT.
+const interruptExitCode = 130
+
+var (
+	// interruptCount is the number of SIGINT and SIGTERM signals received
+	interruptCount int32
+
+	// incomplete holds the output files being written, removed if the
+	// process exits before they are closed
+	incomplete   = make(map[string]bool)
+	incompleteMu sync.Mutex
+)
+
+// handleInterrupts stops launching new work on the first SIGINT or SIGTERM,
+// letting the files being written complete, and on the second removes them
+// and exits. Files left incomplete by a fatal error are removed as well.
+func handleInterrupts() {
+	logrus.RegisterExitHandler(removeIncomplete)
+
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	go func() {
+		for range signals {
+			if atomic.AddInt32(&interruptCount, 1) == 1 {
+				logrus.Warn("interrupted, finishing the files being written; interrupt again to remove them and exit")
+				continue
+			}
+
+			removeIncomplete()
+			os.Exit(interruptExitCode)
+		}
+	}()
+}
+
+// interrupted returns true once a SIGINT or SIGTERM has been received, after
+// which no new input file or output is started.
+func interrupted() bool {
+	return atomic.LoadInt32(&interruptCount) > 0
+}
+
+// startOutput records a file as being written, until finishOutput.
+func startOutput(filename string) {
+	incompleteMu.Lock()
+	defer incompleteMu.Unlock()
+
+	incomplete[filename] = true
+}
+
+// finishOutput records a file as complete.
+func finishOutput(filename string) {
+	incompleteMu.Lock()
+	defer incompleteMu.Unlock()
+
+	delete(incomplete, filename)
+}
+
+// removeIncomplete removes the output files still being written, so no
+// truncated files are left behind. Files are left recorded, as the process
+// is exiting.
+func removeIncomplete() {
+	incompleteMu.Lock()
+	defer incompleteMu.Unlock()
+
+	for filename := range incomplete {
+		if err := os.Remove(filename); err != nil && !os.IsNotExist(err) {
+			logrus.Errorf("failed to remove incomplete %v: %s", filename, err)
+			continue
+		}
+
+		logrus.Warnf("removed incomplete %v", filename)
+	}
+}
@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"errors"
+	"io"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+	"github.com/jtleniger/go-nexrad-geojson/nexrad"
+)
+
+// errStopped stops streaming once interrupted, before another file starts.
+var errStopped = errors.New("interrupted")
+
+// streamSequence returns true if geojsonseq output is written as the radials
+// are georeferenced, a batch at a time, so memory holds the bins of a batch
+// rather than of every elevation. --bucket, --merge-products,
+// --merge-elevations-to-max and --fail-on-empty need every bin of an
+// elevation before writing, so with them the collections are built in memory
+// as for other formats.
+func streamSequence() bool {
+	return format == "GEOJSONSEQ" && bucketSize == 0 && !mergeProducts && !mergeToMax && !failOnEmpty
+}
+
+// sequenceWriter writes the batches of a product's bins sent to it as a
+// GeoJSON text sequence from a goroutine, recording the file for the manifest
+// once written.
+type sequenceWriter struct {
+	batches chan *geojson.FeatureCollection
+	done    chan struct{}
+}
+
+// startSequence starts writing the file name, of the elevations, with the
+// first batch of its bins.
+func startSequence(input string, name string, elevations []int, first *geojson.FeatureCollection) *sequenceWriter {
+	s := &sequenceWriter{
+		// a batch is buffered, so georeferencing runs ahead of writing
+		batches: make(chan *geojson.FeatureCollection, 1),
+		done:    make(chan struct{}),
+	}
+
+	entry := newManifestEntry(input, name, elevations, first)
+
+	go func() {
+		defer close(s.done)
+
+		bins := 0
+
+		writeOutput(name, func(w io.Writer) {
+			for batch := range s.batches {
+				batch.WriteSeq(w)
+				entry.Features += batch.FeatureCount()
+				entry.addValues(batch.Bins)
+				bins += len(batch.Bins)
+			}
+		})
+
+		outputs.addEntry(entry)
+		reportProgress(name, bins)
+	}()
+
+	s.batches <- first
+
+	return s
+}
+
+// finish waits until the batches sent are written and the file is closed.
+func (s *sequenceWriter) finish() {
+	close(s.batches)
+	<-s.done
+}
+
+// streamSequences converts an archive to GeoJSON text sequences, writing each
+// batch of bins as it is georeferenced, to a file per product and elevation,
+// or per product with --combined, named as convert names them.
+func streamSequences(filename string, base string, ar2 *archive2.Archive2, opts []nexrad.Options, extension string, colormaps map[string]*colormap.Colormap) error {
+	station, err := stationPosition(ar2, opts)
+
+	if err != nil {
+		return err
+	}
+
+	// elevations are georeferenced in turn, each sharing every thread
+	// between its radials
+	streamed := make([]nexrad.Options, len(opts))
+	copy(streamed, opts)
+	streamed[0].Workers = threads
+
+	writers := make(map[string]*sequenceWriter, len(opts))
+
+	finish := func() {
+		for product, writer := range writers {
+			writer.finish()
+			delete(writers, product)
+		}
+	}
+
+	// the bins georeferenced of each product by elevation, and written of
+	// each product, capped at --max-features with --combined
+	counts := make(map[string]map[int]int, len(opts))
+	written := make(map[string]int, len(opts))
+	current := 0
+
+	err = nexrad.StreamArchiveProducts(ar2, streamed, func(elevation int, collection *geojson.FeatureCollection) error {
+		product := collection.Properties.Product
+
+		// each elevation's files are complete before the next's start
+		if elevation != current && !combined {
+			finish()
+		}
+
+		current = elevation
+
+		if counts[product] == nil {
+			counts[product] = make(map[int]int)
+		}
+
+		counts[product][elevation] += len(collection.Bins)
+
+		setProperties(collection, colormaps)
+
+		if combined {
+			collection.Properties.Elevation = true
+
+			if maxFeatures > 0 {
+				collection.Truncate(maxFeatures - written[product])
+			}
+		}
+
+		written[product] += len(collection.Bins)
+
+		if writer, ok := writers[product]; ok {
+			writer.batches <- collection
+			return nil
+		}
+
+		if interrupted() {
+			return errStopped
+		}
+
+		if station != nil {
+			collection.SetStation(stationID(ar2), *station)
+		}
+
+		elevations := []int{elevation}
+
+		if combined {
+			elevations = productElevations(ar2, opts[0].Elevations, product)
+		}
+
+		name := outputName(base, filename, ar2, product, elevations, extension)
+		writers[product] = startSequence(filename, name, elevations, collection)
+
+		return nil
+	})
+
+	finish()
+
+	// the files started are complete, and no others once interrupted
+	if err != nil && err != errStopped {
+		return err
+	}
+
+	reportMissingProducts(filename, opts, counts)
+
+	return checkEmpty(filename, opts, counts)
+}
+
+// productElevations returns the elevations with radials holding a product's
+// moment, those a combined file of the product covers, known before they are
+// georeferenced.
+func productElevations(ar2 *archive2.Archive2, elevations []int, product string) []int {
+	present := make([]int, 0, len(elevations))
+
+	for _, elevation := range elevations {
+		for _, radial := range ar2.ElevationScans[elevation] {
+			if radial.HasMoment(product) {
+				present = append(present, elevation)
+				break
+			}
+		}
+	}
+
+	return present
+}
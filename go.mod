@@ -1,6 +1,6 @@
 module github.com/jtleniger/go-nexrad-geojson
 
-go 1.12
+go 1.16
 
 require (
 	github.com/d4l3k/go-pbzip2 v0.0.0-20181117060939-9d7e0c2f0367
@@ -1,12 +1,14 @@
 package archive2
 
 import (
-	"bytes"
+	"bufio"
+	"compress/bzip2"
 	"compress/gzip"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
-	"io/ioutil"
+	"math"
 	"sort"
 	"time"
 
@@ -31,8 +33,20 @@ type Archive2 struct {
 	VCP              *Message5
 }
 
-// Extract data from a given archive 2 data file.
+// Extract data from a given archive 2 data file, panicking if it is corrupt.
+// See Read, which returns an error instead.
 func Extract(f io.ReadSeeker) *Archive2 {
+	ar2, err := extract(f)
+
+	if err != nil {
+		logrus.Panic(err)
+	}
+
+	return ar2
+}
+
+// extract reads the volume header and radials of an archive 2 data file.
+func extract(f io.ReadSeeker) (*Archive2, error) {
 	ar2ExtractTimeStart := time.Now()
 	defer func() {
 		logrus.Debugf("ar2: done %s", time.Since(ar2ExtractTimeStart))
@@ -44,21 +58,18 @@ func Extract(f io.ReadSeeker) *Archive2 {
 		VolumeHeader:   VolumeHeaderRecord{},
 	}
 
-	// older archive2 files are gzipped, check for those and decompress if found
-	if yes, ctype := isCompressed(f); yes {
-		if ctype != "gz" {
-			logrus.Fatalf("unsupported compression %s", ctype)
-		}
-		var gzd *gzip.Reader
-		var err error
-		if gzd, err = gzip.NewReader(f); err != nil {
-			logrus.Fatalf("failed to open gzip file: %s", err)
-		}
-		gzb, err := ioutil.ReadAll(gzd)
-		if err != nil {
-			logrus.Fatalf("failed to read gzip file: %s", err)
+	// some archive2 files are distributed gzipped or bzipped as a whole,
+	// check for those and decompress if found
+	yes, ctype, err := isCompressed(f)
+
+	if err != nil {
+		return nil, err
+	}
+
+	if yes {
+		if f, err = decompressFile(f, ctype); err != nil {
+			return nil, err
 		}
-		f = bytes.NewReader(gzb)
 	}
 
 	// -------------------------- Volume Header Record -------------------------
@@ -92,9 +103,9 @@ func Extract(f io.ReadSeeker) *Archive2 {
 		// read in control word (size) of LDM record
 		if err := binary.Read(f, binary.BigEndian, &ldm.Size); err != nil {
 			if err != io.EOF {
-				logrus.Panic(err.Error())
+				return nil, err
 			}
-			return &ar2
+			return &ar2, nil
 		}
 
 		// As the control word contains a negative size under some circumstances,
@@ -111,10 +122,18 @@ func Extract(f io.ReadSeeker) *Archive2 {
 			"size": ldm.Size,
 		}).Tracef("ar2: ldm: new LDM record")
 
+		c, _, err := isCompressed(f)
+
+		if err != nil {
+			return nil, err
+		}
+
 		var msgBuf io.ReadSeeker
-		if c, _ := isCompressed(f); c {
+		if c {
 			logrus.Tracef("ar2: ldm: decompressing %d bytes", ldm.Size)
-			msgBuf = decompressBZ2(f, ldm.Size)
+			if msgBuf, err = decompressBZ2(f, ldm.Size); err != nil {
+				return nil, err
+			}
 		} else {
 			msgBuf = f
 		}
@@ -138,7 +157,7 @@ func Extract(f io.ReadSeeker) *Archive2 {
 			if err := binary.Read(msgBuf, binary.BigEndian, &msgHeader); err != nil {
 				if err != io.EOF {
 					logrus.Debugf("processed %d messages", numMessages)
-					logrus.Panic(err.Error())
+					return nil, err
 				}
 				break
 			}
@@ -183,8 +202,17 @@ func Extract(f io.ReadSeeker) *Archive2 {
 
 			// 	// move to the end of the message
 			// 	msgBuf.Seek(MessageBodySize-int64(msgHeader.MessageSize), io.SeekCurrent)
+			case 1:
+				m1, err := msg1(msgBuf, ar2.VolumeHeader.ICAO)
+				if err != nil {
+					return nil, err
+				}
+				ar2.ElevationScans[int(m1.Header.ElevationNumber)] = append(ar2.ElevationScans[int(m1.Header.ElevationNumber)], m1)
 			case 31:
-				m31 := msg31(msgBuf)
+				m31, err := msg31(msgBuf)
+				if err != nil {
+					return nil, err
+				}
 				// logrus.Trace(m31.Header.String())
 				ar2.ElevationScans[int(m31.Header.ElevationNumber)] = append(ar2.ElevationScans[int(m31.Header.ElevationNumber)], m31)
 			default:
@@ -193,7 +221,7 @@ func Extract(f io.ReadSeeker) *Archive2 {
 				}
 				_, err := msgBuf.Seek(MessageBodySize, io.SeekCurrent)
 				if err != nil {
-					logrus.Panic("failed to seek forward header message size")
+					return nil, errors.New("failed to seek forward header message size")
 				}
 			}
 
@@ -201,7 +229,49 @@ func Extract(f io.ReadSeeker) *Archive2 {
 		}
 		logrus.Tracef("ar2: ldm: done: %s messages:%v", time.Since(ldmExtractTimeStart), messageCounts)
 	}
-	return &ar2
+}
+
+// Read extracts an archive 2 data file like Extract, but returns an error
+// rather than panicking when the file is corrupt, and when the volume is
+// incomplete, see Validate.
+func Read(f io.ReadSeeker) (*Archive2, error) {
+	ar2, err := extract(f)
+
+	if err != nil {
+		return nil, fmt.Errorf("corrupt or truncated archive: %s", err)
+	}
+
+	if err := ar2.Validate(); err != nil {
+		return nil, err
+	}
+
+	return ar2, nil
+}
+
+// Validate returns an error if the volume holds no radials, or is truncated,
+// with its last radial not marked as the end of the volume as a partially
+// downloaded file would be. Elevations without radials are passed over.
+func (ar2 *Archive2) Validate() error {
+	elevations := make([]int, 0, len(ar2.ElevationScans))
+
+	for _, elevation := range ar2.Elevations() {
+		if len(ar2.ElevationScans[elevation]) > 0 {
+			elevations = append(elevations, elevation)
+		}
+	}
+
+	if len(elevations) == 0 {
+		return errors.New("archive contains no radials")
+	}
+
+	last := elevations[len(elevations)-1]
+	scan := ar2.ElevationScans[last]
+
+	if status := scan[len(scan)-1].Header.RadialStatus; status != radialStatusEndOfVolumeScan {
+		return fmt.Errorf("truncated archive: elevation %d ends after %d radials without the end of the volume", last, len(scan))
+	}
+
+	return nil
 }
 
 func (ar2 *Archive2) String() string {
@@ -223,3 +293,122 @@ func (ar2 *Archive2) Elevations() []int {
 	sort.Ints(elevs)
 	return elevs
 }
+
+// ClosestElevation returns the number of the elevation scan whose angle is
+// closest to angle in degrees, so a tilt can be chosen the same way across
+// VCPs whose elevation numbers differ. Split cuts scan the lowest angles
+// twice, surveillance then Doppler, so of scans at the same tenth of a degree
+// the first with the moments of all the products is chosen, e.g. the Doppler
+// cut for VEL, falling back to the first with the angle.
+func (ar2 *Archive2) ClosestElevation(angle float32, products ...string) (int, error) {
+	best := -1
+	var bestDiff float64
+	bestMissing := 0
+
+	for _, elevation := range ar2.Elevations() {
+		scan := ar2.ElevationScans[elevation]
+
+		if len(scan) == 0 {
+			continue
+		}
+
+		// compared to a tenth of a degree, as angles vary slightly between
+		// the cuts of a split cut
+		diff := math.Round(math.Abs(float64(scan[0].Header.ElevationAngle-angle))*10) / 10
+		missing := 0
+
+		for _, product := range products {
+			if !scan[0].HasMoment(product) {
+				missing++
+			}
+		}
+
+		if best == -1 || diff < bestDiff || (diff == bestDiff && missing < bestMissing) {
+			best, bestDiff, bestMissing = elevation, diff, missing
+		}
+	}
+
+	if best == -1 {
+		return 0, errors.New("archive contains no radials")
+	}
+
+	return best, nil
+}
+
+// RadarLocation returns the latitude and longitude of the radar from the
+// first radial of the lowest elevation scan containing any radials. Legacy
+// archives don't record it, so it must be set with SetRadarLocation first.
+func (ar2 *Archive2) RadarLocation() (float32, float32, error) {
+	for _, elevation := range ar2.Elevations() {
+		if scan := ar2.ElevationScans[elevation]; len(scan) > 0 {
+			radial := scan[0]
+
+			if radial.Legacy && radial.VolumeData.Lat == 0 && radial.VolumeData.Lon == 0 {
+				return 0, 0, errors.New("legacy Message 1 archive does not record the radar location")
+			}
+
+			return radial.VolumeData.Lat, radial.VolumeData.Lon, nil
+		}
+	}
+
+	return 0, 0, errors.New("archive contains no radials")
+}
+
+// VolumeTime returns the collection time of the first radial of the volume,
+// that of the lowest elevation scan containing any radials, or the date of
+// the volume header if it holds none.
+func (ar2 *Archive2) VolumeTime() time.Time {
+	for _, elevation := range ar2.Elevations() {
+		if scan := ar2.ElevationScans[elevation]; len(scan) > 0 {
+			return scan[0].Header.Date()
+		}
+	}
+
+	return ar2.VolumeHeader.Date()
+}
+
+// SetRadarLocation sets the latitude and longitude of the radar on every
+// radial, overriding any recorded location.
+func (ar2 *Archive2) SetRadarLocation(lat float32, lon float32) {
+	for _, scan := range ar2.ElevationScans {
+		for _, radial := range scan {
+			radial.VolumeData.Lat = lat
+			radial.VolumeData.Lon = lon
+		}
+	}
+}
+
+// ReadVolumeHeader reads just the volume header record at the start of an
+// archive 2 data file, decompressing the start of files compressed as a
+// whole, e.g. to find the time of a volume without extracting it.
+func ReadVolumeHeader(f io.Reader) (VolumeHeaderRecord, error) {
+	header := VolumeHeaderRecord{}
+	b := bufio.NewReader(f)
+
+	magic, err := b.Peek(2)
+
+	if err != nil {
+		return header, err
+	}
+
+	var r io.Reader = b
+
+	switch string(magic) {
+	case "BZ":
+		r = bzip2.NewReader(b)
+	case "\x1f\x8b":
+		gzd, err := gzip.NewReader(b)
+
+		if err != nil {
+			return header, err
+		}
+
+		r = gzd
+	}
+
+	if err := binary.Read(r, binary.BigEndian, &header); err != nil {
+		return header, fmt.Errorf("failed to read volume header: %s", err)
+	}
+
+	return header, nil
+}
@@ -3,6 +3,7 @@ package archive2
 import (
 	"os"
 	"testing"
+	"time"
 )
 
 func TestExtract(t *testing.T) {
@@ -29,3 +30,68 @@ func TestRawDatasets(t *testing.T) {
 		Extract(tamu)
 	}
 }
+
+func TestClosestElevation(t *testing.T) {
+	radial := func(angle float32, doppler bool) []*Message31 {
+		m31 := &Message31{Header: Message31Header{ElevationAngle: angle}, ReflectivityData: &DataMoment{}}
+
+		if doppler {
+			m31.ReflectivityData = nil
+			m31.VelocityData = &DataMoment{}
+		}
+
+		return []*Message31{m31}
+	}
+
+	// a split cut at 0.5 degrees, then 0.9 and 1.5 degree tilts
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{
+		1: radial(0.48, false),
+		2: radial(0.53, true),
+		3: radial(0.88, false),
+		4: radial(1.45, false),
+	}}
+
+	cases := []struct {
+		angle     float32
+		products  []string
+		elevation int
+	}{
+		{0.5, []string{"REF"}, 1},
+		{0.5, []string{"VEL"}, 2},
+		{0.5, nil, 1},
+		{0.8, []string{"VEL"}, 3},
+		{1.5, []string{"REF"}, 4},
+		{19.5, []string{"REF"}, 4},
+	}
+
+	for _, c := range cases {
+		elevation, err := ar2.ClosestElevation(c.angle, c.products...)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if elevation != c.elevation {
+			t.Errorf("%v degrees %v: expected elevation %d, got %d", c.angle, c.products, c.elevation, elevation)
+		}
+	}
+
+	if _, err := (&Archive2{ElevationScans: map[int][]*Message31{}}).ClosestElevation(0.5); err == nil {
+		t.Errorf("expected an error for an empty archive")
+	}
+}
+
+func TestVolumeTime(t *testing.T) {
+	// 1970/1/1 is day 1, so day 19000 is 2022-01-07
+	ar2 := &Archive2{ElevationScans: map[int][]*Message31{
+		1: {},
+		2: {{Header: Message31Header{CollectionDate: 19000, CollectionTime: 3600000}}},
+		3: {{Header: Message31Header{CollectionDate: 19000, CollectionTime: 3630000}}},
+	}}
+
+	expected := time.Date(2022, 1, 7, 1, 0, 0, 0, time.UTC)
+
+	if got := ar2.VolumeTime(); !got.Equal(expected) {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
@@ -0,0 +1,191 @@
+package archive2
+
+import "math"
+
+// kdpWindow is the number of gates either side of a gate used to fit KDP.
+const kdpWindow = 4
+
+// kdpMinGates is the minimum number of valid PHI gates in a window to fit KDP.
+const kdpMinGates = 3
+
+// phiWrap is the period in degrees of differential phase, which wraps from
+// 360 back to 0.
+const phiWrap = 360
+
+// SpecificDifferentialPhase derives KDP in deg/km from scaled differential
+// phase gates spaced gateSpacing meters apart. KDP is half the range
+// derivative of PHI, estimated here as half the least squares slope of PHI,
+// unwrapped along the radial, over a window of gates centered on each gate.
+// Gates without enough valid PHI in their window are marked below threshold.
+func SpecificDifferentialPhase(phi []float32, gateSpacing float64) []float32 {
+	kdp := make([]float32, len(phi))
+	unwrapped := unwrapPhase(phi)
+
+	gateSpacingKm := gateSpacing / 1000
+
+	for i, v := range phi {
+		if v == MomentDataBelowThreshold || v == MomentDataFolded {
+			kdp[i] = v
+			continue
+		}
+
+		var n, sumX, sumY, sumXY, sumXX float64
+
+		for j := i - kdpWindow; j <= i+kdpWindow; j++ {
+			if j < 0 || j >= len(phi) || phi[j] == MomentDataBelowThreshold || phi[j] == MomentDataFolded {
+				continue
+			}
+
+			x := float64(j) * gateSpacingKm
+			y := unwrapped[j]
+
+			n++
+			sumX += x
+			sumY += y
+			sumXY += x * y
+			sumXX += x * x
+		}
+
+		denominator := n*sumXX - sumX*sumX
+
+		if n < kdpMinGates || denominator == 0 {
+			kdp[i] = MomentDataBelowThreshold
+			continue
+		}
+
+		slope := (n*sumXY - sumX*sumY) / denominator
+
+		kdp[i] = float32(slope / 2)
+	}
+
+	return kdp
+}
+
+// unwrapPhase returns the differential phase of each gate made continuous
+// along the radial, each valid gate shifted by the multiple of phiWrap that
+// brings it within half a wrap of the previous valid gate, so a fit across
+// the wrap sees the ramp rather than a jump of 360 degrees. Gates without
+// valid PHI are left as is.
+func unwrapPhase(phi []float32) []float64 {
+	unwrapped := make([]float64, len(phi))
+	haveReference := false
+	reference := 0.0
+
+	for i, v := range phi {
+		value := float64(v)
+
+		if v == MomentDataBelowThreshold || v == MomentDataFolded {
+			unwrapped[i] = value
+			continue
+		}
+
+		if haveReference {
+			value -= phiWrap * math.Round((value-reference)/phiWrap)
+		}
+
+		unwrapped[i] = value
+		reference = value
+		haveReference = true
+	}
+
+	return unwrapped
+}
+
+// ReflectivityGradient derives the along-beam gradient of reflectivity in
+// dBZ/km from scaled REF gates spaced gateSpacing meters apart, as the
+// difference between each gate and the one before it. The gradient is
+// positive where reflectivity increases away from the radar. The first gate,
+// and gates next to a gate without valid REF, are marked below threshold.
+func ReflectivityGradient(ref []float32, gateSpacing float64) []float32 {
+	gradient := make([]float32, len(ref))
+
+	gateSpacingKm := float32(gateSpacing / 1000)
+
+	for i, v := range ref {
+		if v == MomentDataBelowThreshold || v == MomentDataFolded {
+			gradient[i] = v
+			continue
+		}
+
+		if i == 0 || ref[i-1] == MomentDataBelowThreshold || ref[i-1] == MomentDataFolded {
+			gradient[i] = MomentDataBelowThreshold
+			continue
+		}
+
+		gradient[i] = (v - ref[i-1]) / gateSpacingKm
+	}
+
+	return gradient
+}
+
+// dealiasMaxGap is the number of consecutive missing gates after which
+// dealiasing restarts from the next valid gate rather than trusting a
+// distant reference.
+const dealiasMaxGap = 20
+
+// Dealias unfolds scaled radial velocity gates along a radial with a simple
+// gate-to-gate check: each gate is shifted by the multiple of twice the
+// Nyquist velocity that brings it closest to the previous valid gate. The
+// first valid gate, and the first after a long gap, is assumed unaliased.
+func Dealias(velocity []float32, nyquist float32) []float32 {
+	dealiased := make([]float32, len(velocity))
+
+	if nyquist <= 0 {
+		copy(dealiased, velocity)
+		return dealiased
+	}
+
+	interval := 2 * float64(nyquist)
+	haveReference := false
+	reference := 0.0
+	gap := 0
+
+	for i, v := range velocity {
+		if v == MomentDataBelowThreshold || v == MomentDataFolded {
+			dealiased[i] = v
+			gap++
+
+			if gap > dealiasMaxGap {
+				haveReference = false
+			}
+
+			continue
+		}
+
+		gap = 0
+		value := float64(v)
+
+		if haveReference {
+			value -= interval * math.Round((value-reference)/interval)
+		}
+
+		dealiased[i] = float32(value)
+		reference = value
+		haveReference = true
+	}
+
+	return dealiased
+}
+
+// StormRelativeVelocity derives SRV in m/s from scaled radial velocity gates
+// of a radial at azimuth degrees, subtracting the component along the radial
+// of a storm moving from direction degrees at speed m/s, as winds are given.
+// Velocities are positive away from the radar.
+func StormRelativeVelocity(velocity []float32, azimuth float32, direction float32, speed float32) []float32 {
+	srv := make([]float32, len(velocity))
+
+	// the storm moves toward the opposite of the direction it comes from
+	toward := (float64(direction) + 180) * math.Pi / 180
+	component := float32(float64(speed) * math.Cos(float64(azimuth)*math.Pi/180-toward))
+
+	for i, v := range velocity {
+		if v == MomentDataBelowThreshold || v == MomentDataFolded {
+			srv[i] = v
+			continue
+		}
+
+		srv[i] = v - component
+	}
+
+	return srv
+}
@@ -0,0 +1,164 @@
+package archive2
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSpecificDifferentialPhase(t *testing.T) {
+	// a ramp of 5 degrees per 250 m gate is a KDP of 10 deg/km, starting at
+	// 340 degrees so it wraps past 360 at the fifth gate
+	ramp := make([]float32, 16)
+	wrapped := make([]float32, len(ramp))
+
+	for i := range ramp {
+		ramp[i] = 340 + 5*float32(i)
+		wrapped[i] = float32(math.Mod(float64(ramp[i]), 360))
+	}
+
+	// a gap in the ramp is passed over by the fit
+	ramp[8], wrapped[8] = MomentDataBelowThreshold, MomentDataBelowThreshold
+
+	for name, phi := range map[string][]float32{"linear": ramp, "wrapped": wrapped} {
+		kdp := SpecificDifferentialPhase(phi, 250)
+
+		for i := range kdp {
+			expected := float32(10)
+
+			if i == 8 {
+				expected = MomentDataBelowThreshold
+			}
+
+			if math.Abs(float64(kdp[i]-expected)) > 1e-3 {
+				t.Errorf("%s gate %d: expected %v, got %v", name, i, expected, kdp[i])
+			}
+		}
+	}
+}
+
+func TestReflectivityGradient(t *testing.T) {
+	ref := []float32{10, 12, 17, MomentDataBelowThreshold, 20, 15, MomentDataFolded, 30}
+
+	// 250 m gates
+	gradient := ReflectivityGradient(ref, 250)
+
+	expected := []float32{
+		MomentDataBelowThreshold,
+		8,
+		20,
+		MomentDataBelowThreshold,
+		// no valid gate before it
+		MomentDataBelowThreshold,
+		-20,
+		MomentDataFolded,
+		MomentDataBelowThreshold,
+	}
+
+	for i := range expected {
+		if gradient[i] != expected[i] {
+			t.Errorf("gate %d: expected %v, got %v", i, expected[i], gradient[i])
+		}
+	}
+}
+
+func TestDealias(t *testing.T) {
+	// gates either side of a gap of n missing gates
+	gap := func(before float32, n int, after float32) []float32 {
+		gates := []float32{before}
+
+		for i := 0; i < n; i++ {
+			gates = append(gates, MomentDataBelowThreshold)
+		}
+
+		return append(gates, after)
+	}
+
+	tests := []struct {
+		name     string
+		velocity []float32
+		nyquist  float32
+		expected []float32
+	}{
+		{
+			"aliased run unfolds by twice the nyquist",
+			[]float32{5, 8, -9, -7, 9, MomentDataFolded, 4},
+			10,
+			[]float32{5, 8, 11, 13, 9, MomentDataFolded, 4},
+		},
+		{
+			"negative aliasing unfolds down",
+			[]float32{-6, -9, 8, 6},
+			10,
+			[]float32{-6, -9, -12, -14},
+		},
+		{
+			"short gap keeps the reference",
+			gap(8, dealiasMaxGap, -9),
+			10,
+			gap(8, dealiasMaxGap, 11),
+		},
+		{
+			"long gap resets the reference",
+			gap(8, dealiasMaxGap+1, -9),
+			10,
+			gap(8, dealiasMaxGap+1, -9),
+		},
+		{
+			"zero nyquist is unchanged",
+			[]float32{5, 8, -9, MomentDataBelowThreshold},
+			0,
+			[]float32{5, 8, -9, MomentDataBelowThreshold},
+		},
+		{
+			"negative nyquist is unchanged",
+			[]float32{5, -9},
+			-10,
+			[]float32{5, -9},
+		},
+	}
+
+	for _, test := range tests {
+		dealiased := Dealias(test.velocity, test.nyquist)
+
+		if len(dealiased) != len(test.expected) {
+			t.Fatalf("%s: expected %d gates, got %d", test.name, len(test.expected), len(dealiased))
+		}
+
+		for i := range test.expected {
+			if math.Abs(float64(dealiased[i]-test.expected[i])) > 1e-4 {
+				t.Errorf("%s gate %d: expected %v, got %v", test.name, i, test.expected[i], dealiased[i])
+			}
+		}
+	}
+
+	// the input is left as is
+	velocity := []float32{5, -9}
+	Dealias(velocity, 10)
+
+	if velocity[1] != -9 {
+		t.Errorf("expected the input to be unchanged, got %v", velocity)
+	}
+}
+
+func TestStormRelativeVelocity(t *testing.T) {
+	velocity := []float32{10, -10, MomentDataFolded, MomentDataBelowThreshold}
+
+	// a storm from the west at 20 m/s moves east, away from the radar along
+	// a radial toward the east, toward it along one toward the west, and
+	// across one toward the north
+	cases := map[float32][]float32{
+		90:  {-10, -30, MomentDataFolded, MomentDataBelowThreshold},
+		270: {30, 10, MomentDataFolded, MomentDataBelowThreshold},
+		0:   {10, -10, MomentDataFolded, MomentDataBelowThreshold},
+	}
+
+	for azimuth, expected := range cases {
+		srv := StormRelativeVelocity(velocity, azimuth, 270, 20)
+
+		for i := range expected {
+			if math.Abs(float64(srv[i]-expected[i])) > 1e-4 {
+				t.Errorf("azimuth %v gate %d: expected %v, got %v", azimuth, i, expected[i], srv[i])
+			}
+		}
+	}
+}
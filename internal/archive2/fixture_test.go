@@ -0,0 +1,282 @@
+package archive2
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/binary"
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+var update = flag.Bool("update", false, "regenerate testdata/fixture.ar2")
+
+const fixturePath = "testdata/fixture.ar2"
+
+// fixtureGates are the raw reflectivity gates of every fixture radial: below
+// threshold, range folded, then 5, 10, 20 and 40 dBZ.
+var fixtureGates = []byte{0, 1, 76, 86, 106, 146}
+
+// writeFixture writes a tiny uncompressed archive of one elevation with four
+// radials of reflectivity, laid out as described in the RDA/RPG ICD.
+func writeFixture(path string) error {
+	var b bytes.Buffer
+
+	header := VolumeHeaderRecord{X_ModifiedJulianDate: 19000, X_ModifiedTime: 3600000}
+	copy(header.X_FileName[:], "AR2V0006.001")
+	copy(header.ICAO[:], "KFTG")
+	binary.Write(&b, binary.BigEndian, header)
+
+	var messages bytes.Buffer
+
+	for i := 0; i < 4; i++ {
+		messages.Write(make([]byte, LegacyCTMHeaderLen))
+		binary.Write(&messages, binary.BigEndian, MessageHeader{MessageType: 31, JulianDate: 19000})
+
+		m31h := Message31Header{
+			CollectionTime:               3600000,
+			CollectionDate:               19000,
+			AzimuthNumber:                uint16(i + 1),
+			AzimuthAngle:                 float32(i) * 90,
+			AzimuthResolutionSpacingCode: 2,
+			ElevationNumber:              1,
+			ElevationAngle:               0.5,
+			DataBlockCount:               4,
+		}
+
+		switch i {
+		case 0:
+			m31h.RadialStatus = radialStatusBeginningOfVolumeScan
+		case 3:
+			m31h.RadialStatus = radialStatusEndOfVolumeScan
+		default:
+			m31h.RadialStatus = radialStatusIntermediateRadialData
+		}
+		copy(m31h.RadarIdentifier[:], "KFTG")
+
+		vol := VolumeData{LRTUP: 44, VersionMajor: 1, Lat: 39.7866, Lon: -104.5458, VolumeCoveragePatternNumber: 212}
+		copy(vol.DataBlockType[:], "R")
+		copy(vol.DataName[:], "VOL")
+
+		elv := ElevationData{LRTUP: 12}
+		copy(elv.DataBlockType[:], "R")
+		copy(elv.DataName[:], "ELV")
+
+		rad := RadialData{LRTUP: 28, UnambiguousRange: 4660, NyquistVelocity: 2660}
+		copy(rad.DataBlockType[:], "R")
+		copy(rad.DataName[:], "RAD")
+
+		ref := GenericDataMoment{
+			NumberDataMomentGates:         uint16(len(fixtureGates)),
+			DataMomentRange:               2125,
+			DataMomentRangeSampleInterval: 250,
+			DataWordSize:                  8,
+			Scale:                         2,
+			Offset:                        66,
+		}
+		copy(ref.DataBlockType[:], "D")
+		copy(ref.DataName[:], "REF")
+
+		headerLen := uint32(binary.Size(m31h)) + 4*uint32(m31h.DataBlockCount)
+		volPtr := headerLen
+		elvPtr := volPtr + uint32(binary.Size(vol))
+		radPtr := elvPtr + uint32(binary.Size(elv))
+		refPtr := radPtr + uint32(binary.Size(rad))
+
+		m31h.RadialLength = uint16(refPtr) + uint16(binary.Size(ref)) + uint16(len(fixtureGates))
+
+		binary.Write(&messages, binary.BigEndian, m31h)
+		binary.Write(&messages, binary.BigEndian, []uint32{volPtr, elvPtr, radPtr, refPtr})
+		binary.Write(&messages, binary.BigEndian, vol)
+		binary.Write(&messages, binary.BigEndian, elv)
+		binary.Write(&messages, binary.BigEndian, rad)
+		binary.Write(&messages, binary.BigEndian, ref)
+		messages.Write(fixtureGates)
+	}
+
+	// a single uncompressed LDM record holds every message
+	binary.Write(&b, binary.BigEndian, int32(messages.Len()))
+	b.Write(messages.Bytes())
+
+	if err := os.MkdirAll("testdata", 0755); err != nil {
+		return err
+	}
+
+	return os.WriteFile(path, b.Bytes(), 0644)
+}
+
+func TestExtractFixture(t *testing.T) {
+	if *update {
+		if err := writeFixture(fixturePath); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := os.Open(fixturePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ar2 := Extract(f)
+
+	if len(ar2.ElevationScans) != 1 || len(ar2.ElevationScans[1]) != 4 {
+		t.Fatalf("expected 1 elevation of 4 radials, got %v", ar2.Elevations())
+	}
+
+	if icao := string(ar2.VolumeHeader.ICAO[:]); icao != "KFTG" {
+		t.Errorf("expected station KFTG, got %s", icao)
+	}
+
+	lat, lon, err := ar2.RadarLocation()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lat != 39.7866 || lon != -104.5458 {
+		t.Errorf("expected location 39.7866, -104.5458, got %v, %v", lat, lon)
+	}
+
+	for i, radial := range ar2.ElevationScans[1] {
+		if radial.Header.AzimuthAngle != float32(i)*90 {
+			t.Errorf("radial %d: expected azimuth %v, got %v", i, float32(i)*90, radial.Header.AzimuthAngle)
+		}
+
+		gates, err := radial.ScaledDataForProduct("REF")
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected := []float32{MomentDataBelowThreshold, MomentDataFolded, 5, 10, 20, 40}
+
+		for j := range expected {
+			if (*gates)[j] != expected[j] {
+				t.Errorf("radial %d gate %d: expected %v, got %v", i, j, expected[j], (*gates)[j])
+			}
+		}
+	}
+}
+
+func TestRawDataForProduct(t *testing.T) {
+	f, err := os.Open(fixturePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	radial := Extract(f).ElevationScans[1][0]
+
+	gates, flags, err := radial.RawDataForProduct("REF")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// levels of 5 to 40 dBZ at a scale of 2 and offset of 66
+	expected := []float32{0, 1, 76, 86, 106, 146}
+	expectedFlags := []GateFlag{GateBelowThreshold, GateFolded, GateValid, GateValid, GateValid, GateValid}
+
+	for j := range expected {
+		if (*gates)[j] != expected[j] {
+			t.Errorf("gate %d: expected %v, got %v", j, expected[j], (*gates)[j])
+		}
+
+		if flags[j] != expectedFlags[j] {
+			t.Errorf("gate %d: expected flag %v, got %v", j, expectedFlags[j], flags[j])
+		}
+	}
+
+	if _, _, err := radial.RawDataForProduct("REFGRAD"); err == nil {
+		t.Errorf("expected an error for a derived product")
+	}
+
+	if _, _, err := radial.RawDataForProduct("VEL"); err == nil {
+		t.Errorf("expected an error for a missing moment")
+	}
+}
+
+func TestRead(t *testing.T) {
+	data, err := os.ReadFile(fixturePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Read(bytes.NewReader(data)); err != nil {
+		t.Fatalf("expected the fixture to be valid, got %s", err)
+	}
+
+	// cut within the volume header, a message header, a radial, and after
+	// the third of four radials
+	radialLen := (len(data) - 28) / 4
+
+	for _, n := range []int{0, 10, 40, 100, 28 + 3*radialLen} {
+		if _, err := Read(bytes.NewReader(data[:n])); err == nil {
+			t.Errorf("expected an error for the fixture truncated to %d bytes", n)
+		}
+	}
+
+	// an unknown data block is an error returned from the radial, rather
+	// than a panic
+	corrupt := bytes.Replace(data, []byte("RRAD"), []byte("RQQQ"), 1)
+
+	if _, err := Read(bytes.NewReader(corrupt)); err == nil || !strings.Contains(err.Error(), "unknown type 'QQQ'") {
+		t.Errorf("expected an error for an unknown data block, got %v", err)
+	}
+
+	// an elevation without radials after the end of the volume is passed over
+	ar2, _ := Read(bytes.NewReader(data))
+	ar2.ElevationScans[2] = []*Message31{}
+
+	if err := ar2.Validate(); err != nil {
+		t.Errorf("expected an empty elevation to be passed over, got %s", err)
+	}
+}
+
+func TestReadVolumeHeader(t *testing.T) {
+	data, err := os.ReadFile(fixturePath)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var gzipped bytes.Buffer
+
+	gz := gzip.NewWriter(&gzipped)
+	gz.Write(data)
+	gz.Close()
+
+	expected := time.Date(2022, 1, 7, 1, 0, 0, 0, time.UTC)
+
+	for name, file := range map[string][]byte{"plain": data, "gzip": gzipped.Bytes()} {
+		header, err := ReadVolumeHeader(bytes.NewReader(file))
+
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+
+		if !header.Date().Equal(expected) {
+			t.Errorf("%s: expected %v, got %v", name, expected, header.Date())
+		}
+
+		if !header.IsArchive2() {
+			t.Errorf("%s: expected an Archive II header, got %q", name, header.FileName())
+		}
+	}
+
+	if header, err := ReadVolumeHeader(strings.NewReader("not an archive, only long enough")); err != nil || header.IsArchive2() {
+		t.Errorf("expected a header that isn't Archive II, got %q and %v", header.FileName(), err)
+	}
+
+	if _, err := ReadVolumeHeader(bytes.NewReader(data[:10])); err == nil {
+		t.Error("expected an error for a truncated volume header")
+	}
+}
@@ -0,0 +1,191 @@
+package archive2
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+)
+
+// Message1 Digital Radar Data (legacy)
+//
+// Description:
+// The pre Build 10 radial format, superseded by Message 31 in 2008. Each
+// message is a fixed 2432 bytes holding reflectivity at 1 km and velocity and
+// spectrum width at 250 m, with no dual polarization moments and no radar
+// location.
+type Message1 struct {
+	// CollectionTime Radial data collection time in milliseconds past midnight GMT
+	CollectionTime uint32
+	// CollectionDate Current Julian date - 2440586.5
+	CollectionDate uint16
+	// UnambiguousRange Unambiguous range, scaled by 10 (km)
+	UnambiguousRange uint16
+	// AzimuthAngle Coded azimuth angle, in units of 180/32768 degrees
+	AzimuthAngle uint16
+	// AzimuthNumber Radial number within elevation scan
+	AzimuthNumber uint16
+	// RadialStatus Radial Status
+	RadialStatus uint16
+	// ElevationAngle Coded elevation angle, in units of 180/32768 degrees
+	ElevationAngle uint16
+	// ElevationNumber Elevation number within volume scan
+	ElevationNumber uint16
+	// SurveillanceRange Range to center of first surveillance gate in meters
+	SurveillanceRange int16
+	// DopplerRange Range to center of first Doppler gate in meters
+	DopplerRange int16
+	// SurveillanceInterval Size of surveillance sample interval in meters
+	SurveillanceInterval uint16
+	// DopplerInterval Size of Doppler sample interval in meters
+	DopplerInterval uint16
+	// SurveillanceGates Number of surveillance gates
+	SurveillanceGates uint16
+	// DopplerGates Number of Doppler gates
+	DopplerGates uint16
+	// CutSectorNumber Sector Number within cut
+	CutSectorNumber uint16
+	// CalibrationConstant System gain calibration constant (dB biased)
+	CalibrationConstant float32
+	// SurveillancePointer Byte offset from the start of this header to the reflectivity data, 0 if absent
+	SurveillancePointer uint16
+	// VelocityPointer Byte offset from the start of this header to the velocity data, 0 if absent
+	VelocityPointer uint16
+	// SpectrumWidthPointer Byte offset from the start of this header to the spectrum width data, 0 if absent
+	SpectrumWidthPointer uint16
+	// VelocityResolution Doppler velocity resolution. 2 = 0.5 m/s, 4 = 1 m/s
+	VelocityResolution uint16
+	// VolumeCoveragePattern Volume coverage pattern number
+	VolumeCoveragePattern uint16
+	Spare                 [14]byte
+	// NyquistVelocity Nyquist velocity, scaled by 100 (m/s)
+	NyquistVelocity uint16
+	// AtmosphericAttenuation Atmospheric attenuation factor, scaled by 1000 (dB/km)
+	AtmosphericAttenuation int16
+	// TOVER Threshold parameter, scaled by 10 (dB)
+	TOVER int16
+	// SpotBlankingStatus Spot blanking status for current radial, elevation scan and volume scan
+	SpotBlankingStatus uint16
+	Spare2             [32]byte
+}
+
+// codedAngle converts a Message 1 coded angle to degrees.
+func codedAngle(code uint16) float32 {
+	return float32(float64(code) * 180 / 32768)
+}
+
+// msg1 reads a legacy Message 1 radial and converts it to a Message 31, so
+// the rest of the pipeline handles both formats alike. The 8 bit gates use
+// the same encoding as Message 31, N = 0 below threshold, N = 1 range folded,
+// so only the scale and offset need filling in.
+func msg1(r io.ReadSeeker, icao [4]byte) (*Message31, error) {
+	m1 := Message1{}
+
+	// pointers are relative to the first byte of the header
+	startPos, _ := r.Seek(0, io.SeekCurrent)
+
+	if err := binary.Read(r, binary.BigEndian, &m1); err != nil {
+		return nil, err
+	}
+
+	m31 := Message31{
+		Header: Message31Header{
+			RadarIdentifier: icao,
+			CollectionTime:  m1.CollectionTime,
+			CollectionDate:  m1.CollectionDate,
+			AzimuthNumber:   m1.AzimuthNumber,
+			AzimuthAngle:    codedAngle(m1.AzimuthAngle),
+			// legacy radials are always 1 degree apart
+			AzimuthResolutionSpacingCode: 2,
+			RadialStatus:                 uint8(m1.RadialStatus),
+			ElevationNumber:              uint8(m1.ElevationNumber),
+			CutSectorNumber:              uint8(m1.CutSectorNumber),
+			ElevationAngle:               codedAngle(m1.ElevationAngle),
+			RadialSpotBlankingStatus:     uint8(m1.SpotBlankingStatus),
+		},
+		VolumeData: VolumeData{
+			CalibrationConstant:         m1.CalibrationConstant,
+			VolumeCoveragePatternNumber: m1.VolumeCoveragePattern,
+		},
+		RadialData: RadialData{
+			UnambiguousRange: m1.UnambiguousRange,
+			NyquistVelocity:  m1.NyquistVelocity,
+		},
+		Legacy: true,
+	}
+
+	// F = (N - OFFSET) / SCALE, from the legacy encodings
+	// REF = (N - 2) / 2 - 32, VEL and SW = (N - 2) / 2 - 63.5 at 0.5 m/s
+	// resolution, or VEL = (N - 2) - 127 at 1 m/s
+	velocityScale := float32(2)
+
+	if m1.VelocityResolution == 4 {
+		velocityScale = 1
+	}
+
+	var err error
+
+	if m31.ReflectivityData, err = legacyMoment(r, startPos, "REF", m1.SurveillancePointer, m1.SurveillanceGates, m1.SurveillanceRange, m1.SurveillanceInterval, m1.TOVER, 2, 66); err != nil {
+		return nil, err
+	}
+
+	if m31.VelocityData, err = legacyMoment(r, startPos, "VEL", m1.VelocityPointer, m1.DopplerGates, m1.DopplerRange, m1.DopplerInterval, m1.TOVER, velocityScale, 129); err != nil {
+		return nil, err
+	}
+
+	if m31.SwData, err = legacyMoment(r, startPos, "SW ", m1.SpectrumWidthPointer, m1.DopplerGates, m1.DopplerRange, m1.DopplerInterval, m1.TOVER, 2, 129); err != nil {
+		return nil, err
+	}
+
+	// move to the end of the message
+	r.Seek(startPos+MessageBodySize, io.SeekStart)
+
+	return &m31, nil
+}
+
+// legacyMoment reads the gates of one Message 1 moment, or returns nil if the
+// radial doesn't carry it. Gates centered behind the radar, which the first
+// Doppler gate can be, are dropped as a data moment's range is unsigned.
+func legacyMoment(r io.ReadSeeker, startPos int64, name string, pointer uint16, gates uint16, firstRange int16, interval uint16, tover int16, scale float32, offset float32) (*DataMoment, error) {
+	if pointer == 0 || gates == 0 {
+		return nil, nil
+	}
+
+	if int(pointer)+int(gates) > MessageBodySize {
+		return nil, fmt.Errorf("Message 1: %s data overruns the message", name)
+	}
+
+	data := make([]byte, gates)
+
+	r.Seek(startPos+int64(pointer), io.SeekStart)
+
+	if err := binary.Read(r, binary.BigEndian, data); err != nil {
+		return nil, err
+	}
+
+	skip := 0
+
+	if firstRange < 0 && interval > 0 {
+		skip = (-int(firstRange) + int(interval) - 1) / int(interval)
+	}
+
+	if skip >= len(data) {
+		return nil, nil
+	}
+
+	m := GenericDataMoment{
+		NumberDataMomentGates:         gates - uint16(skip),
+		DataMomentRange:               uint16(int(firstRange) + skip*int(interval)),
+		DataMomentRangeSampleInterval: interval,
+		TOVER:                         uint16(tover),
+		DataWordSize:                  8,
+		Scale:                         scale,
+		Offset:                        offset,
+	}
+	copy(m.DataBlockType[:], "D")
+	copy(m.DataName[:], name)
+
+	return &DataMoment{
+		GenericDataMoment: m,
+		Data:              data[skip:],
+	}, nil
+}
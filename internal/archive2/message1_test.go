@@ -0,0 +1,157 @@
+package archive2
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// legacyArchive returns an uncompressed pre LDM archive of one elevation,
+// with a radial of Message 1 per azimuth.
+func legacyArchive(azimuths []float32) []byte {
+	var b bytes.Buffer
+
+	header := VolumeHeaderRecord{X_ModifiedJulianDate: 12000, X_ModifiedTime: 3600000}
+	copy(header.X_FileName[:], "ARCHIVE2.001")
+	copy(header.ICAO[:], "KFTG")
+	binary.Write(&b, binary.BigEndian, header)
+
+	for i, azimuth := range azimuths {
+		b.Write(make([]byte, LegacyCTMHeaderLen))
+		binary.Write(&b, binary.BigEndian, MessageHeader{MessageType: 1, JulianDate: 12000})
+
+		m1 := Message1{
+			CollectionTime:        3600000,
+			CollectionDate:        12000,
+			UnambiguousRange:      1150,
+			AzimuthAngle:          uint16(azimuth * 32768 / 180),
+			AzimuthNumber:         uint16(i + 1),
+			ElevationAngle:        91, // about 0.5 degrees
+			ElevationNumber:       1,
+			SurveillanceRange:     500,
+			DopplerRange:          -375,
+			SurveillanceInterval:  1000,
+			DopplerInterval:       250,
+			SurveillanceGates:     4,
+			DopplerGates:          4,
+			SurveillancePointer:   100,
+			VelocityPointer:       200,
+			SpectrumWidthPointer:  300,
+			VelocityResolution:    4,
+			VolumeCoveragePattern: 21,
+			NyquistVelocity:       2650,
+		}
+
+		body := make([]byte, MessageBodySize)
+
+		var h bytes.Buffer
+		binary.Write(&h, binary.BigEndian, m1)
+		copy(body, h.Bytes())
+		copy(body[100:], []byte{0, 1, 66, 146})
+		copy(body[200:], []byte{0, 1, 129, 139})
+		copy(body[300:], []byte{0, 1, 131, 149})
+
+		b.Write(body)
+	}
+
+	return b.Bytes()
+}
+
+func TestMessage1Size(t *testing.T) {
+	if size := binary.Size(Message1{}); size != 100 {
+		t.Errorf("expected a 100 byte header, got %d", size)
+	}
+}
+
+func TestExtractLegacy(t *testing.T) {
+	ar2 := Extract(bytes.NewReader(legacyArchive([]float32{0, 90, 180, 270})))
+
+	if len(ar2.ElevationScans) != 1 || len(ar2.ElevationScans[1]) != 4 {
+		t.Fatalf("expected 1 elevation of 4 radials, got %v", ar2.Elevations())
+	}
+
+	radial := ar2.ElevationScans[1][1]
+
+	if a := radial.Header.AzimuthAngle; a < 89.99 || a > 90.01 {
+		t.Errorf("expected azimuth 90, got %v", a)
+	}
+
+	if a := radial.Header.ElevationAngle; a < 0.49 || a > 0.51 {
+		t.Errorf("expected elevation angle 0.5, got %v", a)
+	}
+
+	if station := string(radial.Header.RadarIdentifier[:]); station != "KFTG" {
+		t.Errorf("expected station KFTG, got %v", station)
+	}
+
+	if vcp := radial.VolumeData.VolumeCoveragePatternNumber; vcp != 21 {
+		t.Errorf("expected VCP 21, got %v", vcp)
+	}
+
+	if nyquist := radial.RadialData.Nyquist(); nyquist != 26.5 {
+		t.Errorf("expected Nyquist velocity 26.5, got %v", nyquist)
+	}
+
+	tests := []struct {
+		product    string
+		firstRange uint16
+		expected   []float32
+	}{
+		{"REF", 500, []float32{MomentDataBelowThreshold, MomentDataFolded, 0, 40}},
+		// the two gates behind the radar are dropped
+		{"VEL", 125, []float32{0, 10}},
+		{"SW", 125, []float32{1, 10}},
+	}
+
+	for _, test := range tests {
+		moment, err := radial.DataMomentForProduct(test.product)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if moment.DataMomentRange != test.firstRange {
+			t.Errorf("%v: expected first gate at %v m, got %v", test.product, test.firstRange, moment.DataMomentRange)
+		}
+
+		gates, err := radial.ScaledDataForProduct(test.product)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if len(*gates) != len(test.expected) {
+			t.Fatalf("%v: expected %v, got %v", test.product, test.expected, *gates)
+		}
+
+		for i := range test.expected {
+			if (*gates)[i] != test.expected[i] {
+				t.Errorf("%v gate %d: expected %v, got %v", test.product, i, test.expected[i], (*gates)[i])
+			}
+		}
+	}
+
+	if _, err := radial.DataMomentForProduct("RHO"); err == nil {
+		t.Error("expected no RHO in a legacy radial")
+	}
+}
+
+func TestLegacyRadarLocation(t *testing.T) {
+	ar2 := Extract(bytes.NewReader(legacyArchive([]float32{0, 90})))
+
+	if _, _, err := ar2.RadarLocation(); err == nil {
+		t.Error("expected an error before the location is set")
+	}
+
+	ar2.SetRadarLocation(39.7866, -104.5458)
+
+	lat, lon, err := ar2.RadarLocation()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lat != 39.7866 || lon != -104.5458 {
+		t.Errorf("expected location 39.7866, -104.5458, got %v, %v", lat, lon)
+	}
+}
@@ -3,11 +3,10 @@ package archive2
 import (
 	"bytes"
 	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"time"
-
-	"github.com/sirupsen/logrus"
 )
 
 // Message31 Digital Radar Data Generic Format
@@ -31,19 +30,27 @@ type Message31 struct {
 	PhiData          *DataMoment // PhiData (Differential Phase Shift)
 	RhoData          *DataMoment // RhoData (Correlation Coefficient)
 	CfpData          *DataMoment // CfpData (Clutter Filter Power Removed)
+	// Legacy is set for radials converted from Message 1, which carry no
+	// radar location
+	Legacy bool
 }
 
-func (m *Message31) ScaledDataForProduct(product string) (*[]float32, error) {
+// DataMomentForProduct returns the data moment block for the given product,
+// or the block it is derived from.
+func (m *Message31) DataMomentForProduct(product string) (*DataMoment, error) {
 	var moment *DataMoment
 
 	switch product {
-	case "REF":
+	case "REF", "REFGRAD":
+		// REFGRAD is derived from REF and shares its gates
 		moment = m.ReflectivityData
-	case "VEL":
+	case "VEL", "SRV":
+		// SRV is derived from VEL and shares its gates
 		moment = m.VelocityData
 	case "SW":
 		moment = m.SwData
-	case "PHI":
+	case "PHI", "KDP":
+		// KDP is derived from PHI and shares its gates
 		moment = m.PhiData
 	case "RHO":
 		moment = m.RhoData
@@ -56,14 +63,97 @@ func (m *Message31) ScaledDataForProduct(product string) (*[]float32, error) {
 	}
 
 	if moment == nil {
-		return nil, fmt.Errorf("nil data moment for %s", product)
+		return nil, &MissingMomentError{Product: product}
+	}
+
+	return moment, nil
+}
+
+// MissingMomentError is returned for a radial without the data moment of a
+// product, e.g. VEL in a surveillance cut.
+type MissingMomentError struct {
+	Product string
+}
+
+func (e *MissingMomentError) Error() string {
+	return fmt.Sprintf("nil data moment for %s", e.Product)
+}
+
+// HasMoment returns false if the radial lacks the data moment of the product,
+// or the moment it is derived from. Unknown products are left to
+// DataMomentForProduct to report.
+func (m *Message31) HasMoment(product string) bool {
+	_, err := m.DataMomentForProduct(product)
+	_, missing := err.(*MissingMomentError)
+
+	return !missing
+}
+
+// ProductUnit returns the unit of measure for the scaled values of a product.
+func ProductUnit(product string) string {
+	switch product {
+	case "REF":
+		return "dBZ"
+	case "VEL", "SRV", "SW":
+		return "m/s"
+	case "ZDR", "CFP":
+		return "dB"
+	case "PHI":
+		return "deg"
+	case "KDP":
+		return "deg/km"
+	case "REFGRAD":
+		return "dBZ/km"
+	}
+
+	// RHO is a unitless ratio
+	return ""
+}
+
+// ScaledDataForProduct returns the physical value of the product's gates, see
+// DataMoment.ScaledData. Velocities keep the sign of the moment, negative
+// toward the radar.
+func (m *Message31) ScaledDataForProduct(product string) (*[]float32, error) {
+	moment, err := m.DataMomentForProduct(product)
+
+	if err != nil {
+		return nil, err
 	}
 
 	gates := moment.ScaledData()
 
+	// SRV is left as VEL, as subtracting the storm motion needs the motion,
+	// see StormRelativeVelocity
+	switch product {
+	case "KDP":
+		gates = SpecificDifferentialPhase(gates, float64(moment.DataMomentRangeSampleInterval))
+	case "REFGRAD":
+		gates = ReflectivityGradient(gates, float64(moment.DataMomentRangeSampleInterval))
+	}
+
 	return &gates, nil
 }
 
+// RawDataForProduct returns the unscaled data levels of the product's gates
+// and their flags, see DataMoment.RawData. Derived products are computed from
+// scaled values, so have no levels.
+func (m *Message31) RawDataForProduct(product string) (*[]float32, []GateFlag, error) {
+	switch product {
+	case "KDP", "REFGRAD", "SRV":
+		return nil, nil, fmt.Errorf("%s is derived, with no raw data levels", product)
+	}
+
+	moment, err := m.DataMomentForProduct(product)
+
+	if err != nil {
+		return nil, nil, err
+	}
+
+	gates, flags := moment.RawData()
+
+	return &gates, flags, nil
+}
+
 func (h Message31Header) String() string {
 	return fmt.Sprintf("Message 31 - %s @ %v deg=%.2f tilt=%.2f",
 		string(h.RadarIdentifier[:]),
@@ -75,9 +165,8 @@ func (h Message31Header) String() string {
 
 // Date and time this data is valid for
 func (h Message31Header) Date() time.Time {
-	return time.Date(1970, time.January, 1, 0, 0, 0, 0, time.UTC).
-		Add(time.Duration(h.CollectionDate) * time.Hour * 24).
-		Add(time.Duration(h.CollectionTime) * time.Millisecond)
+	// CollectionDate is a modified Julian date where 1970/1/1 = 1
+	return timeFromModifiedJulian(int(h.CollectionDate), int(h.CollectionTime))
 }
 
 // Message31Header contains header information for an Archive 2 Message 31 type
@@ -126,7 +215,7 @@ func (h *Message31Header) AzimuthResolutionSpacing() float64 {
 	return 1
 }
 
-func msg31(r io.ReadSeeker) *Message31 {
+func msg31(r io.ReadSeeker) (*Message31, error) {
 	m31h := Message31Header{}
 
 	// save the position of the first byte so we can easily process data blocks later.
@@ -150,7 +239,7 @@ func msg31(r io.ReadSeeker) *Message31 {
 
 	blockPointers := make([]uint32, m31h.DataBlockCount)
 	if err := binary.Read(r, binary.BigEndian, blockPointers); err != nil {
-		logrus.Panic(err.Error())
+		return nil, err
 	}
 
 	// check for more DataBlockPointers
@@ -161,7 +250,7 @@ func msg31(r io.ReadSeeker) *Message31 {
 	maxLoops := 20
 	for i := 0; true; i++ {
 		if err := binary.Read(r, binary.BigEndian, &lookahead); err != nil {
-			logrus.Panic(err.Error())
+			return nil, err
 		}
 
 		if bytes.Equal(lookahead, hexRVOL) {
@@ -177,7 +266,7 @@ func msg31(r io.ReadSeeker) *Message31 {
 
 		// prevent infinite loop
 		if i == maxLoops {
-			logrus.Fatal("M31 Header: failed to find the end of the datablock pointers.")
+			return nil, errors.New("M31 Header: failed to find the end of the datablock pointers")
 		}
 		i++
 	}
@@ -190,7 +279,7 @@ func msg31(r io.ReadSeeker) *Message31 {
 
 		d := DataBlock{}
 		if err := binary.Read(r, binary.BigEndian, &d); err != nil {
-			logrus.Panic(err.Error())
+			return nil, err
 		}
 
 		// rewind from reading the datalblock
@@ -240,7 +329,7 @@ func msg31(r io.ReadSeeker) *Message31 {
 				m31.ReflectivityData = d
 			case "VEL":
 				m31.VelocityData = d
-			case "SW":
+			case "SW ":
 				m31.SwData = d
 			case "ZDR":
 				m31.ZdrData = d
@@ -253,8 +342,8 @@ func msg31(r io.ReadSeeker) *Message31 {
 			}
 		default:
 			// preview(r, 256)
-			logrus.Panicf("Data Block - unknown type '%s'", blockName)
+			return nil, fmt.Errorf("Data Block - unknown type '%s'", blockName)
 		}
 	}
-	return &m31
+	return &m31, nil
 }
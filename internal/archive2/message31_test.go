@@ -0,0 +1,59 @@
+package archive2
+
+import "testing"
+
+func TestScaledDataVelocitySign(t *testing.T) {
+	// levels of -10 and 10 m/s at the 0.5 m/s resolution's scale of 2 and
+	// offset of 129
+	moment := &DataMoment{
+		GenericDataMoment: GenericDataMoment{NumberDataMomentGates: 3, DataWordSize: 8, Scale: 2, Offset: 129},
+		Data:              []byte{109, 129, 149},
+	}
+
+	radial := &Message31{VelocityData: moment}
+
+	gates, err := radial.ScaledDataForProduct("VEL")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// an inbound gate, moving toward the radar, stays negative
+	expected := []float32{-10, 0, 10}
+
+	for i := range expected {
+		if (*gates)[i] != expected[i] {
+			t.Errorf("gate %d: expected %v, got %v", i, expected[i], (*gates)[i])
+		}
+	}
+}
+
+func TestRawDataSixteenBitLevels(t *testing.T) {
+	// 16 bit PHI levels of 0, 1, 998, 999 and 1023
+	moment := &DataMoment{
+		GenericDataMoment: GenericDataMoment{NumberDataMomentGates: 5, DataWordSize: 16, Scale: 2.8361, Offset: 2},
+		Data:              []byte{0, 0, 0, 1, 0x03, 0xe6, 0x03, 0xe7, 0x03, 0xff},
+	}
+
+	radial := &Message31{PhiData: moment}
+
+	gates, flags, err := radial.RawDataForProduct("PHI")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// levels as high as the sentinels of scaled values stay valid
+	expected := []float32{0, 1, 998, 999, 1023}
+	expectedFlags := []GateFlag{GateBelowThreshold, GateFolded, GateValid, GateValid, GateValid}
+
+	for i := range expected {
+		if (*gates)[i] != expected[i] {
+			t.Errorf("gate %d: expected %v, got %v", i, expected[i], (*gates)[i])
+		}
+
+		if flags[i] != expectedFlags[i] {
+			t.Errorf("gate %d: expected flag %v, got %v", i, expectedFlags[i], flags[i])
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"strings"
 	"time"
 )
 
@@ -72,6 +73,14 @@ func (vh VolumeHeaderRecord) FileName() string {
 	return string(vh.X_FileName[:])
 }
 
+// IsArchive2 returns true if the header's filename is that of an Archive II
+// volume, AR2V followed by the version, or ARCHIVE2 for legacy volumes.
+func (vh VolumeHeaderRecord) IsArchive2() bool {
+	name := vh.FileName()
+
+	return strings.HasPrefix(name, "AR2V") || strings.HasPrefix(name, "ARCHIVE2")
+}
+
 func timeFromModifiedJulian(days, ms int) time.Time {
 	return time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC).
 		AddDate(0, 0, int(days-1)).
@@ -154,6 +163,16 @@ type RadialData struct {
 	CalibConstVertChan float32
 }
 
+// Nyquist returns the Nyquist velocity in m/s.
+func (r RadialData) Nyquist() float32 {
+	return float32(r.NyquistVelocity) / 100
+}
+
+// UnambiguousRangeKm returns the unambiguous range in km.
+func (r RadialData) UnambiguousRangeKm() float32 {
+	return float32(r.UnambiguousRange) / 10
+}
+
 func (r RadialData) String() string {
 	return fmt.Sprintf("[%s] %s LRTUP:%d NOISE:[%f %f]", r.DataBlockType, r.DataName, r.LRTUP, r.NoiseLevelHorz, r.NoiseLevelVert)
 }
@@ -191,25 +210,37 @@ type DataMoment struct {
 const MomentDataBelowThreshold = 999
 const MomentDataFolded = 998
 
+// GateFlag marks a gate without a value, below the signal threshold or range
+// folded.
+type GateFlag uint8
+
+const (
+	GateValid GateFlag = iota
+	GateBelowThreshold
+	GateFolded
+)
+
+// ScaledFlag returns the flag of a scaled gate, from the MomentDataFolded and
+// MomentDataBelowThreshold it holds in place of a value. Physical values
+// never reach them, unlike raw data levels.
+func ScaledFlag(v float32) GateFlag {
+	switch v {
+	case MomentDataBelowThreshold:
+		return GateBelowThreshold
+	case MomentDataFolded:
+		return GateFolded
+	}
+
+	return GateValid
+}
+
 // ScaledData automatically scales the nexrad moment values to their actual values.
 // For all data moment integer values N = 0 indicates received signal is below
 // threshold and N = 1 indicates range folded data. Actual data range is N = 2
 // through 255, or 1023 for data resolution size 8, and 10 bits respectively.
 func (d *DataMoment) ScaledData() []float32 {
-
-	gates := make([]uint16, d.NumberDataMomentGates)
-
-	if d.DataWordSize == 8 {
-		for i, v := range d.Data {
-			gates[i] = uint16(v)
-		}
-	} else if d.DataWordSize == 16 {
-		r := bytes.NewReader(d.Data)
-		binary.Read(r, binary.BigEndian, gates)
-	}
-
 	scaledData := []float32{}
-	for _, v := range gates {
+	for _, v := range d.levels() {
 		if v == 0 {
 			// below threshold
 			scaledData = append(scaledData, MomentDataBelowThreshold)
@@ -224,6 +255,44 @@ func (d *DataMoment) ScaledData() []float32 {
 	return scaledData
 }
 
+// RawData returns the unscaled integer data level of each gate, as is, and
+// the flag of each. Levels 0 and 1 are flagged, as 16 bit moments may have
+// levels as high as the sentinels of ScaledData, e.g. PHI up to 1023.
+func (d *DataMoment) RawData() ([]float32, []GateFlag) {
+	levels := d.levels()
+	rawData := make([]float32, len(levels))
+	flags := make([]GateFlag, len(levels))
+
+	for i, v := range levels {
+		rawData[i] = float32(v)
+
+		switch v {
+		case 0:
+			flags[i] = GateBelowThreshold
+		case 1:
+			flags[i] = GateFolded
+		}
+	}
+
+	return rawData, flags
+}
+
+// levels unpacks the 8 or 16 bit data level of each gate.
+func (d *DataMoment) levels() []uint16 {
+	gates := make([]uint16, d.NumberDataMomentGates)
+
+	if d.DataWordSize == 8 {
+		for i, v := range d.Data {
+			gates[i] = uint16(v)
+		}
+	} else if d.DataWordSize == 16 {
+		r := bytes.NewReader(d.Data)
+		binary.Read(r, binary.BigEndian, gates)
+	}
+
+	return gates
+}
+
 // scaleUint converts unsigned integer data that can be converted to floating point
 // data using the Scale and Offset fields, i.e., F = (N - OFFSET) / SCALE where
 // N is the integer data value and F is the resulting floating point value. A
@@ -2,7 +2,9 @@ package archive2
 
 import (
 	"bytes"
+	"compress/gzip"
 	"encoding/binary"
+	"fmt"
 	"io"
 	"time"
 
@@ -10,35 +12,69 @@ import (
 	"github.com/sirupsen/logrus"
 )
 
-func decompressBZ2(f io.Reader, size int32) *bytes.Reader {
+func decompressBZ2(f io.Reader, size int32) (*bytes.Reader, error) {
 	start := time.Now()
 	defer func() {
 		logrus.Tracef("ar2: bz2 extracted %d Bytes in %s", size, time.Since(start))
 	}()
 	compressedData := make([]byte, size)
-	binary.Read(f, binary.BigEndian, &compressedData)
+	if err := binary.Read(f, binary.BigEndian, &compressedData); err != nil {
+		return nil, fmt.Errorf("truncated LDM record: %s", err)
+	}
 	bz2Reader, err := pbzip2.NewReader(bytes.NewReader(compressedData))
 	if err != nil {
-		logrus.Fatal(err)
+		return nil, err
 	}
 	extractedData := bytes.NewBuffer([]byte{})
-	io.Copy(extractedData, bz2Reader)
-	return bytes.NewReader(extractedData.Bytes())
+	if _, err := io.Copy(extractedData, bz2Reader); err != nil {
+		return nil, fmt.Errorf("failed to decompress LDM record: %s", err)
+	}
+	return bytes.NewReader(extractedData.Bytes()), nil
+}
+
+// decompressFile decompresses an entire gzip or bzip2 compressed archive file into memory.
+func decompressFile(f io.Reader, ctype string) (*bytes.Reader, error) {
+	var r io.Reader
+
+	switch ctype {
+	case "gz":
+		gzd, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open gzip file: %s", err)
+		}
+		r = gzd
+	case "bz2":
+		bz2d, err := pbzip2.NewReader(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open bzip2 file: %s", err)
+		}
+		defer bz2d.Close()
+		r = bz2d
+	default:
+		return nil, fmt.Errorf("unsupported compression %s", ctype)
+	}
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s file: %s", ctype, err)
+	}
+
+	return bytes.NewReader(data), nil
 }
 
 // isCompressed return true if the file is compressed and string indicating the compression algorithm.
-func isCompressed(f io.ReadSeeker) (bool, string) {
+func isCompressed(f io.ReadSeeker) (bool, string, error) {
 	header := make([]byte, 2)
 	if _, err := f.Read(header); err != nil {
-		logrus.Fatalf("isCompressed: failed to peek header: %s\n", err)
+		return false, "", fmt.Errorf("isCompressed: failed to peek header: %s", err)
 	}
 	f.Seek(-2, io.SeekCurrent)
 	headerString := string(header)
 	switch headerString {
 	case "BZ":
-		return true, "bz2"
+		return true, "bz2", nil
 	case "\x1f\x8b":
-		return true, "gz"
+		return true, "gz", nil
 	}
-	return false, ""
+	return false, "", nil
 }
@@ -0,0 +1,147 @@
+package colormap
+
+import (
+	"fmt"
+	"image/color"
+)
+
+// Break is the color used for values at or above Value, up to the next break.
+type Break struct {
+	Value float32
+	Color color.RGBA
+}
+
+// RangeFolded is the color of range folded gates, the purple NWS velocity
+// displays mark RF with.
+const RangeFolded = "#770077"
+
+// Colormap maps product values to colors using ascending breaks.
+type Colormap struct {
+	Name   string
+	Breaks []Break
+}
+
+// Color returns the color for a value, or false if the value is below the
+// first break.
+func (c *Colormap) Color(value float32) (color.RGBA, bool) {
+	for i := len(c.Breaks) - 1; i >= 0; i-- {
+		if value >= c.Breaks[i].Value {
+			return c.Breaks[i].Color, true
+		}
+	}
+
+	return color.RGBA{}, false
+}
+
+// Hex returns the color for a value as a #rrggbb string, or false if the
+// value is below the first break.
+func (c *Colormap) Hex(value float32) (string, bool) {
+	rgba, ok := c.Color(value)
+
+	if !ok {
+		return "", false
+	}
+
+	return fmt.Sprintf("#%02x%02x%02x", rgba.R, rgba.G, rgba.B), true
+}
+
+// ForName returns the named colormap, one of reflectivity, velocity or
+// grayscale. Grayscale spans the typical range of values of the product.
+func ForName(name string, product string) (*Colormap, error) {
+	switch name {
+	case "reflectivity":
+		return Reflectivity, nil
+	case "velocity":
+		return Velocity, nil
+	case "grayscale":
+		r, ok := productRanges[product]
+
+		if !ok {
+			return nil, fmt.Errorf("no grayscale range for product %s", product)
+		}
+
+		return Grayscale(r[0], r[1], 16), nil
+	}
+
+	return nil, fmt.Errorf("unknown colormap %s", name)
+}
+
+// productRanges are the typical minimum and maximum values of each product.
+var productRanges = map[string][2]float32{
+	"REF":     {-30, 75},
+	"VEL":     {-64, 64},
+	"SRV":     {-64, 64},
+	"SW":      {0, 30},
+	"ZDR":     {-8, 8},
+	"PHI":     {0, 360},
+	"KDP":     {-2, 10},
+	"RHO":     {0.2, 1.05},
+	"REFGRAD": {-20, 20},
+}
+
+// Grayscale returns a colormap of steps even breaks from black at min to
+// white at max.
+func Grayscale(min float32, max float32, steps int) *Colormap {
+	c := &Colormap{
+		Name:   "grayscale",
+		Breaks: make([]Break, steps),
+	}
+
+	for i := range c.Breaks {
+		level := uint8(255 * i / (steps - 1))
+
+		c.Breaks[i] = Break{
+			Value: min + (max-min)*float32(i)/float32(steps),
+			Color: color.RGBA{R: level, G: level, B: level, A: 0xff},
+		}
+	}
+
+	return c
+}
+
+func rgb(hex uint32) color.RGBA {
+	return color.RGBA{R: uint8(hex >> 16), G: uint8(hex >> 8), B: uint8(hex), A: 0xff}
+}
+
+// Reflectivity is the standard NWS reflectivity color table, in dBZ.
+var Reflectivity = &Colormap{
+	Name: "reflectivity",
+	Breaks: []Break{
+		{5, rgb(0x04e9e7)},
+		{10, rgb(0x019ff4)},
+		{15, rgb(0x0300f4)},
+		{20, rgb(0x02fd02)},
+		{25, rgb(0x01c501)},
+		{30, rgb(0x008e00)},
+		{35, rgb(0xfdf802)},
+		{40, rgb(0xe5bc00)},
+		{45, rgb(0xfd9500)},
+		{50, rgb(0xfd0000)},
+		{55, rgb(0xd40000)},
+		{60, rgb(0xbc0000)},
+		{65, rgb(0xf800fd)},
+		{70, rgb(0x9854c6)},
+		{75, rgb(0xfdfdfd)},
+	},
+}
+
+// Velocity is a diverging color table in m/s, green for motion towards the
+// radar and red for motion away from it.
+var Velocity = &Colormap{
+	Name: "velocity",
+	Breaks: []Break{
+		{-1000, rgb(0x003c00)},
+		{-50, rgb(0x006400)},
+		{-36, rgb(0x009600)},
+		{-26, rgb(0x00c800)},
+		{-16, rgb(0x00fa00)},
+		{-6, rgb(0x96fa96)},
+		{-1, rgb(0x969696)},
+		{1, rgb(0xfa9696)},
+		{6, rgb(0xfa0000)},
+		{16, rgb(0xc80000)},
+		{26, rgb(0x960000)},
+		{36, rgb(0x640000)},
+		{50, rgb(0x3c0000)},
+	},
+}
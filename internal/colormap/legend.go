@@ -0,0 +1,100 @@
+package colormap
+
+import (
+	"encoding/json"
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+	"strconv"
+	"strings"
+)
+
+const (
+	// swatchWidth and swatchHeight are the size in pixels of the swatch of
+	// each break in a PNG legend
+	swatchWidth  = 32
+	swatchHeight = 16
+)
+
+// LegendEntry is a break of a legend, the color of values from Value up to
+// the next break.
+type LegendEntry struct {
+	Value float32 `json:"value"`
+	Color string  `json:"color"`
+	Label string  `json:"label"`
+}
+
+// Legend is the value to color mapping of a colormap for a product, for
+// clients drawing a colorbar matching the features.
+type Legend struct {
+	Colormap string        `json:"colormap"`
+	Product  string        `json:"product"`
+	Unit     string        `json:"unit"`
+	Breaks   []LegendEntry `json:"breaks"`
+}
+
+// NewLegend returns the legend of the colormap for a product in unit. Each
+// break is labeled with its range, the last as at or above its value, and a
+// first break below the product's typical range, there only to color every
+// value under the second, as below the second.
+func NewLegend(c *Colormap, product string, unit string) *Legend {
+	l := &Legend{
+		Colormap: c.Name,
+		Product:  product,
+		Unit:     unit,
+		Breaks:   make([]LegendEntry, len(c.Breaks)),
+	}
+
+	for i, b := range c.Breaks {
+		hex, _ := c.Hex(b.Value)
+
+		var label string
+
+		switch {
+		case i == len(c.Breaks)-1:
+			label = fmt.Sprintf("≥ %v %v", formatValue(b.Value), unit)
+		case i == 0 && b.Value < productRanges[product][0]:
+			label = fmt.Sprintf("< %v %v", formatValue(c.Breaks[1].Value), unit)
+		default:
+			label = fmt.Sprintf("%v to %v %v", formatValue(b.Value), formatValue(c.Breaks[i+1].Value), unit)
+		}
+
+		// unitless products, such as RHO, have no unit to follow the value
+		l.Breaks[i] = LegendEntry{Value: b.Value, Color: hex, Label: strings.TrimSpace(label)}
+	}
+
+	return l
+}
+
+// formatValue formats a break value as the shortest decimal that reads back
+// as the same float32, so grayscale breaks aren't written with float64 noise.
+func formatValue(v float32) string {
+	return strconv.FormatFloat(float64(v), 'f', -1, 32)
+}
+
+// WriteJSON writes the legend as indented JSON, labels unescaped so they read
+// as written.
+func (l *Legend) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetEscapeHTML(false)
+	encoder.SetIndent("", "  ")
+
+	return encoder.Encode(l)
+}
+
+// WritePNG writes the colormap as a horizontal colorbar of a swatch per break,
+// lowest on the left. Labels are only in the JSON legend.
+func WritePNG(w io.Writer, c *Colormap) error {
+	img := image.NewRGBA(image.Rect(0, 0, swatchWidth*len(c.Breaks), swatchHeight))
+
+	for i, b := range c.Breaks {
+		for x := i * swatchWidth; x < (i+1)*swatchWidth; x++ {
+			for y := 0; y < swatchHeight; y++ {
+				img.SetRGBA(x, y, b.Color)
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
@@ -0,0 +1,70 @@
+package colormap
+
+import (
+	"bytes"
+	"image/png"
+	"strings"
+	"testing"
+)
+
+func TestNewLegend(t *testing.T) {
+	l := NewLegend(Velocity, "VEL", "m/s")
+
+	if len(l.Breaks) != len(Velocity.Breaks) {
+		t.Fatalf("expected %d breaks, got %d", len(Velocity.Breaks), len(l.Breaks))
+	}
+
+	expected := []LegendEntry{
+		// below the typical range, labeled by the next break
+		{-1000, "#003c00", "< -50 m/s"},
+		{-50, "#006400", "-50 to -36 m/s"},
+		{50, "#3c0000", "≥ 50 m/s"},
+	}
+
+	for i, j := range []int{0, 1, len(l.Breaks) - 1} {
+		if l.Breaks[j] != expected[i] {
+			t.Errorf("break %d: expected %v, got %v", j, expected[i], l.Breaks[j])
+		}
+	}
+
+	var b bytes.Buffer
+
+	if err := l.WriteJSON(&b); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.Contains(b.String(), `"label": "< -50 m/s"`) {
+		t.Errorf("expected unescaped labels, got %v", b.String())
+	}
+}
+
+func TestNewLegendGrayscale(t *testing.T) {
+	l := NewLegend(Grayscale(0.2, 1.05, 4), "RHO", "")
+
+	// shortest float32 decimals
+	if l.Breaks[1].Label != "0.4125 to 0.625" {
+		t.Errorf("unexpected label %q", l.Breaks[1].Label)
+	}
+}
+
+func TestWritePNG(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := WritePNG(&b, Reflectivity); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if width := img.Bounds().Dx(); width != swatchWidth*len(Reflectivity.Breaks) {
+		t.Errorf("expected width %d, got %d", swatchWidth*len(Reflectivity.Breaks), width)
+	}
+
+	if r, g, b, _ := img.At(swatchWidth, 0).RGBA(); r>>8 != 0x01 || g>>8 != 0x9f || b>>8 != 0xf4 {
+		t.Errorf("expected the second break's color in the second swatch")
+	}
+}
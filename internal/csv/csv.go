@@ -0,0 +1,43 @@
+// Package csv writes bins as CSV, a row per bin of its center, value, and
+// position relative to the radar, for tabular analysis without parsing
+// geometries.
+package csv
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+)
+
+// Header names the columns: the longitude and latitude of the bin's center,
+// its value, the elevation angle and azimuth of its radial in degrees, and the
+// slant range of its center in km.
+const Header = "lon,lat,value,elevation,azimuth,range"
+
+// Write writes a header row and a row per bin, coordinates at props.Precision
+// decimals and values as in other formats.
+func Write(w io.Writer, bins []*geo.Bin, props *geo.FeatureProperties) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprintln(b, Header)
+
+	decimals := props.ValueDecimals()
+
+	for _, bin := range bins {
+		c := bin.Center()
+
+		// angles to a hundredth of a degree and ranges to the meter
+		fmt.Fprintf(b, "%.*f,%.*f,%.*f,%.2f,%.2f,%.3f\n",
+			props.Precision, c.X(),
+			props.Precision, c.Y(),
+			decimals, bin.Value,
+			bin.ElevationAngle,
+			bin.Azimuth,
+			bin.Range/1000,
+		)
+	}
+
+	return b.Flush()
+}
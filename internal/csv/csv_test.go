@@ -0,0 +1,38 @@
+package csv
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+func TestWrite(t *testing.T) {
+	bin := geo.NewBin(proj.NewCoord(-105, 40, 0, 0), proj.NewCoord(-104.99, 40, 0, 0), proj.NewCoord(-105, 40.01, 0, 0), proj.NewCoord(-104.99, 40.01, 0, 0), 42.5)
+	bin.ElevationAngle = 0.4833984
+	bin.Azimuth = 90.25
+	bin.Range = 2250
+
+	var b bytes.Buffer
+
+	if err := Write(&b, []*geo.Bin{bin}, &geo.FeatureProperties{Product: "REF", Precision: 4}); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := Header + "\n-104.9950,40.0050,42.5,0.48,90.25,2.250\n"
+
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+
+	b.Reset()
+
+	if err := Write(&b, []*geo.Bin{bin}, &geo.FeatureProperties{Product: "ZDR", Precision: 3}); err != nil {
+		t.Fatal(err)
+	}
+
+	if expected := Header + "\n-104.995,40.005,42.50,0.48,90.25,2.250\n"; b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
@@ -0,0 +1,110 @@
+package geo
+
+import (
+	"github.com/twpayne/go-proj/v10"
+)
+
+// unwrapLongitudes shifts corners by 360 degrees where needed so the bin is
+// continuous, leaving longitudes beyond ±180 for bins crossing the
+// antimeridian, e.g. near Alaskan radars. Only bins in geographic coordinates
+// are unwrapped.
+func (b *Bin) unwrapLongitudes() {
+	for i := 1; i < len(b.Coords); i++ {
+		if d := b.Coords[i][0] - b.Coords[0][0]; d > 180 {
+			b.Coords[i][0] -= 360
+			b.antimeridian = true
+		} else if d < -180 {
+			b.Coords[i][0] += 360
+			b.antimeridian = true
+		}
+	}
+}
+
+// CrossesAntimeridian returns true if the bin extends past ±180 longitude.
+func (b *Bin) CrossesAntimeridian() bool {
+	return b.antimeridian
+}
+
+// Polygons returns the bin's ring, or for bins crossing the antimeridian the
+// parts of the ring either side of it, with longitudes within ±180 as RFC
+// 7946 recommends.
+func (b *Bin) Polygons() [][]proj.Coord {
+	ring := b.Ring()
+
+	if !b.CrossesAntimeridian() {
+		return [][]proj.Coord{ring}
+	}
+
+	return splitAntimeridian(ring)
+}
+
+// splitAntimeridian splits a ring with unwrapped longitudes crossing ±180
+// into the parts either side of it.
+func splitAntimeridian(ring []proj.Coord) [][]proj.Coord {
+	// move the crossing to +180
+	for _, c := range ring {
+		if c.X() < -180 {
+			ring = shiftLongitudes(ring, 360)
+			break
+		}
+	}
+
+	polygons := make([][]proj.Coord, 0, 2)
+
+	if east := clipLongitude(ring, 180, true); len(east) >= 3 {
+		polygons = append(polygons, east)
+	}
+
+	if west := clipLongitude(ring, 180, false); len(west) >= 3 {
+		polygons = append(polygons, shiftLongitudes(west, -360))
+	}
+
+	return polygons
+}
+
+func shiftLongitudes(ring []proj.Coord, degrees float64) []proj.Coord {
+	shifted := make([]proj.Coord, len(ring))
+
+	for i, c := range ring {
+		shifted[i] = proj.NewCoord(c.X()+degrees, c.Y(), c.Z(), c.M())
+	}
+
+	return shifted
+}
+
+// clipLongitude clips the ring to the longitudes at or below the meridian if
+// below is true, or at or above it otherwise.
+func clipLongitude(ring []proj.Coord, meridian float64, below bool) []proj.Coord {
+	inside := func(c proj.Coord) bool {
+		if below {
+			return c.X() <= meridian
+		}
+
+		return c.X() >= meridian
+	}
+
+	intersect := func(a proj.Coord, b proj.Coord) proj.Coord {
+		t := (meridian - a.X()) / (b.X() - a.X())
+
+		return proj.NewCoord(meridian, a.Y()+t*(b.Y()-a.Y()), a.Z()+t*(b.Z()-a.Z()), 0)
+	}
+
+	clipped := make([]proj.Coord, 0, len(ring)+1)
+	previous := ring[len(ring)-1]
+
+	for _, current := range ring {
+		if inside(current) {
+			if !inside(previous) {
+				clipped = append(clipped, intersect(previous, current))
+			}
+
+			clipped = append(clipped, current)
+		} else if inside(previous) {
+			clipped = append(clipped, intersect(previous, current))
+		}
+
+		previous = current
+	}
+
+	return clipped
+}
@@ -0,0 +1,45 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/twpayne/go-proj/v10"
+)
+
+func TestAntimeridianPolygons(t *testing.T) {
+	coords := []proj.Coord{
+		proj.NewCoord(179.99, 52, 0, 0),
+		proj.NewCoord(-179.99, 52, 0, 0),
+		proj.NewCoord(179.99, 52.01, 0, 0),
+		proj.NewCoord(-179.99, 52.01, 0, 0),
+	}
+
+	bin := NewBin(coords[0], coords[1], coords[2], coords[3], 0)
+	bin.unwrapLongitudes()
+
+	if !bin.CrossesAntimeridian() {
+		t.Fatal("expected the bin to cross the antimeridian")
+	}
+
+	polygons := bin.Polygons()
+
+	if len(polygons) != 2 {
+		t.Fatalf("expected 2 polygons, got %d", len(polygons))
+	}
+
+	for i, ring := range polygons {
+		for _, c := range ring {
+			if c.X() < -180 || c.X() > 180 {
+				t.Errorf("polygon %d: longitude %v out of range", i, c.X())
+			}
+		}
+
+		if SignedArea(ring) <= 0 {
+			t.Errorf("polygon %d: expected counterclockwise winding", i)
+		}
+	}
+
+	if len(NewBin(proj.NewCoord(0, 0, 0, 0), proj.NewCoord(1, 0, 0, 0), proj.NewCoord(0, 1, 0, 0), proj.NewCoord(1, 1, 0, 0), 0).Polygons()) != 1 {
+		t.Error("expected a single polygon away from the antimeridian")
+	}
+}
@@ -0,0 +1,39 @@
+package geo
+
+import "github.com/twpayne/go-proj/v10"
+
+// BoundingBox is a geographic region in degrees of longitude and latitude.
+type BoundingBox struct {
+	MinLon float64
+	MinLat float64
+	MaxLon float64
+	MaxLat float64
+}
+
+// Contains returns true if the geographic coordinate lies within the box.
+func (bb *BoundingBox) Contains(c proj.Coord) bool {
+	return c.X() >= bb.MinLon && c.X() <= bb.MaxLon && c.Y() >= bb.MinLat && c.Y() <= bb.MaxLat
+}
+
+// Intersects returns true if any corner of the bin lies within the box.
+func (bb *BoundingBox) Intersects(b *Bin) bool {
+	for _, c := range b.Coords {
+		if bb.Contains(c) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func filterBins(bins []*Bin, bb *BoundingBox) []*Bin {
+	filtered := make([]*Bin, 0, len(bins))
+
+	for _, bin := range bins {
+		if bb.Intersects(bin) {
+			filtered = append(filtered, bin)
+		}
+	}
+
+	return filtered
+}
@@ -0,0 +1,74 @@
+package geo
+
+import "math"
+
+const (
+	// earthRadius is the mean radius of the earth in meters.
+	earthRadius = 6371000
+	// effectiveEarthRadius accounts for standard atmospheric refraction, which
+	// bends the beam towards the ground as if the earth were 4/3 its size.
+	effectiveEarthRadius = earthRadius * 4.0 / 3.0
+)
+
+// beamPosition returns the distance along the earth's surface and the height
+// above the radar of the beam center at slantRange meters from the radar,
+// using the 4/3 effective earth radius model (Doviak & Zrnić, eq. 2.28).
+func beamPosition(slantRange float64, elevationRadians float64) (groundRange float64, height float64) {
+	height = math.Sqrt(
+		slantRange*slantRange+
+			effectiveEarthRadius*effectiveEarthRadius+
+			2*slantRange*effectiveEarthRadius*math.Sin(elevationRadians),
+	) - effectiveEarthRadius
+
+	groundRange = effectiveEarthRadius * math.Asin(slantRange*math.Cos(elevationRadians)/(effectiveEarthRadius+height))
+
+	return groundRange, height
+}
+
+// orthographicRadius converts a distance along the earth's surface to the
+// distance from the origin in the local orthographic plane.
+func orthographicRadius(groundRange float64) float64 {
+	return earthRadius * math.Sin(groundRange/earthRadius)
+}
+
+// beamPosition returns the ground range and height of the beam center at
+// slantRange meters by the 4/3 effective earth radius model, or along a
+// straight line over a flat earth with NoCurvature. Bin corners without
+// curvature are placed by straightBeam instead, as earlier versions placed
+// them.
+func (options *RadarToJSONOptions) beamPosition(slantRange float64, elevationRadians float64) (groundRange float64, height float64) {
+	if options.NoCurvature {
+		return slantRange * math.Cos(elevationRadians), slantRange * math.Sin(elevationRadians)
+	}
+
+	return beamPosition(slantRange, elevationRadians)
+}
+
+// planeRadius converts a ground range from options.beamPosition to the
+// distance from the origin in the local orthographic plane, the same
+// distance with NoCurvature.
+func (options *RadarToJSONOptions) planeRadius(groundRange float64) float64 {
+	if options.NoCurvature {
+		return groundRange
+	}
+
+	return orthographicRadius(groundRange)
+}
+
+// straightBeam returns the azimuth as a math angle and the sine and cosine of
+// the beam's angle from the zenith, phi, in radians, computed in float32 as
+// earlier versions did. NoCurvature places a gate r meters out at r*sinPhi on
+// the tangent plane and r*cosPhi above it from these, so its positions are bit
+// for bit those of earlier versions.
+func straightBeam(azimuth float32, elevation float32) (thetaRadians float64, sinPhi float64, cosPhi float64) {
+	phi := 90 - elevation
+	phiRadians := float64(phi * (math.Pi / 180))
+
+	theta := 90 - azimuth
+
+	if theta < 0 {
+		theta += 360
+	}
+
+	return float64(theta * (math.Pi / 180)), math.Sin(phiRadians), math.Cos(phiRadians)
+}
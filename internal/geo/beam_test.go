@@ -0,0 +1,38 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestBeamPosition(t *testing.T) {
+	// 0.5 degree tilt at 200 km slant range
+	groundRange, height := beamPosition(200000, 0.5*math.Pi/180)
+
+	if math.Abs(height-4098.7) > 1 {
+		t.Errorf("expected height of ~4098.7 m, got %f", height)
+	}
+
+	if math.Abs(groundRange-199914.4) > 1 {
+		t.Errorf("expected ground range of ~199914.4 m, got %f", groundRange)
+	}
+}
+
+func TestBeamPositionNoCurvature(t *testing.T) {
+	options := &RadarToJSONOptions{NoCurvature: true}
+
+	groundRange, height := options.beamPosition(200000, 0.5*math.Pi/180)
+
+	if math.Abs(height-1745.3) > 1 || math.Abs(groundRange-199992.4) > 1 {
+		t.Errorf("expected a straight beam at ~199992.4 m and ~1745.3 m high, got %f and %f", groundRange, height)
+	}
+
+	if rho := options.planeRadius(groundRange); rho != groundRange {
+		t.Errorf("expected the plane radius to be the ground range, got %f", rho)
+	}
+
+	// the earth curves away beneath the beam, even bent by refraction
+	if _, curved := (&RadarToJSONOptions{}).beamPosition(200000, 0.5*math.Pi/180); curved <= height {
+		t.Errorf("expected the beam higher above a curved earth, got %f", curved)
+	}
+}
@@ -0,0 +1,125 @@
+package geo
+
+import (
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+)
+
+// benchmarkGates is the number of gates in a super resolution REF radial,
+// out to 460 km at 250 m.
+const benchmarkGates = 1832
+
+// benchmarkRadials is the number of radials in a super resolution sweep.
+const benchmarkRadials = 720
+
+func benchmarkScan() []*archive2.Message31 {
+	gates := make([]byte, benchmarkGates)
+
+	// mostly valid gates with a few below threshold, as in a stormy sweep
+	for i := range gates {
+		gates[i] = byte(2 + i%250)
+	}
+
+	for i := 0; i < len(gates); i += 10 {
+		gates[i] = 0
+	}
+
+	scan := make([]*archive2.Message31, benchmarkRadials)
+
+	for i := range scan {
+		scan[i] = testRadial(1, float32(i)*0.5, gates)
+		scan[i].Header.AzimuthResolutionSpacingCode = 1
+	}
+
+	return scan
+}
+
+func reportBins(b *testing.B, bins int) {
+	b.ReportMetric(float64(bins)*float64(b.N)/b.Elapsed().Seconds(), "bins/s")
+}
+
+func BenchmarkRadialToRelativePoints(b *testing.B) {
+	radial := benchmarkScan()[0]
+	options := &RadarToJSONOptions{Product: "REF"}
+
+	bins, err := radialToRelativePoints(radial, options)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		radialToRelativePoints(radial, options)
+	}
+
+	reportBins(b, len(bins))
+}
+
+func BenchmarkRelativeBinsToGeographicBins(b *testing.B) {
+	scan := benchmarkScan()
+	options := &RadarToJSONOptions{Product: "REF"}
+
+	bins, err := RelativeScanBins(scan, options)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	relative := make([]Poly, len(bins))
+
+	for i, bin := range bins {
+		relative[i] = bin.Coords
+	}
+
+	transform, err := createTransform(scan[0].VolumeData.Lat, scan[0].VolumeData.Lon)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	defer transform.Destroy()
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+
+		// the transform replaces each bin's corners
+		for j, bin := range bins {
+			bin.Coords = relative[j]
+		}
+
+		b.StartTimer()
+
+		if err := relativeBinsToGeographicBins(transform, bins); err != nil {
+			b.Fatal(err)
+		}
+	}
+
+	reportBins(b, len(bins))
+}
+
+func BenchmarkGeoreferenceScan(b *testing.B) {
+	scan := benchmarkScan()
+	options := &RadarToJSONOptions{Product: "REF"}
+
+	bins, err := GeoreferenceScan(scan, options)
+
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		GeoreferenceScan(scan, options)
+	}
+
+	reportBins(b, len(bins))
+}
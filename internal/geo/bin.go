@@ -2,18 +2,131 @@ package geo
 
 import (
 	"fmt"
+	"io"
 	"strings"
 
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
 	"github.com/twpayne/go-proj/v10"
 )
 
-const coordFmt = "[%.4f,%.4f]"
+const coordFmt = "[%.*f,%.*f]"
+
+// coordZFmt is coordFmt with a z in whole meters
+const coordZFmt = "[%.*f,%.*f,%.0f]"
+
+// DefaultPrecision is the default number of decimals written for coordinates,
+// about 10 m at the equator.
+const DefaultPrecision = 4
+
+// valueDecimals is the number of decimals written for products whose
+// resolution is finer than the default of one decimal.
+var valueDecimals = map[string]int{
+	// ZDR is stored in 1/16 dB increments
+	"ZDR": 2,
+	"KDP": 2,
+}
+
+// ValueDecimals returns the number of decimals written for values of the
+// product.
+func ValueDecimals(product string) int {
+	if decimals, ok := valueDecimals[product]; ok {
+		return decimals
+	}
+
+	return 1
+}
 
 type Poly []proj.Coord
 
 type Bin struct {
 	Coords Poly
 	Value  float32
+	// Elevation is the elevation number of the scan the bin belongs to
+	Elevation int
+	// ElevationAngle is the elevation angle of the radial in degrees
+	ElevationAngle float32
+	// Height is the height of the beam center above radar level in meters
+	Height float64
+	// Azimuth is the azimuth angle of the bin's radial in degrees
+	Azimuth float32
+	// Range is the slant range of the bin's center from the radar in meters
+	Range float64
+	// GateFlag marks bins of gates kept without a value, see Flag. Value is
+	// then meaningless
+	GateFlag archive2.GateFlag
+	// antimeridian is set if unwrapping left corners beyond ±180 longitude
+	antimeridian bool
+}
+
+// FeatureProperties controls how each bin feature and its properties are
+// written.
+type FeatureProperties struct {
+	Product string
+	// Precision is the number of decimals written for coordinates
+	Precision int
+	// Elevation includes the elevation number and angle of each bin
+	Elevation bool
+	// Angle includes the elevation angle of each bin without the number,
+	// for output of a single elevation
+	Angle bool
+	// Height includes the beam center height of each bin
+	Height bool
+	// AntennaHeight is the height in meters of the radar antenna above sea
+	// level, the site height plus the feedhorn height, above which bin
+	// heights are measured
+	AntennaHeight float64
+	// Point writes the center of each bin as a Point instead of its polygon
+	Point bool
+	// Z writes the beam center height of each bin as the z of its GeoJSON
+	// positions, which are otherwise 2D for clients parsing only x and y
+	Z bool
+	// Colormap adds simplestyle-spec fill and stroke colors, if set
+	Colormap *colormap.Colormap
+	// Simplify merges the bins of a bucket adjacent along each radial and
+	// simplifies their outlines at this tolerance, in coordinate units, if
+	// greater than 0
+	Simplify float64
+	// ProductProperty includes the product name of each bin, for collections
+	// merging the features of several products
+	ProductProperty bool
+	// Raw marks bin values as the unscaled data levels of the product's
+	// moment, written as integers with a unit of "level"
+	Raw bool
+	// Transformed marks bin values as transformed by ValueScale and
+	// ValueOffset, written with a unit of "scaled" as they're no longer in
+	// the product's unit
+	Transformed bool
+}
+
+// rawUnit is the unit of unscaled data levels
+const rawUnit = "level"
+
+// transformedUnit is the unit of values transformed by a scale and offset
+const transformedUnit = "scaled"
+
+// ValueDecimals returns the number of decimals written for bin values, none
+// for raw data levels.
+func (p *FeatureProperties) ValueDecimals() int {
+	if p.Raw {
+		return 0
+	}
+
+	return ValueDecimals(p.Product)
+}
+
+// Unit returns the unit of bin values, the product's unit, "level" for raw
+// data levels, or "scaled" for transformed values.
+func (p *FeatureProperties) Unit() string {
+	if p.Raw {
+		return rawUnit
+	}
+
+	if p.Transformed {
+		return transformedUnit
+	}
+
+	return archive2.ProductUnit(p.Product)
 }
 
 func NewBin(a proj.Coord, b proj.Coord, c proj.Coord, d proj.Coord, value float32) *Bin {
@@ -23,20 +136,212 @@ func NewBin(a proj.Coord, b proj.Coord, c proj.Coord, d proj.Coord, value float3
 	}
 }
 
-func (b *Bin) AppendFeature(builder *strings.Builder) {
-	fmt.Fprint(builder, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":[[")
+// AppendFeature writes the bin as a GeoJSON polygon feature, with the value
+// keyed by the lowercase product name alongside the product's unit. Bins
+// crossing the antimeridian are written as a MultiPolygon of both parts.
+func (b *Bin) AppendFeature(builder io.Writer, props *FeatureProperties) {
+	if props.Point {
+		fmt.Fprint(builder, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Point\",\"coordinates\":")
+		b.appendPoint(builder, b.Center(), props)
+	} else if polygons := b.Polygons(); len(polygons) == 1 {
+		fmt.Fprint(builder, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":")
+		b.appendPolygon(builder, polygons[0], props)
+	} else {
+		fmt.Fprint(builder, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"MultiPolygon\",\"coordinates\":[")
+
+		for i, ring := range polygons {
+			if i > 0 {
+				fmt.Fprint(builder, ",")
+			}
 
-	// A, B, D, C, A
-	fmt.Fprintf(builder, coordFmt, b.Coords[0].X(), b.Coords[0].Y())
-	fmt.Fprint(builder, ",")
-	fmt.Fprintf(builder, coordFmt, b.Coords[1].X(), b.Coords[1].Y())
-	fmt.Fprint(builder, ",")
-	fmt.Fprintf(builder, coordFmt, b.Coords[3].X(), b.Coords[3].Y())
-	fmt.Fprint(builder, ",")
-	fmt.Fprintf(builder, coordFmt, b.Coords[2].X(), b.Coords[2].Y())
-	fmt.Fprint(builder, ",")
-	fmt.Fprintf(builder, coordFmt, b.Coords[0].X(), b.Coords[0].Y())
-	fmt.Fprint(builder, "]]},\"properties\":{\"value\":")
-	fmt.Fprintf(builder, "%.1f", b.Value)
+			b.appendPolygon(builder, ring, props)
+		}
+
+		fmt.Fprint(builder, "]")
+	}
+
+	fmt.Fprint(builder, "},\"properties\":{")
+	b.AppendProperties(builder, props)
 	fmt.Fprint(builder, "}}")
 }
+
+// Flag returns "range_folded" or "below_threshold" for gates kept with
+// RadarToJSONOptions.KeepFolded or KeepBelowThreshold, which have no value,
+// or "" for gates with a value.
+func (b *Bin) Flag() string {
+	switch b.GateFlag {
+	case archive2.GateFolded:
+		return "range_folded"
+	case archive2.GateBelowThreshold:
+		return "below_threshold"
+	}
+
+	return ""
+}
+
+// AppendProperties writes the members of the bin's properties object, without
+// the enclosing braces.
+func (b *Bin) AppendProperties(builder io.Writer, props *FeatureProperties) {
+	if flag := b.Flag(); flag != "" {
+		AppendFlagProperties(builder, props, flag)
+	} else {
+		AppendValueProperties(builder, props, b.Value)
+	}
+
+	if props.Elevation {
+		fmt.Fprintf(builder, ",\"elevation\":%d,\"elevation_angle\":%.2f", b.Elevation, b.ElevationAngle)
+	} else if props.Angle {
+		fmt.Fprintf(builder, ",\"elevation_angle\":%.2f", b.ElevationAngle)
+	}
+
+	if props.Height {
+		fmt.Fprintf(builder, ",\"height\":%.0f", b.Height)
+	}
+}
+
+// appendPoint writes a position of the bin, at its beam center height if
+// props.Z is set.
+func (b *Bin) appendPoint(builder io.Writer, c proj.Coord, props *FeatureProperties) {
+	if props.Z {
+		fmt.Fprintf(builder, coordZFmt, props.Precision, c.X(), props.Precision, c.Y(), b.Height)
+		return
+	}
+
+	AppendPoint(builder, c, props.Precision)
+}
+
+// appendPolygon writes a ring of the bin as the coordinates of a GeoJSON
+// polygon, as AppendPolygon, at its beam center height if props.Z is set.
+func (b *Bin) appendPolygon(builder io.Writer, ring []proj.Coord, props *FeatureProperties) {
+	if !props.Z {
+		AppendPolygon(builder, ring, props.Precision)
+		return
+	}
+
+	fmt.Fprint(builder, "[[")
+
+	for _, c := range ring {
+		b.appendPoint(builder, c, props)
+		fmt.Fprint(builder, ",")
+	}
+
+	b.appendPoint(builder, ring[0], props)
+	fmt.Fprint(builder, "]]")
+}
+
+// AppendPoint writes the coordinates of a GeoJSON point, rounded to precision
+// decimals.
+func AppendPoint(builder io.Writer, c proj.Coord, precision int) {
+	fmt.Fprintf(builder, coordFmt, precision, c.X(), precision, c.Y())
+}
+
+// Center returns the average of the bin's corners, within ±180 longitude.
+func (b *Bin) Center() proj.Coord {
+	var x, y float64
+
+	for _, c := range b.Coords {
+		x += c.X() / float64(len(b.Coords))
+		y += c.Y() / float64(len(b.Coords))
+	}
+
+	if b.antimeridian {
+		if x > 180 {
+			x -= 360
+		} else if x < -180 {
+			x += 360
+		}
+	}
+
+	return proj.NewCoord(x, y, 0, 0)
+}
+
+// AppendPolygon writes the ring as the coordinates of a GeoJSON polygon,
+// rounded to precision decimals and closed by repeating the first corner.
+func AppendPolygon(builder io.Writer, ring []proj.Coord, precision int) {
+	fmt.Fprint(builder, "[[")
+
+	for _, c := range ring {
+		fmt.Fprintf(builder, coordFmt, precision, c.X(), precision, c.Y())
+		fmt.Fprint(builder, ",")
+	}
+
+	fmt.Fprintf(builder, coordFmt, precision, ring[0].X(), precision, ring[0].Y())
+	fmt.Fprint(builder, "]]")
+}
+
+// AppendValueProperties writes the value keyed by the lowercase product name,
+// the product's unit, and colors for the value if a colormap is set, after
+// the product name if props.ProductProperty is set.
+func AppendValueProperties(builder io.Writer, props *FeatureProperties, value float32) {
+	appendProductProperty(builder, props)
+	fmt.Fprintf(builder, "\"%s\":%.*f,", strings.ToLower(props.Product), props.ValueDecimals(), value)
+	fmt.Fprintf(builder, "\"unit\":\"%s\"", props.Unit())
+
+	if props.Colormap != nil {
+		if hex, ok := props.Colormap.Hex(value); ok {
+			fmt.Fprintf(builder, ",\"fill\":\"%s\",\"fill-opacity\":0.8,\"stroke\":\"%s\",\"stroke-width\":0", hex, hex)
+		}
+	}
+}
+
+// AppendFlagProperties writes a null value keyed by the lowercase product
+// name, the product's unit, and the flag of a gate without a value. Range
+// folded gates are colored colormap.RangeFolded if a colormap is set, gates
+// below threshold are left uncolored.
+func AppendFlagProperties(builder io.Writer, props *FeatureProperties, flag string) {
+	appendProductProperty(builder, props)
+	fmt.Fprintf(builder, "\"%s\":null,", strings.ToLower(props.Product))
+	fmt.Fprintf(builder, "\"unit\":\"%s\",\"flag\":\"%s\"", props.Unit(), flag)
+
+	if props.Colormap != nil && flag == "range_folded" {
+		fmt.Fprintf(builder, ",\"fill\":\"%s\",\"fill-opacity\":0.8,\"stroke\":\"%s\",\"stroke-width\":0", colormap.RangeFolded, colormap.RangeFolded)
+	}
+}
+
+func appendProductProperty(builder io.Writer, props *FeatureProperties) {
+	if props.ProductProperty {
+		fmt.Fprintf(builder, "\"product\":\"%s\",", props.Product)
+	}
+}
+
+// Ring returns the corners of the bin in polygon order, A, B, D, C, without
+// repeating the first corner. The ring is reversed if needed so it winds
+// counterclockwise, as RFC 7946 requires of exterior rings.
+func (b *Bin) Ring() []proj.Coord {
+	ring := []proj.Coord{b.Coords[0], b.Coords[1], b.Coords[3], b.Coords[2]}
+
+	if SignedArea(ring) < 0 {
+		ring[1], ring[3] = ring[3], ring[1]
+	}
+
+	return ring
+}
+
+// SignedArea returns the area of the ring by the shoelace formula, positive
+// when the ring winds counterclockwise.
+func SignedArea(ring []proj.Coord) float64 {
+	area := 0.0
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		area += ring[j].X()*ring[i].Y() - ring[i].X()*ring[j].Y()
+	}
+
+	return area / 2
+}
+
+// RingContains returns true if the point lies within the polygon ring, using
+// the even-odd rule.
+func RingContains(ring []proj.Coord, x float64, y float64) bool {
+	inside := false
+
+	for i, j := 0, len(ring)-1; i < len(ring); j, i = i, i+1 {
+		xi, yi := ring[i].X(), ring[i].Y()
+		xj, yj := ring[j].X(), ring[j].Y()
+
+		if (yi > y) != (yj > y) && x < (xj-xi)*(y-yi)/(yj-yi)+xi {
+			inside = !inside
+		}
+	}
+
+	return inside
+}
@@ -0,0 +1,133 @@
+package geo
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// Bins from radials all around the radar must wind counterclockwise once
+// projected, whichever way their corners face.
+func TestRingWinding(t *testing.T) {
+	scan := testArchive(1, 8, []byte{100, 100}).ElevationScans[1]
+
+	bins, err := GeoreferenceScan(scan, &RadarToJSONOptions{Product: "REF"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bin := range bins {
+		if area := SignedArea(bin.Ring()); area <= 0 {
+			t.Errorf("bin %v: expected a positive signed area, got %v", bin.Coords, area)
+		}
+	}
+
+	// a clockwise bin is reversed
+	cw := NewBin(bins[0].Coords[1], bins[0].Coords[0], bins[0].Coords[3], bins[0].Coords[2], 0)
+
+	if area := SignedArea(cw.Ring()); area <= 0 {
+		t.Errorf("expected a clockwise bin to be reversed, got signed area %v", area)
+	}
+}
+
+func TestFlagProperties(t *testing.T) {
+	tests := []struct {
+		value    float32
+		flag     archive2.GateFlag
+		expected string
+	}{
+		{archive2.MomentDataFolded, archive2.GateFolded, `"vel":null,"unit":"m/s","flag":"range_folded","fill":"#770077","fill-opacity":0.8,"stroke":"#770077","stroke-width":0`},
+		{archive2.MomentDataBelowThreshold, archive2.GateBelowThreshold, `"vel":null,"unit":"m/s","flag":"below_threshold"`},
+		{5, archive2.GateValid, `"vel":5.0,"unit":"m/s","fill":"#fa9696","fill-opacity":0.8,"stroke":"#fa9696","stroke-width":0`},
+		// a value landing on a sentinel, e.g. transformed, is still a value
+		{archive2.MomentDataFolded, archive2.GateValid, `"vel":998.0,"unit":"m/s","fill":"#3c0000","fill-opacity":0.8,"stroke":"#3c0000","stroke-width":0`},
+	}
+
+	for _, test := range tests {
+		bin := NewBin(proj.Coord{}, proj.Coord{}, proj.Coord{}, proj.Coord{}, test.value)
+		bin.GateFlag = test.flag
+
+		var b strings.Builder
+
+		bin.AppendProperties(&b, &FeatureProperties{Product: "VEL", Colormap: colormap.Velocity})
+
+		if b.String() != test.expected {
+			t.Errorf("value %v: expected %s, got %s", test.value, test.expected, b.String())
+		}
+	}
+}
+
+func TestAngleProperties(t *testing.T) {
+	bin := NewBin(proj.Coord{}, proj.Coord{}, proj.Coord{}, proj.Coord{}, 5)
+	bin.Elevation = 1
+	bin.ElevationAngle = 0.483
+
+	tests := []struct {
+		props    FeatureProperties
+		expected string
+	}{
+		{FeatureProperties{Product: "REF"}, `"ref":5.0,"unit":"dBZ"`},
+		{FeatureProperties{Product: "REF", Angle: true}, `"ref":5.0,"unit":"dBZ","elevation_angle":0.48`},
+		{FeatureProperties{Product: "REF", Angle: true, Elevation: true}, `"ref":5.0,"unit":"dBZ","elevation":1,"elevation_angle":0.48`},
+	}
+
+	for _, test := range tests {
+		var b strings.Builder
+
+		bin.AppendProperties(&b, &test.props)
+
+		if b.String() != test.expected {
+			t.Errorf("expected %s, got %s", test.expected, b.String())
+		}
+	}
+}
+
+func TestAppendFeatureZ(t *testing.T) {
+	bin := NewBin(proj.NewCoord(0, 0, 0, 0), proj.NewCoord(1, 0, 0, 0), proj.NewCoord(0, 1, 0, 0), proj.NewCoord(1, 1, 0, 0), 5)
+	bin.Height = 1234.4
+
+	var flat strings.Builder
+
+	bin.AppendFeature(&flat, &FeatureProperties{Product: "REF"})
+
+	if !strings.Contains(flat.String(), `"coordinates":[[[0,0],[1,0],[1,1],[0,1],[0,0]]]`) {
+		t.Errorf("expected 2D positions by default, got %s", flat.String())
+	}
+
+	var z strings.Builder
+
+	bin.AppendFeature(&z, &FeatureProperties{Product: "REF", Z: true})
+
+	if !strings.Contains(z.String(), `"coordinates":[[[0,0,1234],[1,0,1234],[1,1,1234],[0,1,1234],[0,0,1234]]]`) {
+		t.Errorf("expected the height as z, got %s", z.String())
+	}
+
+	var point strings.Builder
+
+	bin.AppendFeature(&point, &FeatureProperties{Product: "REF", Point: true, Precision: 1, Z: true})
+
+	if !strings.Contains(point.String(), `"coordinates":[0.5,0.5,1234]`) {
+		t.Errorf("expected the center with the height as z, got %s", point.String())
+	}
+}
+
+func TestUnit(t *testing.T) {
+	tests := []struct {
+		props    FeatureProperties
+		expected string
+	}{
+		{FeatureProperties{Product: "REF"}, "dBZ"},
+		{FeatureProperties{Product: "REF", Raw: true}, "level"},
+		{FeatureProperties{Product: "REF", Transformed: true}, "scaled"},
+	}
+
+	for _, test := range tests {
+		if unit := test.props.Unit(); unit != test.expected {
+			t.Errorf("%+v: expected %q, got %q", test.props, test.expected, unit)
+		}
+	}
+}
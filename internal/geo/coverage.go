@@ -0,0 +1,189 @@
+package geo
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/sirupsen/logrus"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// coverageVertices is the number of vertices approximating a coverage ring.
+const coverageVertices = 360
+
+// Coverage is the footprint of an elevation scan, a ring at the ground range
+// of its farthest gate.
+type Coverage struct {
+	// Elevation is the elevation number of the scan
+	Elevation int
+	// ElevationAngle is the elevation angle of the scan's first radial in
+	// degrees
+	ElevationAngle float32
+	// Range is the ground range of the ring in meters
+	Range float64
+	Ring  []proj.Coord
+	// antimeridian is set if unwrapping left vertices beyond ±180 longitude
+	antimeridian bool
+}
+
+// ArchiveCoverage returns the coverage of each elevation in
+// options.Elevations for options.Product, keyed by elevation number. The
+// ring is capped at options.MaxRange and options.LastGate, and written in
+// options.CRS, on the plane tangent at options.Center, or by
+// options.Pipeline if any is set.
+// Other options don't apply. Elevations without the product's moment are
+// left out.
+func ArchiveCoverage(archive2 *archive2.Archive2, options *RadarToJSONOptions) (map[int]*Coverage, error) {
+	lat, lon, err := archive2.RadarLocation()
+
+	if err != nil {
+		return nil, err
+	}
+
+	transform, err := options.transform(lat, lon)
+
+	if err != nil {
+		return nil, err
+	}
+
+	defer transform.Destroy()
+
+	coverages := make(map[int]*Coverage, len(options.Elevations))
+
+	for _, elevation := range options.Elevations {
+		if len(archive2.ElevationScans[elevation]) == 0 {
+			logrus.Warnf("elevation %d has no radials, skipping", elevation)
+			continue
+		}
+
+		coverage, err := scanCoverage(archive2.ElevationScans[elevation], transform, options)
+
+		if err != nil {
+			return nil, fmt.Errorf("elevation %d: %s", elevation, err)
+		}
+
+		if coverage == nil {
+			logrus.Warnf("elevation %d has no %s data, skipping", elevation, options.Product)
+			continue
+		}
+
+		coverages[elevation] = coverage
+	}
+
+	return coverages, nil
+}
+
+func scanCoverage(scan []*archive2.Message31, transform *proj.PJ, options *RadarToJSONOptions) (*Coverage, error) {
+	if len(scan) == 0 {
+		return nil, errors.New("scan contains no radials")
+	}
+
+	groundRange := 0.0
+	present := false
+
+	for _, radial := range scan {
+		if !radial.HasMoment(options.Product) {
+			continue
+		}
+
+		present = true
+
+		moment, err := radial.DataMomentForProduct(options.Product)
+
+		if err != nil {
+			return nil, err
+		}
+
+		_, gates := options.gateRange(int(moment.NumberDataMomentGates))
+		slantRange := float64(moment.DataMomentRange) + float64(gates)*float64(moment.DataMomentRangeSampleInterval)
+		ground, _ := options.beamPosition(slantRange, float64(radial.Header.ElevationAngle)*(math.Pi/180))
+
+		groundRange = math.Max(groundRange, ground)
+	}
+
+	if !present {
+		return nil, nil
+	}
+
+	if options.MaxRange != nil {
+		groundRange = math.Min(groundRange, float64(*options.MaxRange)*1000)
+	}
+
+	rho := options.planeRadius(groundRange)
+	ring := make([]proj.Coord, coverageVertices)
+
+	// counterclockwise, as RFC 7946 expects of exterior rings
+	for i := range ring {
+		theta := 2 * math.Pi * float64(i) / coverageVertices
+		ring[i] = proj.NewCoord(rho*math.Cos(theta), rho*math.Sin(theta), 0, 0)
+	}
+
+	if err := forwardArray(transform, ring); err != nil {
+		return nil, err
+	}
+
+	coverage := &Coverage{
+		Elevation:      int(scan[0].Header.ElevationNumber),
+		ElevationAngle: scan[0].Header.ElevationAngle,
+		Range:          groundRange,
+		Ring:           ring,
+	}
+
+	if options.geographicOutput() {
+		coverage.unwrapLongitudes()
+	}
+
+	return coverage, nil
+}
+
+// unwrapLongitudes shifts vertices by 360 degrees where needed so the ring is
+// continuous, as for bins.
+func (c *Coverage) unwrapLongitudes() {
+	for i := 1; i < len(c.Ring); i++ {
+		if d := c.Ring[i][0] - c.Ring[i-1][0]; d > 180 {
+			c.Ring[i][0] -= 360
+		} else if d < -180 {
+			c.Ring[i][0] += 360
+		}
+
+		if c.Ring[i][0] > 180 || c.Ring[i][0] < -180 {
+			c.antimeridian = true
+		}
+	}
+}
+
+// Polygons returns the ring, or for rings crossing the antimeridian the parts
+// either side of it.
+func (c *Coverage) Polygons() [][]proj.Coord {
+	if !c.antimeridian {
+		return [][]proj.Coord{c.Ring}
+	}
+
+	return splitAntimeridian(c.Ring)
+}
+
+// AppendFeature writes the coverage as a GeoJSON polygon feature, with its
+// elevation, elevation angle and range in km as properties.
+func (c *Coverage) AppendFeature(builder io.Writer, precision int) {
+	if polygons := c.Polygons(); len(polygons) == 1 {
+		fmt.Fprint(builder, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Polygon\",\"coordinates\":")
+		AppendPolygon(builder, polygons[0], precision)
+	} else {
+		fmt.Fprint(builder, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"MultiPolygon\",\"coordinates\":[")
+
+		for i, ring := range polygons {
+			if i > 0 {
+				fmt.Fprint(builder, ",")
+			}
+
+			AppendPolygon(builder, ring, precision)
+		}
+
+		fmt.Fprint(builder, "]")
+	}
+
+	fmt.Fprintf(builder, "},\"properties\":{\"elevation\":%d,\"elevation_angle\":%v,\"range_km\":%.1f}}", c.Elevation, c.ElevationAngle, c.Range/1000)
+}
@@ -0,0 +1,54 @@
+package geo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestArchiveCoverage(t *testing.T) {
+	ar2 := testArchive(2, 36, []byte{0, 1, 100, 150, 200})
+	maxRange := float32(3)
+
+	coverages, err := ArchiveCoverage(ar2, &RadarToJSONOptions{Product: "REF", Elevations: []int{1, 2}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capped, err := ArchiveCoverage(ar2, &RadarToJSONOptions{Product: "REF", Elevations: []int{1}, MaxRange: &maxRange})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, elevation := range []int{1, 2} {
+		coverage := coverages[elevation]
+
+		// the far edge of the last of 5 gates
+		expected, _ := beamPosition(2125+5*250, float64(coverage.ElevationAngle)*(math.Pi/180))
+
+		if math.Abs(coverage.Range-expected) > 1e-6 {
+			t.Errorf("elevation %d: expected range %v, got %v", elevation, expected, coverage.Range)
+		}
+
+		if len(coverage.Ring) != coverageVertices {
+			t.Errorf("elevation %d: expected %d vertices, got %d", elevation, coverageVertices, len(coverage.Ring))
+		}
+
+		if SignedArea(coverage.Ring) <= 0 {
+			t.Errorf("elevation %d: expected counterclockwise winding", elevation)
+		}
+
+		// the northernmost vertex is due north of the radar at the range
+		north := coverage.Ring[coverageVertices/4]
+		distance := (north.Y() - 39.7866) * math.Pi / 180 * earthRadius
+
+		if math.Abs(distance-coverage.Range) > 5 {
+			t.Errorf("elevation %d: expected the ring %v m north of the radar, got %v m", elevation, coverage.Range, distance)
+		}
+	}
+
+	if capped[1].Range != 3000 {
+		t.Errorf("expected the range capped at 3000 m, got %v", capped[1].Range)
+	}
+}
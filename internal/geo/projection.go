@@ -1,30 +1,141 @@
 package geo
 
 import (
+	"errors"
 	"fmt"
+	"math"
+	"strings"
 
-	"github.com/sirupsen/logrus"
 	"github.com/twpayne/go-proj/v10"
 )
 
-func createTransforms(radarLatitude float32, radarLongitude float32) []*proj.PJ {
-	ltp := fmt.Sprintf("+proj=ortho +lat_0=%v +lon_0=%v +x_0=0 +y_0=0 +ellps=WGS84 +units=m +no_defs", radarLatitude, radarLongitude)
+// geographic is the PROJ definition of WGS84 longitude and latitude.
+const geographic = "+proj=longlat +ellps=WGS84 +datum=WGS84 +no_defs"
 
-	geographic := "+proj=longlat +ellps=WGS84 +datum=WGS84 +no_defs"
+// localTangentPlane returns the PROJ definition of the orthographic plane
+// tangent to the ellipsoid at the point, in meters.
+func localTangentPlane(lat float32, lon float32) string {
+	return fmt.Sprintf("+proj=ortho +lat_0=%v +lon_0=%v +x_0=0 +y_0=0 +ellps=WGS84 +units=m +no_defs", lat, lon)
+}
+
+// createTransform returns the transform from the radar's local tangent plane
+// directly to geographic coordinates. PROJ objects must not be shared between
+// goroutines, so each transform gets its own context and callers should
+// create one per goroutine.
+func createTransform(radarLatitude float32, radarLongitude float32) (*proj.PJ, error) {
+	return createTransformTo(radarLatitude, radarLongitude, geographic)
+}
+
+// createTransformTo returns the transform from the radar's local tangent
+// plane to the target PROJ definition, see createTransform.
+func createTransformTo(radarLatitude float32, radarLongitude float32, target string) (*proj.PJ, error) {
+	ctx := proj.NewContext()
+
+	ltp := localTangentPlane(radarLatitude, radarLongitude)
+
+	transform, err := ctx.NewCRSToCRS(ltp, target, nil)
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create transform: %s", err)
+	}
+
+	// targets given by authority codes may order latitude or northing first
+	normalized, err := transform.NormalizeForVisualization()
+	transform.Destroy()
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to normalize transform: %s", err)
+	}
+
+	return normalized, nil
+}
+
+// createPipeline returns the coordinate operation of a PROJ pipeline, see
+// RadarToJSONOptions.Pipeline, for a radar at lat, lon. Unlike
+// createTransformTo, axes are left in the order the pipeline writes them.
+func createPipeline(radarLatitude float32, radarLongitude float32, pipeline string) (*proj.PJ, error) {
+	ctx := proj.NewContext()
+
+	transform, err := ctx.New(expandPipeline(pipeline, radarLatitude, radarLongitude))
+
+	if err != nil {
+		return nil, fmt.Errorf("failed to create pipeline: %s", err)
+	}
+
+	return transform, nil
+}
 
-	ecef := "+proj=geocent +datum=WGS84 +units=m +no_defs +type=crs"
+// expandPipeline replaces the {lat} and {lon} placeholders of a pipeline
+// with the radar's location.
+func expandPipeline(pipeline string, lat float32, lon float32) string {
+	return strings.NewReplacer("{lat}", fmt.Sprint(lat), "{lon}", fmt.Sprint(lon)).Replace(pipeline)
+}
+
+// CheckPipeline returns an error if PROJ rejects the pipeline, or it cannot
+// transform the origin of a tangent plane, so an invalid --proj-pipeline
+// fails before any conversion.
+func CheckPipeline(pipeline string) error {
+	transform, err := createPipeline(0, 0, pipeline)
+
+	if err != nil {
+		return err
+	}
+
+	defer transform.Destroy()
+
+	if transform.IsCRS() {
+		return errors.New("pipeline is a CRS rather than a coordinate operation")
+	}
+
+	if _, err := transform.Forward(proj.NewCoord(0, 0, 0, 0)); err != nil {
+		return fmt.Errorf("failed to transform the tangent plane's origin: %s", err)
+	}
+
+	return nil
+}
 
-	ltpToEcef, err := proj.NewCRSToCRS(ltp, ecef, nil)
+// RadarPosition returns the position of a radar at lat, lon in the output
+// coordinates of the options, e.g. to mark the station on a map of its bins.
+func RadarPosition(lat float32, lon float32, options *RadarToJSONOptions) (proj.Coord, error) {
+	transform, err := options.transform(lat, lon)
 
 	if err != nil {
-		logrus.Fatalln(err)
+		return proj.Coord{}, err
 	}
 
-	ecefToGeographic, err := proj.NewCRSToCRS(ecef, geographic, nil)
+	defer transform.Destroy()
+
+	// the origin of the radar's tangent plane
+	return transform.Forward(proj.NewCoord(0, 0, 0, 0))
+}
+
+// CheckCRS returns an error if PROJ cannot transform to the CRS, so an
+// invalid --crs fails before any conversion.
+func CheckCRS(crs string) error {
+	transform, err := createTransformTo(0, 0, crs)
 
 	if err != nil {
-		logrus.Fatalln(err)
+		return err
+	}
+
+	transform.Destroy()
+
+	return nil
+}
+
+// forwardArray transforms coordinates in place, failing if PROJ can't
+// transform any of them, which it sets to HUGE_VAL rather than leaving
+// them out.
+func forwardArray(transform *proj.PJ, coords []proj.Coord) error {
+	if err := transform.ForwardArray(coords); err != nil {
+		return fmt.Errorf("failed to transform coordinates: %s", err)
+	}
+
+	for _, c := range coords {
+		if math.IsInf(c.X(), 0) || math.IsInf(c.Y(), 0) || math.IsNaN(c.X()) || math.IsNaN(c.Y()) {
+			return errors.New("failed to transform coordinates: outside the domain of the transform")
+		}
 	}
 
-	return []*proj.PJ{ltpToEcef, ecefToGeographic}
+	return nil
 }
@@ -0,0 +1,195 @@
+package geo
+
+import (
+	"math"
+	"sync"
+	"testing"
+
+	"github.com/twpayne/go-proj/v10"
+)
+
+func TestTransformOrigin(t *testing.T) {
+	transform, err := createTransform(39.7866, -104.5458)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer transform.Destroy()
+
+	origin, err := transform.Forward(proj.NewCoord(0, 0, 0, 0))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(origin.X()-(-104.5458)) > 1e-6 || math.Abs(origin.Y()-39.7866) > 1e-6 {
+		t.Errorf("expected the origin at the radar, -104.5458, 39.7866, got %v, %v", origin.X(), origin.Y())
+	}
+}
+
+func TestExpandPipeline(t *testing.T) {
+	pipeline := expandPipeline("+proj=pipeline +step +inv +proj=ortho +lat_0={lat} +lon_0={lon}", 39.7866, -104.5458)
+
+	if expected := "+proj=pipeline +step +inv +proj=ortho +lat_0=39.7866 +lon_0=-104.5458"; pipeline != expected {
+		t.Errorf("expected %v, got %v", expected, pipeline)
+	}
+}
+
+func TestRadarPosition(t *testing.T) {
+	position, err := RadarPosition(39.7866, -104.5458, &RadarToJSONOptions{})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if math.Abs(position.X()-(-104.5458)) > 1e-6 || math.Abs(position.Y()-39.7866) > 1e-6 {
+		t.Errorf("expected the radar at -104.5458, 39.7866, got %v, %v", position.X(), position.Y())
+	}
+
+	// the radar is offset from a center elsewhere
+	position, err = RadarPosition(39.7866, -104.5458, &RadarToJSONOptions{Center: &Center{Lat: 39.7866, Lon: -105}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if position.X() < 30000 || position.X() > 45000 || math.Abs(position.Y()) > 1000 {
+		t.Errorf("expected the radar about 39 km east of the center, got %v, %v", position.X(), position.Y())
+	}
+}
+
+// Run with -race; every goroutine must produce the same coordinates as a
+// serial run when transforming concurrently.
+func TestConcurrentTransforms(t *testing.T) {
+	scan := testArchive(1, 360, []byte{100, 150, 200, 250})
+	opts := &RadarToJSONOptions{Product: "REF"}
+
+	expected, err := GeoreferenceScan(scan.ElevationScans[1], opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var wg sync.WaitGroup
+
+	for i := 0; i < 32; i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			bins, err := GeoreferenceScan(scan.ElevationScans[1], opts)
+
+			if err != nil {
+				t.Error(err)
+				return
+			}
+
+			if len(bins) != len(expected) {
+				t.Errorf("expected %d bins, got %d", len(expected), len(bins))
+				return
+			}
+
+			for j := range bins {
+				for k := range bins[j].Coords {
+					if bins[j].Coords[k] != expected[j].Coords[k] {
+						t.Errorf("bin %d corner %d: expected %v, got %v", j, k, expected[j].Coords[k], bins[j].Coords[k])
+						return
+					}
+				}
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// With a center, the radar's own location is written at its offset from the
+// center, in meters.
+func TestCenter(t *testing.T) {
+	scan := testArchive(1, 4, []byte{100}).ElevationScans[1]
+
+	same, err := GeoreferenceScan(scan, &RadarToJSONOptions{Product: "REF", Center: &Center{Lat: 39.7866, Lon: -104.5458}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, bin := range same {
+		for _, c := range bin.Coords {
+			// the first gate is within 3 km of the radar
+			if math.Hypot(c.X(), c.Y()) > 3000 {
+				t.Errorf("expected corners within 3 km of a center at the radar, got %v", c)
+			}
+		}
+	}
+
+	east, err := GeoreferenceScan(scan, &RadarToJSONOptions{Product: "REF", Center: &Center{Lat: 39.7866, Lon: -105.5458}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one degree of longitude at 39.8 degrees is about 85.6 km
+	if x := east[0].Coords[0].X(); x < 80000 || x > 90000 {
+		t.Errorf("expected the radar about 85 km east of the center, got %v m", x)
+	}
+}
+
+// A CRS given as a PROJ string places bins as the equivalent center does.
+func TestCRS(t *testing.T) {
+	scan := testArchive(1, 4, []byte{100}).ElevationScans[1]
+
+	centered, err := GeoreferenceScan(scan, &RadarToJSONOptions{Product: "REF", Center: &Center{Lat: 39.7866, Lon: -105.5458}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	projected, err := GeoreferenceScan(scan, &RadarToJSONOptions{Product: "REF", CRS: localTangentPlane(39.7866, -105.5458)})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := range centered {
+		for j, c := range centered[i].Coords {
+			if p := projected[i].Coords[j]; math.Abs(p.X()-c.X()) > 1e-6 || math.Abs(p.Y()-c.Y()) > 1e-6 {
+				t.Errorf("bin %d corner %d: expected %v, got %v", i, j, c, p)
+			}
+		}
+	}
+
+	if err := CheckCRS("+proj=bogus"); err == nil {
+		t.Error("expected an error for an invalid CRS")
+	}
+}
+
+// Corners PROJ can't transform, such as beyond the horizon of the tangent
+// plane, fail rather than being written as infinite coordinates.
+func TestForwardArrayOutOfDomain(t *testing.T) {
+	transform, err := createTransform(39.7866, -104.5458)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer transform.Destroy()
+
+	bin := NewBin(proj.NewCoord(0, 0, 0, 0), proj.NewCoord(1000, 0, 0, 0), proj.NewCoord(0, 1000, 0, 0), proj.NewCoord(1000, 1000, 0, 0), 5)
+
+	if err := relativeBinsToGeographicBins(transform, []*Bin{bin}); err != nil {
+		t.Fatalf("expected bins near the radar to transform, got %s", err)
+	}
+
+	far := NewBin(proj.NewCoord(0, 0, 0, 0), proj.NewCoord(7e6, 0, 0, 0), proj.NewCoord(0, 7e6, 0, 0), proj.NewCoord(7e6, 7e6, 0, 0), 5)
+
+	if err := relativeBinsToGeographicBins(transform, []*Bin{far}); err == nil {
+		t.Errorf("expected an error for corners beyond the horizon, got %v", far.Coords)
+	}
+
+	if err := sharedBinsToGeographicBins(transform, []*Bin{far}); err == nil {
+		t.Errorf("expected an error for shared corners beyond the horizon")
+	}
+}
@@ -0,0 +1,81 @@
+package geo
+
+import (
+	"math"
+	"sort"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/sirupsen/logrus"
+)
+
+// maxSpacingFactor is how many times the scan's typical azimuth spacing a
+// radial may be from both of its neighbors before it's anomalous
+const maxSpacingFactor = 5
+
+// azimuthGap returns the angle in degrees between two azimuths, the shorter
+// way around.
+func azimuthGap(a, b float32) float64 {
+	gap := math.Mod(math.Abs(float64(a)-float64(b)), 360)
+
+	return math.Min(gap, 360-gap)
+}
+
+// anomalousRadials returns whether each radial of a scan has a corrupt
+// azimuth: not a number, outside 0 to 360 degrees, or more than
+// maxSpacingFactor times the scan's median spacing from the radials recorded
+// before and after it. A radial missing between two others widens only one
+// gap of each, so only a radial out of place is anomalous.
+func anomalousRadials(scan []*archive2.Message31) []bool {
+	anomalous := make([]bool, len(scan))
+
+	for i, radial := range scan {
+		azimuth := float64(radial.Header.AzimuthAngle)
+		anomalous[i] = math.IsNaN(azimuth) || azimuth < 0 || azimuth > 360
+	}
+
+	if len(scan) < 3 {
+		return anomalous
+	}
+
+	// gaps[i] is the gap from radial i to the next, around the scan
+	gaps := make([]float64, len(scan))
+
+	for i := range scan {
+		gaps[i] = azimuthGap(scan[i].Header.AzimuthAngle, scan[(i+1)%len(scan)].Header.AzimuthAngle)
+	}
+
+	sorted := make([]float64, len(gaps))
+	copy(sorted, gaps)
+	sort.Float64s(sorted)
+
+	limit := maxSpacingFactor * sorted[len(sorted)/2]
+
+	for i := range scan {
+		before := gaps[(i+len(scan)-1)%len(scan)]
+
+		if before > limit && gaps[i] > limit {
+			anomalous[i] = true
+		}
+	}
+
+	return anomalous
+}
+
+// dropAnomalousRadials returns the radials of a scan without those with a
+// corrupt azimuth, see anomalousRadials, logging how many were dropped.
+func dropAnomalousRadials(scan []*archive2.Message31) []*archive2.Message31 {
+	anomalous := anomalousRadials(scan)
+	kept := make([]*archive2.Message31, 0, len(scan))
+
+	for i, radial := range scan {
+		if !anomalous[i] {
+			kept = append(kept, radial)
+		}
+	}
+
+	if dropped := len(scan) - len(kept); dropped > 0 {
+		logrus.Warnf("elevation %d: dropped %d radials with anomalous azimuths", scan[0].Header.ElevationNumber, dropped)
+	}
+
+	return kept
+}
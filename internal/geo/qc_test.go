@@ -0,0 +1,68 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+)
+
+func TestAnomalousRadials(t *testing.T) {
+	tests := []struct {
+		name      string
+		azimuths  []float32
+		anomalous []int
+	}{
+		{"clean", []float32{0, 1, 2, 3, 4, 5}, nil},
+		{"out of place", []float32{0, 1, 2, 180, 4, 5}, []int{3}},
+		{"not a number", []float32{0, 1, float32(math.NaN()), 3, 4, 5}, []int{2}},
+		{"out of range", []float32{0, 1, 2, 3, 400.5, 5}, []int{4}},
+		// a gap of missing radials widens one side of each neighbor
+		{"missing", []float32{0, 1, 2, 30, 31, 32}, nil},
+		// a sector scan's ends are far apart, but near their other neighbor
+		{"sector", []float32{90, 90.5, 91, 91.5, 92}, nil},
+		{"wrapped", []float32{358, 359, 0, 1, 2}, nil},
+	}
+
+	for _, test := range tests {
+		radials := make([]*archive2.Message31, 0, len(test.azimuths))
+
+		for _, azimuth := range test.azimuths {
+			radials = append(radials, testRadial(1, azimuth, []byte{100}))
+		}
+
+		anomalous := anomalousRadials(radials)
+		expected := make([]bool, len(radials))
+
+		for _, i := range test.anomalous {
+			expected[i] = true
+		}
+
+		for i := range expected {
+			if anomalous[i] != expected[i] {
+				t.Errorf("%s: radial %d at %v: expected anomalous %v, got %v", test.name, i, test.azimuths[i], expected[i], anomalous[i])
+			}
+		}
+	}
+}
+
+func TestRadarToBinsDropAnomalousAzimuths(t *testing.T) {
+	ar2 := testArchive(1, 36, []byte{100})
+	ar2.ElevationScans[1][5].Header.AzimuthAngle = 235
+
+	scans, err := RadarToBins(ar2, &RadarToJSONOptions{Product: "REF", Elevations: []int{1}, DropAnomalousAzimuths: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scans[1]) != 35 {
+		t.Fatalf("expected 35 bins without the anomalous radial, got %d", len(scans[1]))
+	}
+
+	for _, bin := range scans[1] {
+		if bin.Azimuth == 235 {
+			t.Errorf("expected the radial at 235 degrees to be dropped")
+		}
+	}
+}
@@ -1,7 +1,12 @@
 package geo
 
 import (
+	"context"
+	"errors"
+	"fmt"
 	"math"
+	"math/rand"
+	"sort"
 	"sync"
 
 	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
@@ -10,159 +15,1059 @@ import (
 )
 
 type RadarToJSONOptions struct {
+	// Product is in upper case, e.g. VEL, which the nexrad package
+	// normalizes it to
 	Product    string
 	Minimum    *float32
 	Maximum    *float32
 	Elevations []int
+	// ValueScale and ValueOffset transform each gate's value kept after
+	// Minimum and Maximum to value * ValueScale + ValueOffset, a ValueScale
+	// of 1 if unset. Flagged gates keep their flag
+	ValueScale  *float32
+	ValueOffset float32
+	// BoundingBox drops bins entirely outside the region, if set
+	BoundingBox *BoundingBox
+	// MaxRange drops bins extending beyond this ground range in km, if set
+	MaxRange *float32
+	// FirstGate drops the gates of each radial before this index, counted
+	// from 0 at the radar, e.g. the ground clutter nearest it
+	FirstGate int
+	// LastGate drops the gates of each radial after this index, if set
+	LastGate *int
+	// Dealias unfolds aliased VEL and SRV values using the radial's Nyquist
+	// velocity
+	Dealias bool
+	// StormMotion is subtracted from velocities for SRV, which requires it
+	StormMotion *StormMotion
+	// KeepFolded keeps range folded gates as bins flagged
+	// archive2.GateFolded, rather than dropping them. Minimum and Maximum
+	// don't apply to them
+	KeepFolded bool
+	// KeepBelowThreshold keeps gates below the signal threshold as bins
+	// flagged archive2.GateBelowThreshold, as KeepFolded
+	KeepBelowThreshold bool
+	// Raw keeps the unscaled data level of each gate as its value rather
+	// than the physical value, for the products read directly from a moment.
+	// Minimum and Maximum are compared to levels, and Dealias doesn't apply
+	Raw bool
+	// Thin keeps every Nth radial and gate, widening the kept bins to cover
+	// the dropped ones, if greater than 1
+	Thin int
+	// Sample keeps each bin with this probability, if between 0 and 1, a
+	// random subset for previews that is the same on every run
+	Sample float64
+	// Center writes coordinates in meters on the plane tangent at this
+	// point, rather than longitude and latitude, if set. Radars sharing a
+	// center share a frame
+	Center *Center
+	// CRS writes coordinates in this target CRS, an authority code such as
+	// EPSG:3857 or a PROJ string, rather than WGS84 longitude and latitude,
+	// if set. Axes are ordered easting or longitude first. It can't be
+	// combined with Center
+	CRS string
+	// Pipeline is a PROJ pipeline, or any other coordinate operation, from
+	// meters on the radar's tangent plane to the output coordinates, used in
+	// place of the transform to CRS or Center if set. {lat} and {lon} are
+	// replaced by the radar's location
+	Pipeline string
+	// NoCurvature places bins along a straight beam over a flat earth, bit
+	// for bit where earlier versions did, rather than by the 4/3 effective
+	// earth radius model of refraction
+	NoCurvature bool
+	// SortAzimuth orders the radials of each scan by azimuth before they are
+	// thinned and georeferenced, rather than in the order they were recorded
+	SortAzimuth bool
+	// DropAnomalousAzimuths skips radials of each scan with a corrupt
+	// azimuth, out of place among the radials recorded around them, which
+	// would otherwise be drawn as a wedge across the sweep
+	DropAnomalousAzimuths bool
+	// Workers splits the radials of each scan, and the projection of their
+	// bins, across this many goroutines with a transform each, if greater
+	// than 1, so a single elevation uses several cores. The bins are the
+	// same for any number of workers
+	Workers int
+	// MaxFeatures stops georeferencing each elevation once every product has
+	// more than this many bins, if greater than 0, keeping the first
+	// MaxFeatures+1 of each in radial order, one past the cap so the nexrad
+	// package can cap each FeatureCollection at MaxFeatures bins and mark it
+	// truncated
+	MaxFeatures int
+	// Progress is called as each elevation finishes georeferencing, if set.
+	// Calls come from multiple goroutines but never run concurrently
+	Progress func(elevation int, bins int)
 }
 
-func RadarToBins(archive2 *archive2.Archive2, options *RadarToJSONOptions) map[int][]*Bin {
-	volumeData := archive2.ElevationScans[1][0].VolumeData
-	transforms := createTransforms(volumeData.Lat, volumeData.Lon)
+// defaultMinimums are the minimums of products whose low values are mostly
+// noise. Other products are unbounded by default, as their negative values
+// are weather, e.g. inbound VEL or ZDR of vertically oriented hydrometeors.
+var defaultMinimums = map[string]float32{
+	// correlation coefficient below 0.8 is mostly non-meteorological
+	"RHO": 0.8,
+}
+
+// DefaultMinimum returns the minimum suited to a product when none is given,
+// or nil if every value is kept.
+func DefaultMinimum(product string) *float32 {
+	if m, ok := defaultMinimums[product]; ok {
+		return &m
+	}
+
+	return nil
+}
+
+// StormMotion is the motion of a storm, from Direction in degrees, as winds
+// are given, at Speed in m/s.
+type StormMotion struct {
+	Direction float32
+	Speed     float32
+}
+
+// Center is the latitude and longitude of a projection origin.
+type Center struct {
+	Lat float32
+	Lon float32
+}
+
+// target returns the PROJ definition of the CRS output coordinates are
+// written in.
+func (options *RadarToJSONOptions) target() string {
+	if options.CRS != "" {
+		return options.CRS
+	}
+
+	if options.Center != nil {
+		return localTangentPlane(options.Center.Lat, options.Center.Lon)
+	}
+
+	return geographic
+}
+
+// geographicOutput returns true if output coordinates are WGS84 longitude and
+// latitude, which are unwrapped at the antimeridian.
+func (options *RadarToJSONOptions) geographicOutput() bool {
+	return options.CRS == "" && options.Center == nil && options.Pipeline == ""
+}
+
+// transform returns a new transform from the tangent plane of a radar at
+// lat, lon to the output coordinates.
+func (options *RadarToJSONOptions) transform(lat float32, lon float32) (*proj.PJ, error) {
+	if options.Pipeline != "" {
+		return createPipeline(lat, lon, options.Pipeline)
+	}
+
+	return createTransformTo(lat, lon, options.target())
+}
+
+// sampleSeed seeds the random subset of bins kept by Sample for each scan,
+// so previews are reproducible.
+const sampleSeed = 1
+
+// sample keeps each bin with probability options.Sample, if set, drawing from
+// rng.
+func (options *RadarToJSONOptions) sample(bins []*Bin, rng *rand.Rand) []*Bin {
+	if options.Sample <= 0 || options.Sample >= 1 {
+		return bins
+	}
+
+	kept := bins[:0]
+
+	for _, bin := range bins {
+		if rng.Float64() < options.Sample {
+			kept = append(kept, bin)
+		}
+	}
+
+	return kept
+}
+
+// workers returns the number of goroutines georeferencing each scan.
+func (options *RadarToJSONOptions) workers() int {
+	if options.Workers > 1 {
+		return options.Workers
+	}
+
+	return 1
+}
+
+// radials returns the radials of a scan in the order they are georeferenced,
+// without those with anomalous azimuths if DropAnomalousAzimuths is set, and
+// a copy sorted by azimuth if SortAzimuth is set, leaving the scan as read
+// for the other products and elevations sharing it.
+func (options *RadarToJSONOptions) radials(scan []*archive2.Message31) []*archive2.Message31 {
+	// checked in the order recorded, as neighbors in time are in azimuth
+	if options.DropAnomalousAzimuths {
+		scan = dropAnomalousRadials(scan)
+	}
+
+	if !options.SortAzimuth {
+		return scan
+	}
+
+	sorted := make([]*archive2.Message31, len(scan))
+	copy(sorted, scan)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].Header.AzimuthAngle < sorted[j].Header.AzimuthAngle
+	})
+
+	return sorted
+}
+
+// gateRange returns the indexes of the first gate kept of a radial with n
+// gates, and one past the last.
+func (options *RadarToJSONOptions) gateRange(n int) (int, int) {
+	first, last := options.FirstGate, n
+
+	if options.LastGate != nil && *options.LastGate+1 < last {
+		last = *options.LastGate + 1
+	}
+
+	return first, last
+}
 
-	georeferencedScans := make(map[int][]*Bin, len(options.Elevations))
+// transformValue returns a gate's value scaled by ValueScale and offset by
+// ValueOffset, or the gate as is if flagged.
+func (options *RadarToJSONOptions) transformValue(gate float32, flagged bool) float32 {
+	if flagged {
+		return gate
+	}
+
+	if options.ValueScale != nil {
+		gate *= *options.ValueScale
+	}
+
+	return gate + options.ValueOffset
+}
+
+// stride returns the step between kept radials and gates.
+func (options *RadarToJSONOptions) stride() int {
+	if options.Thin > 1 {
+		return options.Thin
+	}
+
+	return 1
+}
+
+func RadarToBins(archive2 *archive2.Archive2, options *RadarToJSONOptions) (map[int][]*Bin, error) {
+	scans, err := RadarToProductBins(archive2, []*RadarToJSONOptions{options})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return scans[options.Product], nil
+}
+
+// RadarToProductBins georeferences several products in a single pass over
+// each elevation, keyed by product then elevation number. Each options
+// converts its own Product with its own Minimum, Maximum and Dealias, while
+// the elevations, radar location and bin geometry settings (Elevations,
+// BoundingBox, MaxRange, FirstGate, LastGate, Thin, Sample, Center, CRS and
+// Progress) come from the first.
+// Bins of different products covering the same gate share the cost of
+// transforming their corners. Elevations without a product's moment, e.g. VEL
+// in the surveillance cuts of some VCPs, are left out of its results.
+func RadarToProductBins(archive2 *archive2.Archive2, options []*RadarToJSONOptions) (map[string]map[int][]*Bin, error) {
+	return RadarToProductBinsContext(context.Background(), archive2, options)
+}
+
+// RadarToProductBinsContext is RadarToProductBins, stopping between radials
+// and returning ctx.Err() once ctx is done.
+func RadarToProductBinsContext(ctx context.Context, archive2 *archive2.Archive2, options []*RadarToJSONOptions) (map[string]map[int][]*Bin, error) {
+	if len(options) == 0 {
+		return nil, errors.New("no products to convert")
+	}
+
+	georeferencedScans := make(map[string]map[int][]*Bin, len(options))
+
+	for _, o := range options {
+		if _, ok := georeferencedScans[o.Product]; ok {
+			return nil, fmt.Errorf("product %s given more than once", o.Product)
+		}
+
+		georeferencedScans[o.Product] = make(map[int][]*Bin, len(options[0].Elevations))
+	}
+
+	lat, lon, err := archive2.RadarLocation()
+
+	if err != nil {
+		return nil, err
+	}
+
+	shared := options[0]
 
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr error
+
+	for _, elevation := range shared.Elevations {
+		if ctx.Err() != nil {
+			break
+		}
 
-	for _, elevation := range options.Elevations {
-		if _, ok := archive2.ElevationScans[elevation]; !ok {
-			logrus.Warnf("elevation %v not present", elevation)
+		// partial files can hold an elevation without any radials
+		if len(archive2.ElevationScans[elevation]) == 0 {
+			logrus.Warnf("elevation %d has no radials, skipping", elevation)
 			continue
 		}
 
 		wg.Add(1)
 
-		go func(elevation int, transforms []*proj.PJ, options *RadarToJSONOptions) {
-			georeferencedScans[elevation] = georeferenceScan(archive2.ElevationScans[elevation], transforms, options)
-			wg.Done()
-		}(elevation, transforms, options)
+		go func(elevation int) {
+			defer wg.Done()
+
+			products, err := georeferenceProductsAt(ctx, archive2.ElevationScans[elevation], lat, lon, options)
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			if err != nil {
+				if firstErr == nil {
+					firstErr = fmt.Errorf("elevation %d: %s", elevation, err)
+				}
+
+				return
+			}
+
+			total := 0
+
+			for _, o := range options {
+				bins, ok := products[o.Product]
+
+				if !ok {
+					logrus.Warnf("elevation %d has no %s data, skipping", elevation, o.Product)
+					continue
+				}
+
+				georeferencedScans[o.Product][elevation] = bins
+				total += len(bins)
+			}
+
+			if shared.Progress != nil {
+				shared.Progress(elevation, total)
+			}
+		}(elevation)
 	}
 
 	wg.Wait()
 
-	return georeferencedScans
+	// elevations stopped by ctx fail with its error, reported unwrapped
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+
+	return georeferencedScans, nil
+}
+
+// StreamRadials is the number of radials georeferenced at a time by
+// StreamProductBinsContext and StreamScan, enough to keep the workers busy
+// while holding the bins of only a sector of a sweep.
+const StreamRadials = 60
+
+// StreamProductBinsContext georeferences several products as
+// RadarToProductBinsContext, but an elevation at a time in the order of
+// options[0].Elevations, calling fn with the bins of each product present in
+// StreamRadials radials at a time rather than collecting them, so memory
+// holds only the bins of a batch. The bins passed for an elevation, in turn,
+// are those RadarToProductBins returns for it. Progress is called as each
+// elevation finishes. An error from fn stops georeferencing and is returned.
+func StreamProductBinsContext(ctx context.Context, archive2 *archive2.Archive2, options []*RadarToJSONOptions, fn func(elevation int, products map[string][]*Bin) error) error {
+	if len(options) == 0 {
+		return errors.New("no products to convert")
+	}
+
+	seen := make(map[string]bool, len(options))
+
+	for _, o := range options {
+		if seen[o.Product] {
+			return fmt.Errorf("product %s given more than once", o.Product)
+		}
+
+		seen[o.Product] = true
+	}
+
+	lat, lon, err := archive2.RadarLocation()
+
+	if err != nil {
+		return err
+	}
+
+	shared := options[0]
+
+	for _, elevation := range shared.Elevations {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		// partial files can hold an elevation without any radials
+		if len(archive2.ElevationScans[elevation]) == 0 {
+			logrus.Warnf("elevation %d has no radials, skipping", elevation)
+			continue
+		}
+
+		present := make(map[string]bool, len(options))
+		total := 0
+
+		// returned as is, unlike the errors georeferencing
+		var fnErr error
+
+		err := streamProductsAt(ctx, archive2.ElevationScans[elevation], lat, lon, options, StreamRadials, func(products map[string][]*Bin) error {
+			for product, bins := range products {
+				present[product] = true
+				total += len(bins)
+			}
+
+			fnErr = fn(elevation, products)
+
+			return fnErr
+		})
+
+		if fnErr != nil {
+			return fnErr
+		}
+
+		// stopped by ctx, reported unwrapped
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		if err != nil {
+			return fmt.Errorf("elevation %d: %s", elevation, err)
+		}
+
+		for _, o := range options {
+			if !present[o.Product] {
+				logrus.Warnf("elevation %d has no %s data, skipping", elevation, o.Product)
+			}
+		}
+
+		if shared.Progress != nil {
+			shared.Progress(elevation, total)
+		}
+	}
+
+	return nil
+}
+
+// StreamScan georeferences a single elevation scan as GeoreferenceScan,
+// calling fn with the bins of StreamRadials radials at a time rather than
+// collecting them. An error from fn stops georeferencing and is returned.
+func StreamScan(scan []*archive2.Message31, options *RadarToJSONOptions, fn func(bins []*Bin) error) error {
+	if len(scan) == 0 {
+		return errors.New("scan contains no radials")
+	}
+
+	volumeData := scan[0].VolumeData
+	present := false
+
+	err := streamProductsAt(context.Background(), scan, volumeData.Lat, volumeData.Lon, []*RadarToJSONOptions{options}, StreamRadials, func(products map[string][]*Bin) error {
+		bins, ok := products[options.Product]
+
+		if !ok {
+			return nil
+		}
+
+		present = true
+
+		return fn(bins)
+	})
+
+	if err != nil {
+		return err
+	}
+
+	if !present {
+		return fmt.Errorf("scan has no %s data", options.Product)
+	}
+
+	return nil
+}
+
+// GeoreferenceScan georeferences a single elevation scan, using the radar
+// location reported by its first radial as the projection origin.
+func GeoreferenceScan(scan []*archive2.Message31, options *RadarToJSONOptions) ([]*Bin, error) {
+	return GeoreferenceScanContext(context.Background(), scan, options)
+}
+
+// GeoreferenceScanContext is GeoreferenceScan, stopping between radials and
+// returning ctx.Err() once ctx is done.
+func GeoreferenceScanContext(ctx context.Context, scan []*archive2.Message31, options *RadarToJSONOptions) ([]*Bin, error) {
+	if len(scan) == 0 {
+		return nil, errors.New("scan contains no radials")
+	}
+
+	volumeData := scan[0].VolumeData
+
+	return georeferenceScanAt(ctx, scan, volumeData.Lat, volumeData.Lon, options)
+}
+
+// georeferenceScanAt georeferences a scan with its own transform from the
+// radar location, so it is safe to call from multiple goroutines.
+func georeferenceScanAt(ctx context.Context, scan []*archive2.Message31, lat float32, lon float32, options *RadarToJSONOptions) ([]*Bin, error) {
+	products, err := georeferenceProductsAt(ctx, scan, lat, lon, []*RadarToJSONOptions{options})
+
+	if err != nil {
+		return nil, err
+	}
+
+	bins, ok := products[options.Product]
+
+	if !ok {
+		return nil, fmt.Errorf("scan has no %s data", options.Product)
+	}
+
+	return bins, nil
+}
+
+// georeferenceProductsAt georeferences every product of a scan with its own
+// transforms from the radar location, one per worker, as georeferenceScanAt.
+func georeferenceProductsAt(ctx context.Context, scan []*archive2.Message31, lat float32, lon float32, options []*RadarToJSONOptions) (map[string][]*Bin, error) {
+	products := make(map[string][]*Bin, len(options))
+
+	err := streamProductsAt(ctx, scan, lat, lon, options, capBatch(options), func(batch map[string][]*Bin) error {
+		for product, bins := range batch {
+			if collected, ok := products[product]; ok {
+				bins = append(collected, bins...)
+			}
+
+			products[product] = bins
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return products, nil
 }
 
-func georeferenceScan(scan []*archive2.Message31, transforms []*proj.PJ, options *RadarToJSONOptions) []*Bin {
+// streamProductsAt georeferences every product of a scan batch radials at a
+// time, as georeferenceBatches, with its own transforms from the radar
+// location, one per worker.
+func streamProductsAt(ctx context.Context, scan []*archive2.Message31, lat float32, lon float32, options []*RadarToJSONOptions, batch int, fn func(products map[string][]*Bin) error) error {
+	workers := options[0].workers()
+
+	// no more workers than radials
+	if workers > len(scan) {
+		workers = len(scan)
+	}
+
+	if workers < 1 {
+		workers = 1
+	}
+
+	transforms := make([]*proj.PJ, workers)
+
+	for i := range transforms {
+		transform, err := options[0].transform(lat, lon)
+
+		if err != nil {
+			return err
+		}
+
+		defer transform.Destroy()
+
+		transforms[i] = transform
+	}
+
+	return georeferenceBatches(ctx, scan, transforms, options, batch, fn)
+}
+
+// RelativeScanBins returns the bins of a scan with corners in meters relative
+// to the radar, before projection and any BoundingBox, e.g. to count or
+// inspect values cheaply without PROJ. Radials without the product's moment
+// are skipped. Sample keeps the same bins it would when georeferencing.
+func RelativeScanBins(scan []*archive2.Message31, options *RadarToJSONOptions) ([]*Bin, error) {
 	bins := make([]*Bin, 0)
+	rng := rand.New(rand.NewSource(sampleSeed))
+	scan = options.radials(scan)
+
+	for i := 0; i < len(scan); i += options.stride() {
+		if !scan[i].HasMoment(options.Product) {
+			continue
+		}
+
+		relativeBins, err := radialToRelativePoints(scan[i], options)
+
+		if err != nil {
+			return nil, err
+		}
+
+		bins = append(bins, options.sample(relativeBins, rng)...)
+	}
+
+	return bins, nil
+}
+
+// georeferenceBatches georeferences every product of a scan batch radials at
+// a time, or every radial at once if batch is 0, calling fn with the bins of
+// each product present in the batch's radials. Each batch is
+// georeferenced by georeferenceRadials, sampling from one source for the
+// whole scan, so the bins don't depend on the number of workers or the batch
+// size.
+func georeferenceBatches(ctx context.Context, scan []*archive2.Message31, transforms []*proj.PJ, options []*RadarToJSONOptions, batch int, fn func(products map[string][]*Bin) error) error {
+	shared := options[0]
+	scan = shared.radials(scan)
+
+	radials := make([]*archive2.Message31, 0, len(scan)/shared.stride()+1)
+
+	for i := 0; i < len(scan); i += shared.stride() {
+		radials = append(radials, scan[i])
+	}
+
+	if batch <= 0 {
+		batch = len(radials)
+	}
+
+	rng := rand.New(rand.NewSource(sampleSeed))
+
+	// the bins kept of each product, at most one past its MaxFeatures
+	kept := make(map[string]int, len(options))
+
+	for start := 0; start < len(radials); start += batch {
+		end := start + batch
+
+		if end > len(radials) {
+			end = len(radials)
+		}
+
+		products, err := georeferenceRadials(ctx, radials[start:end], transforms, options, rng)
 
-	for _, radial := range scan {
-		relativeBins := radialToRelativePoints(radial, options)
+		if err != nil {
+			return err
+		}
+
+		capped := true
+
+		for _, o := range options {
+			bins := products[o.Product]
 
-		bins = append(bins, relativeBins...)
+			if o.MaxFeatures > 0 && kept[o.Product]+len(bins) > o.MaxFeatures+1 {
+				products[o.Product] = bins[:o.MaxFeatures+1-kept[o.Product]]
+			}
+
+			kept[o.Product] += len(products[o.Product])
+
+			if o.MaxFeatures <= 0 || kept[o.Product] <= o.MaxFeatures {
+				capped = false
+			}
+		}
+
+		if err := fn(products); err != nil {
+			return err
+		}
+
+		// the remaining radials would only add bins past every cap
+		if capped {
+			break
+		}
 	}
 
-	relativeBinsToGeographicBins(transforms, bins)
+	return nil
+}
 
-	return bins
+// capBatch returns the number of radials georeferenced at a time to convert
+// a whole scan, batches of StreamRadials if MaxFeatures may stop it early, or
+// 0 for every radial at once.
+func capBatch(options []*RadarToJSONOptions) int {
+	for _, o := range options {
+		if o.MaxFeatures > 0 {
+			return StreamRadials
+		}
+	}
+
+	return 0
 }
 
-func radialToRelativePoints(radial *archive2.Message31, options *RadarToJSONOptions) []*Bin {
+// georeferenceRadials georeferences every product of consecutive radials,
+// splitting the radials and then the bins across a worker per transform.
+// Bins are sampled from rng and collected in radial order between the two,
+// so they don't depend on the number of workers.
+func georeferenceRadials(ctx context.Context, radials []*archive2.Message31, transforms []*proj.PJ, options []*RadarToJSONOptions, rng *rand.Rand) (map[string][]*Bin, error) {
+	shared := options[0]
+	products := make(map[string][]*Bin, len(options))
+	bins := make([]*Bin, 0)
+
+	// the bins of each product, by radial, nil for radials lacking its
+	// moment
+	relative := make([][][]*Bin, len(radials))
+
+	err := split(len(radials), len(transforms), func(worker int, start int, end int) error {
+		for i := start; i < end; i++ {
+			if err := ctx.Err(); err != nil {
+				return err
+			}
+
+			relative[i] = make([][]*Bin, len(options))
+
+			for j, o := range options {
+				if !radials[i].HasMoment(o.Product) {
+					continue
+				}
+
+				relativeBins, err := radialToRelativePoints(radials[i], o)
+
+				if err != nil {
+					return err
+				}
+
+				relative[i][j] = relativeBins
+			}
+		}
+
+		return nil
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	// radials lacking a product's moment are skipped, and products without
+	// any are left out
+	present := make(map[string]bool, len(options))
+
+	for i := range radials {
+		for j, o := range options {
+			if relative[i][j] == nil {
+				continue
+			}
+
+			// sampled before projection, sparing the transforms of dropped bins
+			relativeBins := shared.sample(relative[i][j], rng)
+
+			present[o.Product] = true
+			products[o.Product] = append(products[o.Product], relativeBins...)
+			bins = append(bins, relativeBins...)
+		}
+	}
+
+	err = split(len(bins), len(transforms), func(worker int, start int, end int) error {
+		if len(options) > 1 {
+			return sharedBinsToGeographicBins(transforms[worker], bins[start:end])
+		}
+
+		return relativeBinsToGeographicBins(transforms[worker], bins[start:end])
+	})
+
+	if err != nil {
+		return nil, err
+	}
+
+	if shared.geographicOutput() {
+		for _, bin := range bins {
+			bin.unwrapLongitudes()
+		}
+	}
+
+	for _, o := range options {
+		if !present[o.Product] {
+			delete(products, o.Product)
+			continue
+		}
+
+		if products[o.Product] == nil {
+			products[o.Product] = make([]*Bin, 0)
+		}
+
+		if shared.BoundingBox != nil {
+			products[o.Product] = filterBins(products[o.Product], shared.BoundingBox)
+		}
+	}
+
+	return products, nil
+}
+
+// split calls fn from a goroutine per worker with consecutive ranges of n
+// items, returning the first error.
+func split(n int, workers int, fn func(worker int, start int, end int) error) error {
+	if workers <= 1 {
+		return fn(0, 0, n)
+	}
+
+	var wg sync.WaitGroup
+	errs := make([]error, workers)
+
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+
+		go func(w int) {
+			defer wg.Done()
+
+			errs[w] = fn(w, w*n/workers, (w+1)*n/workers)
+		}(w)
+	}
+
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func radialToRelativePoints(radial *archive2.Message31, options *RadarToJSONOptions) ([]*Bin, error) {
 	azimuth := radial.Header.AzimuthAngle
 	elevation := radial.Header.ElevationAngle
 
-	gates, err := radial.ScaledDataForProduct(options.Product)
+	moment, err := radial.DataMomentForProduct(options.Product)
 
 	if err != nil {
-		logrus.Fatalln(err)
+		return nil, err
 	}
 
-	firstGateDist := float64(radial.ReflectivityData.DataMomentRange)
-	gateIncrement := float64(radial.ReflectivityData.DataMomentRangeSampleInterval)
+	var gates *[]float32
 
-	phi := 90 - elevation
-	phi_radians := float64(phi * (math.Pi / 180))
+	// raw levels are flagged apart, as they may be as high as the sentinels
+	// of scaled values
+	var rawFlags []archive2.GateFlag
 
-	theta := 90 - azimuth
+	if options.Raw {
+		gates, rawFlags, err = radial.RawDataForProduct(options.Product)
+	} else {
+		gates, err = radial.ScaledDataForProduct(options.Product)
+	}
 
-	if theta < 0 {
-		theta += 360
+	if err != nil {
+		return nil, err
+	}
+
+	if options.Dealias && (options.Product == "VEL" || options.Product == "SRV") && !options.Raw {
+		dealiased := archive2.Dealias(*gates, radial.RadialData.Nyquist())
+		gates = &dealiased
+	}
+
+	if options.Product == "SRV" {
+		if options.StormMotion == nil {
+			return nil, errors.New("SRV requires a storm motion")
+		}
+
+		srv := archive2.StormRelativeVelocity(*gates, azimuth, options.StormMotion.Direction, options.StormMotion.Speed)
+		gates = &srv
 	}
 
-	thetaRadians := float64(theta * (math.Pi / 180))
+	firstGateDist := float64(moment.DataMomentRange)
+	gateIncrement := float64(moment.DataMomentRangeSampleInterval)
+
+	// convert to float64 before scaling, float32 angles would misplace the
+	// edges shared by adjacent radials by centimeters at long ranges
+	elevationRadians := float64(elevation) * (math.Pi / 180)
 
-	r := firstGateDist
+	thetaRadians := azimuthToTheta(azimuth) * (math.Pi / 180)
 
-	radarRelativeBins := make([]*Bin, 0)
+	// the straight beam of earlier versions, as they computed it
+	var sinPhi, cosPhi float64
+
+	if options.NoCurvature {
+		thetaRadians, sinPhi, cosPhi = straightBeam(azimuth, elevation)
+	}
 
-	halfAzimuthSpacingRadians := radial.Header.AzimuthResolutionSpacing() * (math.Pi / 360)
+	stride := options.stride()
 
-	sinPhi := math.Sin(phi_radians)
-	cosPhi := math.Cos(phi_radians)
+	first, last := options.gateRange(len(*gates))
+	r := firstGateDist + gateIncrement*float64(first)
 
-	for _, gate := range *gates {
-		r2 := r + gateIncrement
+	// bins and their corners are carved from one block each per radial,
+	// rather than allocated per gate
+	capacity := 0
 
-		if gate == archive2.MomentDataBelowThreshold || gate == archive2.MomentDataFolded {
+	if last > first {
+		capacity = (last - first + stride - 1) / stride
+	}
+	radarRelativeBins := make([]*Bin, 0, capacity)
+	binBlock := make([]Bin, capacity)
+	coordBlock := make([]proj.Coord, 4*capacity)
+
+	halfAzimuthSpacingRadians := halfSpacingRadians(radial.Header.AzimuthResolutionSpacing() * float64(stride))
+
+	for i := first; i < last; i += stride {
+		gate := (*gates)[i]
+		r2 := r + gateIncrement*float64(stride)
+
+		if options.MaxRange != nil {
+			// gates are ordered by range, so no further gates can be in range
+			if ground, _ := options.beamPosition(r2, elevationRadians); ground > float64(*options.MaxRange)*1000 {
+				break
+			}
+		}
+
+		// read before the value is transformed, which may land on the
+		// sentinels
+		flag := archive2.ScaledFlag(gate)
+
+		if rawFlags != nil {
+			flag = rawFlags[i]
+		}
+
+		folded := flag == archive2.GateFolded
+		belowThreshold := flag == archive2.GateBelowThreshold
+		flagged := flag != archive2.GateValid
+
+		if (folded && !options.KeepFolded) || (belowThreshold && !options.KeepBelowThreshold) {
 			r = r2
 			continue
 		}
 
-		if options.Minimum != nil && gate < *options.Minimum {
+		if !flagged && options.Minimum != nil && gate < *options.Minimum {
 			r = r2
 			continue
 		}
 
-		if options.Maximum != nil && gate > *options.Maximum {
+		if !flagged && options.Maximum != nil && gate > *options.Maximum {
 			r = r2
 			continue
 		}
 
+		ground, height := options.beamPosition(r, elevationRadians)
+		ground2, height2 := options.beamPosition(r2, elevationRadians)
+
+		rho := options.planeRadius(ground)
+		rho2 := options.planeRadius(ground2)
+
+		if options.NoCurvature {
+			rho, height = r*sinPhi, r*cosPhi
+			rho2, height2 = r2*sinPhi, r2*cosPhi
+		}
+
 		// From radar's point of view:
 		// - bottom left
 		// - bottom right
 		// - top left
 		// - top right
 		point1 := proj.NewCoord(
-			r*sinPhi*math.Cos(thetaRadians+halfAzimuthSpacingRadians),
-			r*sinPhi*math.Sin(thetaRadians+halfAzimuthSpacingRadians),
-			r*cosPhi,
+			rho*math.Cos(thetaRadians+halfAzimuthSpacingRadians),
+			rho*math.Sin(thetaRadians+halfAzimuthSpacingRadians),
+			height,
 			0,
 		)
 
 		point2 := proj.NewCoord(
-			r*sinPhi*math.Cos(thetaRadians-halfAzimuthSpacingRadians),
-			r*sinPhi*math.Sin(thetaRadians-halfAzimuthSpacingRadians),
-			r*cosPhi,
+			rho*math.Cos(thetaRadians-halfAzimuthSpacingRadians),
+			rho*math.Sin(thetaRadians-halfAzimuthSpacingRadians),
+			height,
 			0,
 		)
 
 		point3 := proj.NewCoord(
-			r2*sinPhi*math.Cos(thetaRadians+halfAzimuthSpacingRadians),
-			r2*sinPhi*math.Sin(thetaRadians+halfAzimuthSpacingRadians),
-			r2*cosPhi,
+			rho2*math.Cos(thetaRadians+halfAzimuthSpacingRadians),
+			rho2*math.Sin(thetaRadians+halfAzimuthSpacingRadians),
+			height2,
 			0,
 		)
 
 		point4 := proj.NewCoord(
-			r2*sinPhi*math.Cos(thetaRadians-halfAzimuthSpacingRadians),
-			r2*sinPhi*math.Sin(thetaRadians-halfAzimuthSpacingRadians),
-			r2*cosPhi,
+			rho2*math.Cos(thetaRadians-halfAzimuthSpacingRadians),
+			rho2*math.Sin(thetaRadians-halfAzimuthSpacingRadians),
+			height2,
 			0,
 		)
 
-		bin := NewBin(point1, point2, point3, point4, gate)
+		n := len(radarRelativeBins)
+		coords := coordBlock[4*n : 4*n+4 : 4*n+4]
+		coords[0], coords[1], coords[2], coords[3] = point1, point2, point3, point4
+
+		bin := &binBlock[n]
+		bin.Coords = coords
+		bin.Value = options.transformValue(gate, flagged)
+		bin.GateFlag = flag
+		bin.Elevation = int(radial.Header.ElevationNumber)
+		bin.ElevationAngle = elevation
+		_, bin.Height = options.beamPosition((r+r2)/2, elevationRadians)
+		bin.Azimuth = azimuth
+		bin.Range = (r + r2) / 2
 
 		radarRelativeBins = append(radarRelativeBins, bin)
 
 		r = r2
 	}
 
-	return radarRelativeBins
+	return radarRelativeBins, nil
+}
+
+// halfSpacingRadians returns half of an azimuth spacing in degrees, in
+// radians: spacing / 2 * π / 180, folded into a single π / 360. Each bin
+// extends this far either side of its radial.
+func halfSpacingRadians(spacingDegrees float64) float64 {
+	return spacingDegrees * (math.Pi / 360)
+}
+
+// azimuthToTheta converts a compass azimuth, clockwise from north, to a math
+// angle, counterclockwise from east, in [0, 360). Azimuths reported as 360
+// or more, or below 0, wrap to the same angle as their equivalent in range.
+func azimuthToTheta(azimuth float32) float64 {
+	theta := math.Mod(90-float64(azimuth), 360)
+
+	if theta < 0 {
+		theta += 360
+	}
+
+	return theta
 }
 
-func relativeBinsToGeographicBins(transforms []*proj.PJ, relativeBins []*Bin) {
-	allCoords := make([]proj.Coord, 0)
+// relativeBinsToGeographicBins transforms the corners of every bin in a
+// scan with a single batched PROJ call, rather than per bin, failing if any
+// can't be transformed.
+func relativeBinsToGeographicBins(transform *proj.PJ, relativeBins []*Bin) error {
+	allCoords := make([]proj.Coord, 0, len(relativeBins)*4)
 
 	for _, bin := range relativeBins {
 		allCoords = append(allCoords, bin.Coords...)
 	}
 
-	for _, t := range transforms {
-		t.ForwardArray(allCoords)
+	if err := forwardArray(transform, allCoords); err != nil {
+		return err
+	}
+
+	for i, bin := range relativeBins {
+		bin.Coords = allCoords[(i * 4):(i*4 + 4):(i*4 + 4)]
+	}
+
+	return nil
+}
+
+// sharedBinsToGeographicBins transforms each distinct set of corners once,
+// for bins of several products covering the same gates. Every bin gets its
+// own copy of the transformed corners, as unwrapping longitudes modifies
+// them. It fails, as relativeBinsToGeographicBins, if any can't be
+// transformed.
+func sharedBinsToGeographicBins(transform *proj.PJ, relativeBins []*Bin) error {
+	index := make(map[[4]proj.Coord]int)
+	uniqueCoords := make([]proj.Coord, 0)
+	slots := make([]int, len(relativeBins))
+
+	for i, bin := range relativeBins {
+		var corners [4]proj.Coord
+		copy(corners[:], bin.Coords)
+
+		slot, ok := index[corners]
+
+		if !ok {
+			slot = len(uniqueCoords) / 4
+			index[corners] = slot
+			uniqueCoords = append(uniqueCoords, corners[:]...)
+		}
+
+		slots[i] = slot
+	}
+
+	if err := forwardArray(transform, uniqueCoords); err != nil {
+		return err
 	}
 
 	for i, bin := range relativeBins {
-		bin.Coords = allCoords[(i * 4):(i*4 + 4)]
+		coords := make(Poly, 4)
+		copy(coords, uniqueCoords[slots[i]*4:])
+		bin.Coords = coords
 	}
+
+	return nil
 }
@@ -0,0 +1,898 @@
+package geo
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+)
+
+var update = flag.Bool("update", false, "regenerate golden files in testdata")
+
+func testRadial(elevationNumber uint8, azimuth float32, gates []byte) *archive2.Message31 {
+	return &archive2.Message31{
+		Header: archive2.Message31Header{
+			AzimuthAngle:                 azimuth,
+			AzimuthResolutionSpacingCode: 2,
+			ElevationNumber:              elevationNumber,
+			ElevationAngle:               0.5 * float32(elevationNumber),
+		},
+		VolumeData: archive2.VolumeData{
+			Lat: 39.7866,
+			Lon: -104.5458,
+		},
+		ReflectivityData: &archive2.DataMoment{
+			GenericDataMoment: archive2.GenericDataMoment{
+				NumberDataMomentGates:         uint16(len(gates)),
+				DataMomentRange:               2125,
+				DataMomentRangeSampleInterval: 250,
+				DataWordSize:                  8,
+				Scale:                         2,
+				Offset:                        66,
+			},
+			Data: gates,
+		},
+	}
+}
+
+func testArchive(elevations int, radials int, gates []byte) *archive2.Archive2 {
+	ar2 := &archive2.Archive2{
+		ElevationScans: make(map[int][]*archive2.Message31),
+	}
+
+	for e := 1; e <= elevations; e++ {
+		for a := 0; a < radials; a++ {
+			ar2.ElevationScans[e] = append(ar2.ElevationScans[e], testRadial(uint8(e), float32(a)*360/float32(radials), gates))
+		}
+	}
+
+	return ar2
+}
+
+// Run with -race to catch concurrent writes to the per-elevation results.
+func TestRadarToBinsConcurrentElevations(t *testing.T) {
+	ar2 := testArchive(10, 36, []byte{0, 1, 100, 150, 200})
+
+	opts := &RadarToJSONOptions{
+		Product:    "REF",
+		Elevations: []int{1, 2, 3, 4, 5, 6, 7, 8, 9, 10},
+	}
+
+	bins, err := RadarToBins(ar2, opts)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bins) != len(opts.Elevations) {
+		t.Fatalf("expected %d elevations, got %d", len(opts.Elevations), len(bins))
+	}
+
+	for _, elevation := range opts.Elevations {
+		// 36 radials, 3 of 5 gates above threshold
+		if len(bins[elevation]) != 36*3 {
+			t.Errorf("elevation %d: expected %d bins, got %d", elevation, 36*3, len(bins[elevation]))
+		}
+	}
+}
+
+func TestRadialBinHeight(t *testing.T) {
+	radial := testRadial(1, 0, []byte{100, 100})
+
+	bins, err := radialToRelativePoints(radial, &RadarToJSONOptions{Product: "REF"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bins) != 2 {
+		t.Fatalf("expected 2 bins, got %d", len(bins))
+	}
+
+	// beam center of the first gate, 2250 m along a 0.5 degree beam
+	_, want := beamPosition(2250, 0.5*math.Pi/180)
+
+	if math.Abs(bins[0].Height-want) > 0.01 {
+		t.Errorf("expected height %v, got %v", want, bins[0].Height)
+	}
+
+	if bins[1].Height <= bins[0].Height {
+		t.Errorf("expected height to increase with range, got %v then %v", bins[0].Height, bins[1].Height)
+	}
+}
+
+func TestRadialGateRange(t *testing.T) {
+	radial := testRadial(1, 0, []byte{100, 100, 100, 100, 100})
+
+	all, err := radialToRelativePoints(radial, &RadarToJSONOptions{Product: "REF"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	last := 3
+	bins, err := radialToRelativePoints(radial, &RadarToJSONOptions{Product: "REF", FirstGate: 1, LastGate: &last})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bins) != 3 {
+		t.Fatalf("expected gates 1 to 3, got %d bins", len(bins))
+	}
+
+	if bins[0].Range != all[1].Range || bins[2].Range != all[3].Range {
+		t.Errorf("expected the ranges of gates 1 and 3, got %v and %v", bins[0].Range, bins[2].Range)
+	}
+
+	// past the end of the radial
+	if bins, _ := radialToRelativePoints(radial, &RadarToJSONOptions{Product: "REF", FirstGate: 10}); len(bins) != 0 {
+		t.Errorf("expected no bins beyond the last gate, got %d", len(bins))
+	}
+}
+
+// Elevations without the moment are skipped, converting the rest.
+func TestRadarToBinsMissingMoment(t *testing.T) {
+	ar2 := testArchive(2, 36, []byte{100})
+
+	// the test radials only contain reflectivity, give elevation 2 velocity
+	for _, radial := range ar2.ElevationScans[2] {
+		radial.VelocityData = radial.ReflectivityData
+	}
+
+	scans, err := RadarToBins(ar2, &RadarToJSONOptions{Product: "VEL", Elevations: []int{1, 2}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := scans[1]; ok {
+		t.Error("expected elevation 1, without velocity, to be left out")
+	}
+
+	if len(scans[2]) != 36 {
+		t.Errorf("expected 36 bins for elevation 2, got %d", len(scans[2]))
+	}
+
+	if _, err := GeoreferenceScan(ar2.ElevationScans[1], &RadarToJSONOptions{Product: "VEL"}); err == nil {
+		t.Error("expected an error georeferencing a scan without the moment")
+	}
+}
+
+// Elevations present without any radials, as in partial files, are skipped.
+func TestRadarToBinsEmptyElevation(t *testing.T) {
+	ar2 := testArchive(2, 36, []byte{100})
+	ar2.ElevationScans[2] = ar2.ElevationScans[2][:0]
+
+	scans, err := RadarToBins(ar2, &RadarToJSONOptions{Product: "REF", Elevations: []int{1, 2}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := scans[2]; ok {
+		t.Error("expected elevation 2, without radials, to be left out")
+	}
+
+	if len(scans[1]) != 36 {
+		t.Errorf("expected 36 bins for elevation 1, got %d", len(scans[1]))
+	}
+
+	if _, err := GeoreferenceScan(ar2.ElevationScans[2], &RadarToJSONOptions{Product: "REF"}); err == nil {
+		t.Error("expected an error georeferencing a scan without radials")
+	}
+
+	if _, err := ArchiveCoverage(ar2, &RadarToJSONOptions{Product: "REF", Elevations: []int{1, 2}}); err != nil {
+		t.Errorf("expected the coverage to skip elevation 2, got %v", err)
+	}
+}
+
+func TestRadarToBinsSortAzimuth(t *testing.T) {
+	ar2 := testArchive(1, 36, []byte{100})
+	scan := ar2.ElevationScans[1]
+
+	// recorded out of order
+	for i, j := 0, len(scan)-1; i < j; i, j = i+1, j-1 {
+		scan[i], scan[j] = scan[j], scan[i]
+	}
+
+	scans, err := RadarToBins(ar2, &RadarToJSONOptions{Product: "REF", Elevations: []int{1}, SortAzimuth: true})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(scans[1]) != 36 {
+		t.Fatalf("expected 36 bins, got %d", len(scans[1]))
+	}
+
+	for i := 1; i < len(scans[1]); i++ {
+		if scans[1][i].Azimuth < scans[1][i-1].Azimuth {
+			t.Fatalf("expected bins in azimuth order, got %v after %v", scans[1][i].Azimuth, scans[1][i-1].Azimuth)
+		}
+	}
+
+	if scan[0].Header.AzimuthAngle != 350 {
+		t.Errorf("expected the scan left as recorded, got %v first", scan[0].Header.AzimuthAngle)
+	}
+}
+
+// A single pass over several products must produce the same bins as
+// converting each on its own, with each product's own value filter.
+func TestRadarToProductBins(t *testing.T) {
+	ar2 := testArchive(2, 36, []byte{0, 1, 100, 150, 200})
+
+	for _, scan := range ar2.ElevationScans {
+		for _, radial := range scan {
+			velocity := *radial.ReflectivityData
+			velocity.Data = []byte{129, 0, 139, 150, 1}
+			radial.VelocityData = &velocity
+		}
+	}
+
+	minimum := float32(20)
+	elevations := []int{1, 2}
+
+	options := []*RadarToJSONOptions{
+		{Product: "REF", Minimum: &minimum, Elevations: elevations},
+		{Product: "VEL", Elevations: elevations},
+	}
+
+	products, err := RadarToProductBins(ar2, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, o := range options {
+		expected, err := RadarToBins(ar2, o)
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, elevation := range elevations {
+			bins := products[o.Product][elevation]
+
+			if len(bins) != len(expected[elevation]) {
+				t.Fatalf("%v elevation %d: expected %d bins, got %d", o.Product, elevation, len(expected[elevation]), len(bins))
+			}
+
+			for i := range bins {
+				if bins[i].Value != expected[elevation][i].Value {
+					t.Errorf("%v bin %d: expected value %v, got %v", o.Product, i, expected[elevation][i].Value, bins[i].Value)
+				}
+
+				for j := range bins[i].Coords {
+					if bins[i].Coords[j] != expected[elevation][i].Coords[j] {
+						t.Errorf("%v bin %d corner %d: expected %v, got %v", o.Product, i, j, expected[elevation][i].Coords[j], bins[i].Coords[j])
+					}
+				}
+			}
+		}
+	}
+
+	// 2 of 3 REF gates are above 20 dBZ, 3 VEL gates are valid
+	if n := len(products["REF"][1]); n != 36*2 {
+		t.Errorf("expected %d REF bins, got %d", 36*2, n)
+	}
+
+	if n := len(products["VEL"][1]); n != 36*3 {
+		t.Errorf("expected %d VEL bins, got %d", 36*3, n)
+	}
+
+	if _, err := RadarToProductBins(ar2, []*RadarToJSONOptions{options[0], options[0]}); err == nil {
+		t.Error("expected an error for a repeated product")
+	}
+}
+
+func TestDefaultMinimum(t *testing.T) {
+	// only RHO has a default, the rest keep negative values such as inbound
+	// velocities
+	expected := map[string]*float32{
+		"REF":     nil,
+		"VEL":     nil,
+		"SW":      nil,
+		"ZDR":     nil,
+		"PHI":     nil,
+		"KDP":     nil,
+		"RHO":     float32Ptr(0.8),
+		"REFGRAD": nil,
+	}
+
+	for product, e := range expected {
+		m := DefaultMinimum(product)
+
+		if (m == nil) != (e == nil) || (m != nil && *m != *e) {
+			t.Errorf("%v: expected default minimum %v, got %v", product, e, m)
+		}
+	}
+
+	// without a minimum, inbound velocities are kept
+	ar2 := testArchive(1, 4, []byte{0, 1, 100, 150, 200})
+
+	for _, radial := range ar2.ElevationScans[1] {
+		velocity := *radial.ReflectivityData
+		velocity.Data = []byte{10, 66, 120}
+		radial.VelocityData = &velocity
+	}
+
+	bins, err := RadarToBins(ar2, &RadarToJSONOptions{Product: "VEL", Minimum: DefaultMinimum("VEL"), Elevations: []int{1}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(bins[1]) != 4*3 {
+		t.Fatalf("expected %d bins, got %d", 4*3, len(bins[1]))
+	}
+
+	if bins[1][0].Value != -28 {
+		t.Errorf("expected an inbound velocity of -28, got %v", bins[1][0].Value)
+	}
+}
+
+func TestStormRelativeVelocity(t *testing.T) {
+	ar2 := testArchive(1, 4, []byte{0, 1, 100, 150, 200})
+
+	for _, radial := range ar2.ElevationScans[1] {
+		velocity := *radial.ReflectivityData
+		velocity.Data = []byte{86}
+		radial.VelocityData = &velocity
+	}
+
+	// 10 m/s outbound everywhere, with a storm from the north at 10 m/s
+	options := &RadarToJSONOptions{Product: "SRV", Elevations: []int{1}, StormMotion: &StormMotion{Direction: 0, Speed: 10}}
+
+	bins, err := RadarToBins(ar2, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// radials toward 0, 90, 180 and 270 degrees
+	expected := []float32{20, 10, 0, 10}
+
+	for i, bin := range bins[1] {
+		if math.Abs(float64(bin.Value-expected[i])) > 1e-4 {
+			t.Errorf("radial %d: expected %v, got %v", i, expected[i], bin.Value)
+		}
+	}
+
+	options.StormMotion = nil
+
+	if _, err := RadarToBins(ar2, options); err == nil {
+		t.Errorf("expected an error without a storm motion")
+	}
+}
+
+func float32Ptr(v float32) *float32 {
+	return &v
+}
+
+func TestRadialToRelativePoints(t *testing.T) {
+	minimum := float32(10)
+	maximum := float32(20)
+	level := float32(100)
+
+	tests := []struct {
+		name     string
+		gates    []byte
+		options  RadarToJSONOptions
+		expected int
+	}{
+		{"all valid", []byte{76, 86, 106, 146}, RadarToJSONOptions{Product: "REF"}, 4},
+		{"below threshold", []byte{0, 86, 0, 146}, RadarToJSONOptions{Product: "REF"}, 2},
+		{"range folded", []byte{1, 1, 106, 146}, RadarToJSONOptions{Product: "REF"}, 2},
+		{"no gates", []byte{}, RadarToJSONOptions{Product: "REF"}, 0},
+		{"minimum", []byte{76, 86, 106, 146}, RadarToJSONOptions{Product: "REF", Minimum: &minimum}, 3},
+		{"minimum and maximum", []byte{76, 86, 106, 146}, RadarToJSONOptions{Product: "REF", Minimum: &minimum, Maximum: &maximum}, 2},
+		{"keep folded", []byte{1, 1, 106, 146}, RadarToJSONOptions{Product: "REF", KeepFolded: true}, 4},
+		{"keep folded outside minimum", []byte{1, 76, 106, 146}, RadarToJSONOptions{Product: "REF", KeepFolded: true, Minimum: &minimum, Maximum: &maximum}, 2},
+		{"keep below threshold", []byte{0, 1, 106, 146}, RadarToJSONOptions{Product: "REF", KeepBelowThreshold: true}, 3},
+		{"keep both", []byte{0, 1, 106, 146}, RadarToJSONOptions{Product: "REF", KeepBelowThreshold: true, KeepFolded: true}, 4},
+		{"raw minimum", []byte{76, 86, 106, 146}, RadarToJSONOptions{Product: "REF", Raw: true, Minimum: &level}, 2},
+	}
+
+	for _, test := range tests {
+		bins, err := radialToRelativePoints(testRadial(1, 45, test.gates), &test.options)
+
+		if err != nil {
+			t.Errorf("%s: %s", test.name, err)
+			continue
+		}
+
+		if len(bins) != test.expected {
+			t.Errorf("%s: expected %d bins, got %d", test.name, test.expected, len(bins))
+		}
+	}
+
+	bins, err := radialToRelativePoints(testRadial(1, 45, []byte{76, 86}), &RadarToJSONOptions{Product: "REF"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the second gate spans 2375 to 2625 m
+	if bins[1].Azimuth != 45 || bins[1].Range != 2500 {
+		t.Errorf("expected azimuth 45 and range 2500 m, got %v and %v", bins[1].Azimuth, bins[1].Range)
+	}
+}
+
+// TestFixtureGolden compares the radar relative corners of every bin in the
+// fixture archive with testdata/fixture_bins.golden, regenerated with -update.
+// The corners are compared before projection, so the golden file does not
+// depend on the installed PROJ version.
+func TestFixtureGolden(t *testing.T) {
+	f, err := os.Open("../archive2/testdata/fixture.ar2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ar2 := archive2.Extract(f)
+
+	var b strings.Builder
+
+	for _, radial := range ar2.ElevationScans[1] {
+		bins, err := radialToRelativePoints(radial, &RadarToJSONOptions{Product: "REF"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, bin := range bins {
+			fmt.Fprintf(&b, "%.1f", bin.Value)
+
+			for _, c := range bin.Coords {
+				fmt.Fprintf(&b, " %.1f,%.1f,%.1f", c.X(), c.Y(), c.Z())
+			}
+
+			fmt.Fprintln(&b)
+		}
+	}
+
+	golden := "testdata/fixture_bins.golden"
+
+	if *update {
+		if err := os.WriteFile(golden, []byte(b.String()), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != string(expected) {
+		t.Errorf("bins differ from %s:\n%s", golden, b.String())
+	}
+}
+
+// NoCurvature must place the fixture's bins bit for bit where versions before
+// the 4/3 effective earth radius model did, as recorded in the golden file by
+// them, from which the same transform gives the same positions.
+func TestNoCurvatureGolden(t *testing.T) {
+	f, err := os.Open("../archive2/testdata/fixture.ar2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ar2 := archive2.Extract(f)
+
+	var b strings.Builder
+
+	for _, radial := range ar2.ElevationScans[1] {
+		bins, err := radialToRelativePoints(radial, &RadarToJSONOptions{Product: "REF", NoCurvature: true})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// every digit, as any difference in the last bit is a different
+		// position
+		for _, bin := range bins {
+			fmt.Fprintf(&b, "%v", bin.Value)
+
+			for _, c := range bin.Coords {
+				fmt.Fprintf(&b, " %v,%v,%v", c.X(), c.Y(), c.Z())
+			}
+
+			fmt.Fprintln(&b)
+		}
+	}
+
+	golden := "testdata/fixture_bins_no_curvature.golden"
+
+	expected, err := os.ReadFile(golden)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if b.String() != string(expected) {
+		t.Errorf("bins differ from %s:\n%s", golden, b.String())
+	}
+}
+
+// Super-resolution sweeps have 720 radials 0.5 degrees apart. Each bin must
+// share its side edges with the bins of the neighboring radials, leaving no
+// gaps or overlaps around the sweep.
+func TestSuperResolutionSweep(t *testing.T) {
+	gates := make([]byte, 1840)
+
+	for i := range gates {
+		gates[i] = 100
+	}
+
+	radials := make([][]*Bin, 720)
+
+	for i := range radials {
+		radial := testRadial(1, float32(i)*0.5, gates)
+		radial.Header.AzimuthResolutionSpacingCode = 1
+
+		bins, err := radialToRelativePoints(radial, &RadarToJSONOptions{Product: "REF"})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		radials[i] = bins
+	}
+
+	assertSharedEdges(t, radials)
+}
+
+// assertSharedEdges checks that the clockwise edge, B and D, of every bin is
+// the counterclockwise edge, A and C, of the bin at the same gate in the next
+// radial, wrapping from the last radial to the first.
+func assertSharedEdges(t *testing.T, radials [][]*Bin) {
+	t.Helper()
+
+	for i, bins := range radials {
+		next := radials[(i+1)%len(radials)]
+
+		for j, bin := range bins {
+			for _, pair := range [][2]int{{1, 0}, {3, 2}} {
+				a, b := bin.Coords[pair[0]], next[j].Coords[pair[1]]
+
+				if d := math.Hypot(a.X()-b.X(), a.Y()-b.Y()); d > 1e-3 {
+					t.Fatalf("radial %d gate %d: edge differs from the next radial by %v m", i, j, d)
+				}
+			}
+		}
+	}
+}
+
+func TestThin(t *testing.T) {
+	scan := testArchive(1, 360, []byte{100, 100, 100, 100, 100, 100}).ElevationScans[1]
+
+	full, err := georeferenceScanAt(context.Background(), scan, 39.7866, -104.5458, &RadarToJSONOptions{Product: "REF"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	thinned, err := georeferenceScanAt(context.Background(), scan, 39.7866, -104.5458, &RadarToJSONOptions{Product: "REF", Thin: 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(thinned)*4 != len(full) {
+		t.Errorf("expected %d bins, got %d", len(full)/4, len(thinned))
+	}
+
+	bins, err := radialToRelativePoints(scan[0], &RadarToJSONOptions{Product: "REF", Thin: 2})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the last thinned gate ends where the last full resolution gate does
+	last := bins[len(bins)-1].Coords[3]
+	ground, _ := beamPosition(2125+6*250, float64(float32(0.5))*(math.Pi/180))
+
+	if r := math.Hypot(last.X(), last.Y()); math.Abs(r-orthographicRadius(ground)) > 1e-6 {
+		t.Errorf("expected the last gate to end %v m from the radar, got %v m", orthographicRadius(ground), r)
+	}
+}
+
+func TestSample(t *testing.T) {
+	scan := testArchive(1, 360, []byte{100, 100, 100, 100, 100, 100}).ElevationScans[1]
+	options := &RadarToJSONOptions{Product: "REF", Sample: 0.1}
+
+	sampled, err := georeferenceScanAt(context.Background(), scan, 39.7866, -104.5458, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// about a tenth of the 2160 bins
+	if len(sampled) < 150 || len(sampled) > 282 {
+		t.Errorf("expected about 216 bins, got %d", len(sampled))
+	}
+
+	again, err := georeferenceScanAt(context.Background(), scan, 39.7866, -104.5458, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(again) != len(sampled) || again[0].Coords[0] != sampled[0].Coords[0] {
+		t.Errorf("expected the same sample on every run")
+	}
+
+	relative, err := RelativeScanBins(scan, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(relative) != len(sampled) {
+		t.Errorf("expected %d relative bins, as georeferenced, got %d", len(sampled), len(relative))
+	}
+}
+
+// Run with -race to catch workers sharing a transform.
+func TestWorkers(t *testing.T) {
+	scan := testArchive(1, 360, []byte{100, 120, 140, 160, 180, 200}).ElevationScans[1]
+
+	for _, sample := range []float64{1, 0.5} {
+		serial, err := georeferenceScanAt(context.Background(), scan, 39.7866, -104.5458, &RadarToJSONOptions{Product: "REF", Sample: sample})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, workers := range []int{2, 7, 2000} {
+			parallel, err := georeferenceScanAt(context.Background(), scan, 39.7866, -104.5458, &RadarToJSONOptions{Product: "REF", Sample: sample, Workers: workers})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if len(parallel) != len(serial) {
+				t.Fatalf("%d workers: expected %d bins, got %d", workers, len(serial), len(parallel))
+			}
+
+			for i := range serial {
+				if parallel[i].Value != serial[i].Value || parallel[i].Coords[0] != serial[i].Coords[0] || parallel[i].Coords[3] != serial[i].Coords[3] {
+					t.Fatalf("%d workers: bin %d differs from the serial bins", workers, i)
+				}
+			}
+		}
+	}
+}
+
+func TestStreamProductBins(t *testing.T) {
+	ar2 := testArchive(2, 360, []byte{100, 120, 140, 160, 180, 200})
+	options := &RadarToJSONOptions{Product: "REF", Elevations: []int{1, 2}, Sample: 0.5, Workers: 3}
+
+	collected, err := RadarToBins(ar2, options)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	streamed := make(map[int][]*Bin)
+	elevations := make([]int, 0)
+
+	err = StreamProductBinsContext(context.Background(), ar2, []*RadarToJSONOptions{options}, func(elevation int, products map[string][]*Bin) error {
+		if len(elevations) == 0 || elevations[len(elevations)-1] != elevation {
+			elevations = append(elevations, elevation)
+		}
+
+		// no more than a batch of radials of 6 gates
+		if len(products["REF"]) > StreamRadials*6 {
+			t.Errorf("elevation %d: expected a batch of at most %d bins, got %d", elevation, StreamRadials*6, len(products["REF"]))
+		}
+
+		streamed[elevation] = append(streamed[elevation], products["REF"]...)
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(elevations) != 2 || elevations[0] != 1 || elevations[1] != 2 {
+		t.Errorf("expected elevations 1 then 2, got %v", elevations)
+	}
+
+	// sampled and placed as if collected
+	for elevation, bins := range collected {
+		if len(streamed[elevation]) != len(bins) {
+			t.Fatalf("elevation %d: expected %d bins, got %d", elevation, len(bins), len(streamed[elevation]))
+		}
+
+		for i := range bins {
+			if streamed[elevation][i].Value != bins[i].Value || streamed[elevation][i].Coords[0] != bins[i].Coords[0] || streamed[elevation][i].Coords[3] != bins[i].Coords[3] {
+				t.Fatalf("elevation %d: bin %d differs from the collected bins", elevation, i)
+			}
+		}
+	}
+
+	stop := errors.New("stop")
+	calls := 0
+
+	err = StreamProductBinsContext(context.Background(), ar2, []*RadarToJSONOptions{options}, func(elevation int, products map[string][]*Bin) error {
+		calls++
+
+		return stop
+	})
+
+	if err != stop || calls != 1 {
+		t.Errorf("expected the error from the first call, unwrapped, got %v after %d calls", err, calls)
+	}
+}
+
+func TestMaxFeatures(t *testing.T) {
+	scan := testArchive(1, 360, []byte{100, 120, 140, 160, 180, 200}).ElevationScans[1]
+
+	all, err := georeferenceScanAt(context.Background(), scan, 39.7866, -104.5458, &RadarToJSONOptions{Product: "REF"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	capped, err := georeferenceScanAt(context.Background(), scan, 39.7866, -104.5458, &RadarToJSONOptions{Product: "REF", MaxFeatures: 5})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// one past the cap, telling the collection it was truncated
+	if len(capped) != 6 {
+		t.Fatalf("expected 6 bins, got %d", len(capped))
+	}
+
+	for i, bin := range capped {
+		if bin.Value != all[i].Value || bin.Coords[0] != all[i].Coords[0] {
+			t.Fatalf("bin %d differs from the first bins of the scan", i)
+		}
+	}
+
+	// the first batch's 360 bins pass the cap, so no other is georeferenced
+	batches := 0
+
+	err = streamProductsAt(context.Background(), scan, 39.7866, -104.5458, []*RadarToJSONOptions{{Product: "REF", MaxFeatures: 5}}, StreamRadials, func(products map[string][]*Bin) error {
+		batches++
+
+		return nil
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if batches != 1 {
+		t.Errorf("expected georeferencing to stop after 1 batch, got %d", batches)
+	}
+}
+
+// Radials centered either side of north must meet at 0/360 degrees, whether
+// the radar reports azimuths in [0, 360) or as 360 and above.
+func TestAzimuthWraparound(t *testing.T) {
+	tests := []struct {
+		name   string
+		offset float32
+	}{
+		{"0 to 360", 0},
+		{"360 to 720", 360},
+	}
+
+	for _, test := range tests {
+		radials := make([][]*Bin, 360)
+
+		for i := range radials {
+			bins, err := radialToRelativePoints(testRadial(1, float32(i)+0.5+test.offset, []byte{100, 100, 100}), &RadarToJSONOptions{Product: "REF"})
+
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			radials[i] = bins
+		}
+
+		assertSharedEdges(t, radials)
+
+		// the edge between 359.5 and 0.5 degrees points due north
+		last := radials[359][0].Coords[1]
+
+		if math.Abs(last.X()) > 1e-6 || last.Y() <= 0 {
+			t.Errorf("%s: expected the seam due north, got %v", test.name, last)
+		}
+	}
+
+	if theta := azimuthToTheta(360); theta != 90 {
+		t.Errorf("expected azimuth 360 at theta 90, got %v", theta)
+	}
+}
+
+func TestHalfAzimuthSpacing(t *testing.T) {
+	if half := halfSpacingRadians(1); math.Abs(half-0.5*math.Pi/180) > 1e-15 {
+		t.Errorf("expected half of 1 degree to be %v radians, got %v", 0.5*math.Pi/180, half)
+	}
+
+	if half := halfSpacingRadians(0.5); math.Abs(half-0.25*math.Pi/180) > 1e-15 {
+		t.Errorf("expected half of 0.5 degrees to be %v radians, got %v", 0.25*math.Pi/180, half)
+	}
+
+	// a 1 degree radial due north spans 0.5 degrees either side of it
+	bins, err := radialToRelativePoints(testRadial(1, 0, []byte{100}), &RadarToJSONOptions{Product: "REF"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i, expected := range []float64{90.5, 89.5, 90.5, 89.5} {
+		c := bins[0].Coords[i]
+
+		if angle := math.Atan2(c.Y(), c.X()) * 180 / math.Pi; math.Abs(angle-expected) > 1e-9 {
+			t.Errorf("corner %d: expected angle %v, got %v", i, expected, angle)
+		}
+	}
+}
+
+func TestRadialValueScale(t *testing.T) {
+	// 5 and 10 dBZ, then below threshold and range folded
+	radial := testRadial(1, 0, []byte{76, 86, 0, 1})
+	scale := float32(2)
+	minimum := float32(6)
+
+	bins, err := radialToRelativePoints(radial, &RadarToJSONOptions{
+		Product:            "REF",
+		Minimum:            &minimum,
+		ValueScale:         &scale,
+		ValueOffset:        -5,
+		KeepFolded:         true,
+		KeepBelowThreshold: true,
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the minimum applies before the transform, and flags are kept
+	expected := []string{"", "below_threshold", "range_folded"}
+
+	if len(bins) != len(expected) {
+		t.Fatalf("expected %d bins, got %d", len(expected), len(bins))
+	}
+
+	if bins[0].Value != 15 {
+		t.Errorf("expected 10 dBZ transformed to 15, got %v", bins[0].Value)
+	}
+
+	for i, bin := range bins {
+		if bin.Flag() != expected[i] {
+			t.Errorf("bin %d: expected flag %q, got %q", i, expected[i], bin.Flag())
+		}
+	}
+
+	// a value transformed onto a sentinel is still a value
+	bins, err = radialToRelativePoints(radial, &RadarToJSONOptions{Product: "REF", ValueOffset: archive2.MomentDataFolded - 5})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bins[0].Value != archive2.MomentDataFolded || bins[0].Flag() != "" {
+		t.Errorf("expected 5 dBZ offset to an unflagged %v, got %v flagged %q", archive2.MomentDataFolded, bins[0].Value, bins[0].Flag())
+	}
+}
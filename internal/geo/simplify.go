@@ -0,0 +1,155 @@
+package geo
+
+import (
+	"math"
+
+	"github.com/twpayne/go-proj/v10"
+)
+
+// Simplify removes vertices of a ring, without repeating the first vertex,
+// that are within tolerance of the outline by Douglas-Peucker, in the units
+// of the ring's coordinates. Rings that would collapse below a triangle are
+// returned unchanged.
+func Simplify(ring []proj.Coord, tolerance float64) []proj.Coord {
+	if tolerance <= 0 || len(ring) <= 3 {
+		return ring
+	}
+
+	// split the ring at its first vertex and the vertex farthest from it, so
+	// each half is an open chain
+	far := 0
+	farthest := -1.0
+
+	for i, c := range ring {
+		if d := math.Hypot(c.X()-ring[0].X(), c.Y()-ring[0].Y()); d > farthest {
+			far, farthest = i, d
+		}
+	}
+
+	if far == 0 {
+		return ring
+	}
+
+	keep := make([]bool, len(ring))
+	keep[0], keep[far] = true, true
+
+	closed := append(ring[:len(ring):len(ring)], ring[0])
+
+	douglasPeucker(closed, 0, far, tolerance, keep)
+	douglasPeucker(closed, far, len(ring), tolerance, keep)
+
+	simplified := make([]proj.Coord, 0, len(ring))
+
+	for i, c := range ring {
+		if keep[i] {
+			simplified = append(simplified, c)
+		}
+	}
+
+	if len(simplified) < 3 {
+		return ring
+	}
+
+	return simplified
+}
+
+// douglasPeucker marks the vertices of the chain between first and last that
+// are kept at the tolerance.
+func douglasPeucker(chain []proj.Coord, first int, last int, tolerance float64, keep []bool) {
+	if last-first < 2 {
+		return
+	}
+
+	index := -1
+	farthest := tolerance
+
+	for i := first + 1; i < last; i++ {
+		if d := segmentDistance(chain[i], chain[first], chain[last]); d > farthest {
+			index, farthest = i, d
+		}
+	}
+
+	if index < 0 {
+		return
+	}
+
+	keep[index] = true
+
+	douglasPeucker(chain, first, index, tolerance, keep)
+	douglasPeucker(chain, index, last, tolerance, keep)
+}
+
+// segmentDistance returns the distance from p to the segment from a to b.
+func segmentDistance(p proj.Coord, a proj.Coord, b proj.Coord) float64 {
+	dx, dy := b.X()-a.X(), b.Y()-a.Y()
+
+	if dx == 0 && dy == 0 {
+		return math.Hypot(p.X()-a.X(), p.Y()-a.Y())
+	}
+
+	t := ((p.X()-a.X())*dx + (p.Y()-a.Y())*dy) / (dx*dx + dy*dy)
+	t = math.Max(0, math.Min(1, t))
+
+	return math.Hypot(p.X()-(a.X()+t*dx), p.Y()-(a.Y()+t*dy))
+}
+
+// MergeRuns merges runs of bins adjacent along a radial, each bin's far edge
+// shared with the next bin's near edge, into one ring per run, keeping every
+// vertex along the sides. Bins crossing the antimeridian are left as their
+// own polygons. Bins are expected in the order they were georeferenced, as
+// within a scan.
+func MergeRuns(bins []*Bin) [][]proj.Coord {
+	rings := make([][]proj.Coord, 0)
+
+	for i := 0; i < len(bins); {
+		if bins[i].CrossesAntimeridian() {
+			rings = append(rings, bins[i].Polygons()...)
+			i++
+			continue
+		}
+
+		j := i
+
+		for j+1 < len(bins) && !bins[j+1].CrossesAntimeridian() && adjacent(bins[j], bins[j+1]) {
+			j++
+		}
+
+		rings = append(rings, runRing(bins[i:j+1]))
+		i = j + 1
+	}
+
+	return rings
+}
+
+// adjacent returns true if the far edge of a is the near edge of b.
+func adjacent(a *Bin, b *Bin) bool {
+	return a.Coords[2] == b.Coords[0] && a.Coords[3] == b.Coords[1]
+}
+
+// runRing returns the outline of a run of adjacent bins: the near edge of
+// the first, out along one side, the far edge of the last, and back along
+// the other side, wound counterclockwise.
+func runRing(run []*Bin) []proj.Coord {
+	if len(run) == 1 {
+		return run[0].Ring()
+	}
+
+	ring := make([]proj.Coord, 0, 2*len(run)+2)
+	ring = append(ring, run[0].Coords[0], run[0].Coords[1])
+
+	for _, bin := range run {
+		ring = append(ring, bin.Coords[3])
+	}
+
+	for i := len(run) - 1; i >= 0; i-- {
+		ring = append(ring, run[i].Coords[2])
+	}
+
+	if SignedArea(ring) < 0 {
+		for i, j := 0, len(ring)-1; i < j; i, j = i+1, j-1 {
+			ring[i], ring[j] = ring[j], ring[i]
+		}
+	}
+
+	return ring
+}
@@ -0,0 +1,84 @@
+package geo
+
+import (
+	"math"
+	"testing"
+
+	"github.com/twpayne/go-proj/v10"
+)
+
+func TestSimplify(t *testing.T) {
+	// a square with a vertex 0.001 off the middle of its bottom edge and one
+	// well off its right edge
+	ring := []proj.Coord{
+		proj.NewCoord(0, 0, 0, 0),
+		proj.NewCoord(0.5, 0.001, 0, 0),
+		proj.NewCoord(1, 0, 0, 0),
+		proj.NewCoord(1.2, 0.5, 0, 0),
+		proj.NewCoord(1, 1, 0, 0),
+		proj.NewCoord(0, 1, 0, 0),
+	}
+
+	if simplified := Simplify(ring, 0.01); len(simplified) != 5 {
+		t.Errorf("expected the vertex within tolerance removed, got %v", simplified)
+	}
+
+	if simplified := Simplify(ring, 0.5); len(simplified) != 4 {
+		t.Errorf("expected both off edge vertices removed, got %v", simplified)
+	}
+
+	if simplified := Simplify(ring[:3], 10); len(simplified) != 3 {
+		t.Errorf("expected a triangle unchanged, got %v", simplified)
+	}
+}
+
+// Runs of adjacent gates merge into one ring, whose straight sides simplify
+// to the run's four outer corners.
+func TestMergeRuns(t *testing.T) {
+	// the below threshold gate splits the radial into runs of 3 and 1
+	scan := testArchive(1, 1, []byte{100, 110, 120, 0, 130}).ElevationScans[1]
+
+	bins, err := RelativeScanBins(scan, &RadarToJSONOptions{Product: "REF"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rings := MergeRuns(bins)
+
+	if len(rings) != 2 {
+		t.Fatalf("expected 2 runs, got %d", len(rings))
+	}
+
+	if len(rings[0]) != 8 {
+		t.Errorf("expected every side vertex of the run kept, got %d vertices", len(rings[0]))
+	}
+
+	area := 0.0
+
+	for _, bin := range bins[:3] {
+		area += SignedArea(bin.Ring())
+	}
+
+	if math.Abs(SignedArea(rings[0])-area) > 1e-3*area {
+		t.Errorf("expected the run to cover its bins, area %v, got %v", area, SignedArea(rings[0]))
+	}
+
+	simplified := Simplify(rings[0], 0.01)
+
+	if len(simplified) != 4 {
+		t.Errorf("expected the run simplified to 4 corners, got %d", len(simplified))
+	}
+
+	for _, c := range []proj.Coord{bins[0].Coords[0], bins[0].Coords[1], bins[2].Coords[2], bins[2].Coords[3]} {
+		found := false
+
+		for _, s := range simplified {
+			found = found || s == c
+		}
+
+		if !found {
+			t.Errorf("expected outer corner %v kept, got %v", c, simplified)
+		}
+	}
+}
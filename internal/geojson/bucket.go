@@ -0,0 +1,109 @@
+package geojson
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+)
+
+// bucket is a range of values [Lower, Lower+size) and the bins within it.
+type bucket struct {
+	Lower float32
+	Bins  []*geo.Bin
+}
+
+// buckets groups bins into ranges of size values, ordered by value.
+func buckets(bins []*geo.Bin, size float32) []*bucket {
+	byLower := make(map[float32]*bucket)
+
+	for _, bin := range bins {
+		lower := float32(math.Floor(float64(bin.Value/size))) * size
+
+		b, ok := byLower[lower]
+
+		if !ok {
+			b = &bucket{Lower: lower}
+			byLower[lower] = b
+		}
+
+		b.Bins = append(b.Bins, bin)
+	}
+
+	sorted := make([]*bucket, 0, len(byLower))
+
+	for _, b := range byLower {
+		sorted = append(sorted, b)
+	}
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Lower < sorted[j].Lower })
+
+	return sorted
+}
+
+// appendBucketFeature writes the bins of a bucket as a single MultiPolygon
+// feature, with the lower bound of the bucket as the value and the upper
+// bound as the product name suffixed with _max.
+func appendBucketFeature(w io.Writer, props *geo.FeatureProperties, b *bucket, size float32) {
+	if props.Point {
+		fmt.Fprint(w, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"MultiPoint\",\"coordinates\":[")
+
+		for i, bin := range b.Bins {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+
+			geo.AppendPoint(w, bin.Center(), props.Precision)
+		}
+	} else {
+		fmt.Fprint(w, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"MultiPolygon\",\"coordinates\":[")
+		appendBucketPolygons(w, props, b)
+	}
+
+	fmt.Fprint(w, "]},\"properties\":{")
+	appendBucketProperties(w, props, b, size)
+	fmt.Fprint(w, "}}")
+}
+
+// appendBucketPolygons writes the polygons of every bin in the bucket,
+// separated by commas, or with props.Simplify the simplified outline of each
+// run of bins along a radial.
+func appendBucketPolygons(w io.Writer, props *geo.FeatureProperties, b *bucket) {
+	first := true
+
+	if props.Simplify > 0 {
+		for _, ring := range geo.MergeRuns(b.Bins) {
+			if !first {
+				fmt.Fprint(w, ",")
+			}
+
+			first = false
+
+			geo.AppendPolygon(w, geo.Simplify(ring, props.Simplify), props.Precision)
+		}
+
+		return
+	}
+
+	for _, bin := range b.Bins {
+		for _, ring := range bin.Polygons() {
+			if !first {
+				fmt.Fprint(w, ",")
+			}
+
+			first = false
+
+			geo.AppendPolygon(w, ring, props.Precision)
+		}
+	}
+}
+
+// appendBucketProperties writes the members of a bucket's properties object,
+// without the enclosing braces.
+func appendBucketProperties(w io.Writer, props *geo.FeatureProperties, b *bucket, size float32) {
+	geo.AppendValueProperties(w, props, b.Lower)
+	fmt.Fprintf(w, ",\"%s_max\":%v", strings.ToLower(props.Product), b.Lower+size)
+}
@@ -0,0 +1,33 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+)
+
+// WriteCoverage encodes a FeatureCollection with a polygon feature per
+// coverage ring to w, with the metadata as its properties if set. Write
+// errors are left to w, as with FeatureCollection.Write.
+func WriteCoverage(w io.Writer, coverages []*geo.Coverage, metadata *Metadata, precision int) {
+	fmt.Fprintf(w, "{\"type\":\"FeatureCollection\",")
+
+	if metadata != nil {
+		properties, _ := json.Marshal(metadata)
+		fmt.Fprintf(w, "\"properties\":%s,", properties)
+	}
+
+	fmt.Fprintf(w, "\"features\":[")
+
+	for i, coverage := range coverages {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+
+		coverage.AppendFeature(w, precision)
+	}
+
+	fmt.Fprintf(w, "]}")
+}
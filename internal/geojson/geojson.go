@@ -1,28 +1,258 @@
 package geojson
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
+	"math"
+	"sort"
 	"strings"
 
 	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
 )
 
-func BinsToString(bins []*geo.Bin) *strings.Builder {
+// recordSeparator precedes each GeoJSON text in a sequence, see RFC 8142.
+const recordSeparator = "\x1e"
+
+// FeatureCollection is a GeoJSON FeatureCollection with a polygon feature per bin.
+type FeatureCollection struct {
+	// Metadata is written as the collection's properties, if set
+	Metadata   *Metadata
+	Properties geo.FeatureProperties
+	Bins       []*geo.Bin
+	// BucketSize groups bins into a MultiPolygon feature per range of this
+	// many product units, if set
+	BucketSize float32
+	// Station is written as a Point feature ahead of the bins, if set
+	Station *Station
+}
+
+// Station is the radar a collection was scanned by, at Position in the
+// output coordinates, for maps to draw the station symbol.
+type Station struct {
+	ID       string
+	Position proj.Coord
+}
+
+// SetStation adds a Point feature for the radar, and its position to the
+// metadata as radar_location, rounded to the coordinate precision.
+func (fc *FeatureCollection) SetStation(id string, position proj.Coord) {
+	fc.Station = &Station{ID: id, Position: position}
+
+	if fc.Metadata == nil {
+		return
+	}
+
+	scale := math.Pow(10, float64(fc.Properties.Precision))
+	metadata := *fc.Metadata
+	metadata.RadarLocation = []float64{
+		math.Round(position.X()*scale) / scale,
+		math.Round(position.Y()*scale) / scale,
+	}
+	fc.Metadata = &metadata
+}
+
+// appendStation writes the Point feature of the station, tagged with
+// "feature": "station" to tell it from the bins.
+func (fc *FeatureCollection) appendStation(w io.Writer) {
+	fmt.Fprint(w, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"Point\",\"coordinates\":")
+	geo.AppendPoint(w, fc.Station.Position, fc.Properties.Precision)
+	fmt.Fprintf(w, "},\"properties\":{\"station\":%q,\"feature\":\"station\"}}", fc.Station.ID)
+}
+
+func NewFeatureCollection(product string, bins []*geo.Bin) *FeatureCollection {
+	return &FeatureCollection{
+		Properties: geo.FeatureProperties{
+			Product:   product,
+			Precision: geo.DefaultPrecision,
+		},
+		Bins: bins,
+	}
+}
+
+// Combine merges the collections for several elevations into one, in
+// elevation order, tagging each feature with its elevation.
+func Combine(collections map[int]*FeatureCollection) *FeatureCollection {
+	elevations := make([]int, 0, len(collections))
+
+	for elevation := range collections {
+		elevations = append(elevations, elevation)
+	}
+
+	sort.Ints(elevations)
+
+	combined := &FeatureCollection{
+		Bins: make([]*geo.Bin, 0),
+	}
+
+	for _, elevation := range elevations {
+		combined.Properties = collections[elevation].Properties
+		combined.BucketSize = collections[elevation].BucketSize
+		combined.Station = collections[elevation].Station
+		combined.Bins = append(combined.Bins, collections[elevation].Bins...)
+
+		if combined.Metadata == nil && collections[elevation].Metadata != nil {
+			metadata := *collections[elevation].Metadata
+			metadata.ElevationAngle = nil
+			metadata.NyquistVelocity = nil
+			metadata.UnambiguousRange = nil
+			combined.Metadata = &metadata
+		}
+
+		if combined.Metadata != nil && collections[elevation].Metadata != nil && collections[elevation].Metadata.Truncated {
+			combined.Metadata.Truncated = true
+		}
+	}
+
+	combined.Properties.Elevation = true
+
+	return combined
+}
+
+// FeatureCount returns the number of features written for the collection,
+// one per bin, or one per bucket when BucketSize is set, and the station's.
+func (fc *FeatureCollection) FeatureCount() int {
+	if fc.Station != nil {
+		return fc.binFeatureCount() + 1
+	}
+
+	return fc.binFeatureCount()
+}
+
+// Truncate keeps the first n bins of the collection, in radial order, if it
+// holds more, marking the metadata as truncated. It returns true if any were
+// dropped.
+func (fc *FeatureCollection) Truncate(n int) bool {
+	if len(fc.Bins) <= n {
+		return false
+	}
+
+	fc.Bins = fc.Bins[:n]
+
+	if fc.Metadata != nil {
+		metadata := *fc.Metadata
+		metadata.Truncated = true
+		fc.Metadata = &metadata
+	}
+
+	return true
+}
+
+// binFeatureCount returns the number of features of bins or buckets.
+func (fc *FeatureCollection) binFeatureCount() int {
+	if fc.BucketSize > 0 {
+		return len(buckets(fc.Bins, fc.BucketSize))
+	}
+
+	return len(fc.Bins)
+}
+
+// String returns the FeatureCollection encoded as GeoJSON.
+func (fc *FeatureCollection) String() string {
 	var b strings.Builder
 
-	fmt.Fprintf(&b, "{\"type\":\"FeatureCollection\",\"features\":[")
+	fc.Write(&b)
+
+	return b.String()
+}
+
+// Write encodes the FeatureCollection as GeoJSON to w. Write errors are left
+// to w, use a bufio.Writer and check the error from Flush.
+func (fc *FeatureCollection) Write(w io.Writer) {
+	fmt.Fprintf(w, "{\"type\":\"FeatureCollection\",")
+
+	if fc.Metadata != nil {
+		properties, _ := json.Marshal(fc.Metadata)
+		fmt.Fprintf(w, "\"properties\":%s,", properties)
+	}
+
+	fmt.Fprintf(w, "\"features\":[")
+
+	if fc.Station != nil {
+		fc.appendStation(w)
+
+		if fc.binFeatureCount() > 0 {
+			fmt.Fprint(w, ",")
+		}
+	}
+
+	fc.appendFeatures(w)
+	fmt.Fprintf(w, "]}")
+}
+
+// appendFeatures writes the collection's features separated by commas.
+func (fc *FeatureCollection) appendFeatures(w io.Writer) {
+	if fc.BucketSize > 0 {
+		buckets := buckets(fc.Bins, fc.BucketSize)
+		stop := len(buckets) - 1
+
+		for i, b := range buckets {
+			appendBucketFeature(w, &fc.Properties, b, fc.BucketSize)
 
-	stop := len(bins) - 1
+			if i != stop {
+				fmt.Fprint(w, ",")
+			}
+		}
+	} else {
+		stop := len(fc.Bins) - 1
 
-	for i, bin := range bins {
-		bin.AppendFeature(&b)
+		for i, bin := range fc.Bins {
+			bin.AppendFeature(w, &fc.Properties)
 
-		if i != stop {
-			fmt.Fprint(&b, ",")
+			if i != stop {
+				fmt.Fprint(w, ",")
+			}
 		}
 	}
+}
+
+// WriteSeq encodes each feature as a separate record of a GeoJSON text
+// sequence (RFC 8142) to w, so consumers can process features one at a time.
+// It can be called for each batch of bins as they are georeferenced, e.g. the
+// collections nexrad.StreamArchiveProducts passes, setting Station only on the
+// first, so the whole sequence is never in memory.
+// Write errors are left to w, as with Write.
+func (fc *FeatureCollection) WriteSeq(w io.Writer) {
+	if fc.Station != nil {
+		fc.appendStationRecord(w)
+	}
+
+	fc.appendSeqFeatures(w)
+}
+
+// appendStationRecord writes the station's Point feature as a record of a
+// GeoJSON text sequence.
+func (fc *FeatureCollection) appendStationRecord(w io.Writer) {
+	fmt.Fprint(w, recordSeparator)
+	fc.appendStation(w)
+	fmt.Fprint(w, "\n")
+}
+
+// appendSeqFeatures writes each bin or bucket feature as a record of a
+// GeoJSON text sequence.
+func (fc *FeatureCollection) appendSeqFeatures(w io.Writer) {
+	if fc.BucketSize > 0 {
+		for _, b := range buckets(fc.Bins, fc.BucketSize) {
+			fmt.Fprint(w, recordSeparator)
+			appendBucketFeature(w, &fc.Properties, b, fc.BucketSize)
+			fmt.Fprint(w, "\n")
+		}
+
+		return
+	}
+
+	for _, bin := range fc.Bins {
+		fmt.Fprint(w, recordSeparator)
+		bin.AppendFeature(w, &fc.Properties)
+		fmt.Fprint(w, "\n")
+	}
+}
+
+func BinsToString(product string, bins []*geo.Bin) *strings.Builder {
+	var b strings.Builder
 
-	fmt.Fprintf(&b, "]}")
+	NewFeatureCollection(product, bins).Write(&b)
 
 	return &b
 }
@@ -0,0 +1,92 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/twpayne/go-proj/v10"
+)
+
+func TestSetStation(t *testing.T) {
+	collection := NewFeatureCollection("REF", rowOfBins(2))
+	collection.Metadata = &Metadata{Station: "KFTG"}
+	collection.SetStation("KFTG", proj.NewCoord(-104.545812, 39.786633, 0, 0))
+
+	if location := collection.Metadata.RadarLocation; len(location) != 2 || location[0] != -104.5458 || location[1] != 39.7866 {
+		t.Errorf("expected radar_location rounded to [-104.5458 39.7866], got %v", location)
+	}
+
+	var b bytes.Buffer
+	collection.Write(&b)
+
+	var written struct {
+		Features []struct {
+			Geometry struct {
+				Type string
+			}
+			Properties map[string]interface{}
+		}
+	}
+
+	if err := json.Unmarshal(b.Bytes(), &written); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, b.String())
+	}
+
+	if len(written.Features) != 3 || collection.FeatureCount() != 3 {
+		t.Fatalf("expected 3 features, got %d written and %d counted", len(written.Features), collection.FeatureCount())
+	}
+
+	if station := written.Features[0]; station.Geometry.Type != "Point" || station.Properties["station"] != "KFTG" || station.Properties["feature"] != "station" {
+		t.Errorf("expected the station's Point feature first, got %v", station)
+	}
+
+	b.Reset()
+	collection.WriteSeq(&b)
+
+	if records := strings.Count(b.String(), recordSeparator); records != 3 {
+		t.Errorf("expected 3 records, got %d", records)
+	}
+
+	// only the station is written for a collection without bins
+	collection.Bins = rowOfBins(0)
+	b.Reset()
+	collection.Write(&b)
+
+	if err := json.Unmarshal(b.Bytes(), &written); err != nil || len(written.Features) != 1 {
+		t.Errorf("expected only the station's feature, got %s", b.String())
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	collection := NewFeatureCollection("REF", rowOfBins(5))
+	metadata := &Metadata{Station: "KFTG"}
+	collection.Metadata = metadata
+
+	if collection.Truncate(5) || collection.Metadata.Truncated {
+		t.Fatalf("expected a collection at the cap to be left as is")
+	}
+
+	if !collection.Truncate(3) || len(collection.Bins) != 3 || !collection.Metadata.Truncated {
+		t.Fatalf("expected 3 bins and truncated metadata, got %d bins and %v", len(collection.Bins), collection.Metadata.Truncated)
+	}
+
+	// the metadata is copied, as collections of one scan may share it
+	if metadata.Truncated {
+		t.Errorf("expected the original metadata to be left untruncated")
+	}
+
+	var b bytes.Buffer
+	collection.Write(&b)
+
+	if !strings.Contains(b.String(), `"truncated":true`) {
+		t.Errorf("expected truncated in the properties, got %s", b.String())
+	}
+
+	combined := Combine(map[int]*FeatureCollection{1: collection, 2: NewFeatureCollection("REF", rowOfBins(1))})
+
+	if combined.Metadata == nil || !combined.Metadata.Truncated {
+		t.Errorf("expected a combination with a truncated elevation to be truncated")
+	}
+}
@@ -0,0 +1,291 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// WriteMask encodes a FeatureCollection with a single MultiPolygon feature,
+// the outline of every bin dissolved into one, with holes where bins are
+// missing, e.g. gates below threshold or dropped by --minimum. The feature's
+// properties are the product and the number of bins. Write errors are left
+// to w, as with Write.
+func (fc *FeatureCollection) WriteMask(w io.Writer) {
+	fmt.Fprintf(w, "{\"type\":\"FeatureCollection\",")
+
+	if fc.Metadata != nil {
+		properties, _ := json.Marshal(fc.Metadata)
+		fmt.Fprintf(w, "\"properties\":%s,", properties)
+	}
+
+	fmt.Fprintf(w, "\"features\":[")
+
+	if polygons := Dissolve(fc.Bins, fc.Properties.Precision); len(polygons) > 0 {
+		fmt.Fprint(w, "{\"type\":\"Feature\",\"geometry\":{\"type\":\"MultiPolygon\",\"coordinates\":[")
+
+		for i, rings := range polygons {
+			if i > 0 {
+				fmt.Fprint(w, ",")
+			}
+
+			appendRings(w, rings, fc.Properties.Precision)
+		}
+
+		fmt.Fprintf(w, "]},\"properties\":{\"product\":\"%s\",\"bins\":%d}}", fc.Properties.Product, len(fc.Bins))
+	}
+
+	fmt.Fprintf(w, "]}")
+}
+
+// appendRings writes the coordinates of a GeoJSON polygon, each ring closed
+// by repeating its first position.
+func appendRings(w io.Writer, rings [][]proj.Coord, precision int) {
+	fmt.Fprint(w, "[")
+
+	for i, ring := range rings {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+
+		fmt.Fprint(w, "[")
+
+		for _, c := range ring {
+			geo.AppendPoint(w, c, precision)
+			fmt.Fprint(w, ",")
+		}
+
+		geo.AppendPoint(w, ring[0], precision)
+		fmt.Fprint(w, "]")
+	}
+
+	fmt.Fprint(w, "]")
+}
+
+// Dissolve returns the union of the bins as polygons, each an exterior ring
+// winding counterclockwise followed by the rings of its holes, clockwise,
+// without repeating their first positions. Corners are snapped to precision
+// decimals, so the edges neighboring bins share cancel out as in TopoJSON,
+// leaving the outline. Bins touching only at a corner are kept as separate
+// polygons, and holes touch their exterior ring at a point at most, as the
+// OGC simple features model requires. The bins must not overlap, as those of
+// one elevation, or their outlines cross rather than dissolve.
+func Dissolve(bins []*geo.Bin, precision int) [][][]proj.Coord {
+	t := newTopology(bins, precision)
+
+	// the edges left once shared edges cancel, and the order they were
+	// first drawn in, so the output is the same on every run
+	counts := make(map[edge]int)
+	order := make([]edge, 0)
+
+	for _, bin := range bins {
+		for _, ring := range bin.Polygons() {
+			if geo.SignedArea(ring) < 0 {
+				reversed := make([]proj.Coord, len(ring))
+
+				for i, c := range ring {
+					reversed[len(ring)-1-i] = c
+				}
+
+				ring = reversed
+			}
+
+			for i := range ring {
+				from := t.quantize(ring[i].X(), ring[i].Y())
+				to := t.quantize(ring[(i+1)%len(ring)].X(), ring[(i+1)%len(ring)].Y())
+
+				if from == to {
+					continue
+				}
+
+				if reverse := (edge{From: to, To: from}); counts[reverse] > 0 {
+					counts[reverse]--
+					continue
+				}
+
+				e := edge{From: from, To: to}
+
+				if counts[e] == 0 {
+					order = append(order, e)
+				}
+
+				counts[e]++
+			}
+		}
+	}
+
+	outgoing := make(map[point][]edge)
+	added := make(map[edge]bool)
+
+	for _, e := range order {
+		if added[e] {
+			continue
+		}
+
+		added[e] = true
+
+		for i := 0; i < counts[e]; i++ {
+			outgoing[e.From] = append(outgoing[e.From], e)
+		}
+	}
+
+	take := func(e edge) {
+		counts[e]--
+
+		edges := outgoing[e.From]
+
+		for i := range edges {
+			if edges[i] == e {
+				outgoing[e.From] = append(edges[:i], edges[i+1:]...)
+				break
+			}
+		}
+	}
+
+	rings := make([][]point, 0)
+
+	for _, start := range order {
+		for counts[start] > 0 {
+			take(start)
+
+			ring := []point{start.From}
+			e := start
+
+			for e.To != start.From {
+				ring = append(ring, e.To)
+
+				next, ok := leftmost(e, outgoing[e.To])
+
+				if !ok {
+					break
+				}
+
+				take(next)
+				e = next
+			}
+
+			for _, r := range splitRing(ring) {
+				if r = removeCollinear(r); len(r) >= 3 {
+					rings = append(rings, r)
+				}
+			}
+		}
+	}
+
+	return assignHoles(t, rings)
+}
+
+// leftmost returns the outgoing edge turning furthest left from e, which
+// keeps bins touching only at a corner in separate rings.
+func leftmost(e edge, outgoing []edge) (edge, bool) {
+	var best edge
+	bestTurn := math.Inf(-1)
+
+	dx, dy := float64(e.To.X-e.From.X), float64(e.To.Y-e.From.Y)
+
+	for _, next := range outgoing {
+		nx, ny := float64(next.To.X-next.From.X), float64(next.To.Y-next.From.Y)
+
+		if turn := math.Atan2(dx*ny-dy*nx, dx*nx+dy*ny); turn > bestTurn {
+			best, bestTurn = next, turn
+		}
+	}
+
+	return best, len(outgoing) > 0
+}
+
+// splitRing splits a ring at each position it passes through twice into
+// rings passing through each once.
+func splitRing(ring []point) [][]point {
+	rings := make([][]point, 0, 1)
+	stack := make([]point, 0, len(ring))
+	index := make(map[point]int, len(ring))
+
+	for _, p := range ring {
+		i, ok := index[p]
+
+		if !ok {
+			index[p] = len(stack)
+			stack = append(stack, p)
+			continue
+		}
+
+		split := make([]point, len(stack)-i)
+		copy(split, stack[i:])
+		rings = append(rings, split)
+
+		for _, q := range stack[i+1:] {
+			delete(index, q)
+		}
+
+		stack = stack[:i+1]
+	}
+
+	return append(rings, stack)
+}
+
+// removeCollinear drops the positions of a ring on the straight line between
+// their neighbors, such as the corners shared by the bins along a radial.
+func removeCollinear(ring []point) []point {
+	kept := make([]point, 0, len(ring))
+
+	for i, p := range ring {
+		prev, next := ring[(i+len(ring)-1)%len(ring)], ring[(i+1)%len(ring)]
+
+		if (p.X-prev.X)*(next.Y-p.Y)-(p.Y-prev.Y)*(next.X-p.X) != 0 {
+			kept = append(kept, p)
+		}
+	}
+
+	return kept
+}
+
+// assignHoles returns a polygon per counterclockwise ring, with each
+// clockwise ring as a hole of the smallest exterior ring containing it.
+func assignHoles(t *topology, rings [][]point) [][][]proj.Coord {
+	exteriors := make([][]proj.Coord, 0)
+	holes := make([][]proj.Coord, 0)
+
+	for _, r := range rings {
+		ring := make([]proj.Coord, len(r))
+
+		for i, p := range r {
+			ring[i] = proj.NewCoord(t.MinX+float64(p.X)*t.Scale, t.MinY+float64(p.Y)*t.Scale, 0, 0)
+		}
+
+		if geo.SignedArea(ring) > 0 {
+			exteriors = append(exteriors, ring)
+		} else {
+			holes = append(holes, ring)
+		}
+	}
+
+	polygons := make([][][]proj.Coord, len(exteriors))
+
+	for i, exterior := range exteriors {
+		polygons[i] = [][]proj.Coord{exterior}
+	}
+
+	for _, hole := range holes {
+		// the middle of an edge, as a corner may touch the exterior ring
+		x, y := (hole[0].X()+hole[1].X())/2, (hole[0].Y()+hole[1].Y())/2
+
+		best := -1
+
+		for i, exterior := range exteriors {
+			if geo.RingContains(exterior, x, y) && (best == -1 || geo.SignedArea(exterior) < geo.SignedArea(exteriors[best])) {
+				best = i
+			}
+		}
+
+		if best >= 0 {
+			polygons[best] = append(polygons[best], hole)
+		}
+	}
+
+	return polygons
+}
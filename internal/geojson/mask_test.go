@@ -0,0 +1,121 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// gridBins returns a bin of 0.01 degrees for each cell of the grid that is
+// set, rows from the south.
+func gridBins(grid []string) []*geo.Bin {
+	bins := make([]*geo.Bin, 0)
+
+	for row, cells := range grid {
+		for col, cell := range cells {
+			if cell != '#' {
+				continue
+			}
+
+			x, y := -105+float64(col)*0.01, 40+float64(row)*0.01
+
+			bins = append(bins, geo.NewBin(
+				proj.NewCoord(x, y, 0, 0),
+				proj.NewCoord(x+0.01, y, 0, 0),
+				proj.NewCoord(x, y+0.01, 0, 0),
+				proj.NewCoord(x+0.01, y+0.01, 0, 0),
+				30,
+			))
+		}
+	}
+
+	return bins
+}
+
+func TestDissolve(t *testing.T) {
+	cases := []struct {
+		name  string
+		grid  []string
+		rings [][]int
+	}{
+		// a square ring of bins is one exterior of its 4 corners and a hole
+		{"hole", []string{"###", "#.#", "###"}, [][]int{{4, 4}}},
+		// bins touching at a corner stay separate polygons
+		{"corner", []string{"#.", ".#"}, [][]int{{4}, {4}}},
+		// as do holes touching at a corner
+		{"holes", []string{"####", "##.#", "#.##", "####"}, [][]int{{4, 4, 4}}},
+		// bins enclosing a cell only at its corners leave no hole
+		{"enclosed", []string{".##", "#.#", "##."}, [][]int{{6}, {6}}},
+		// a hole open to the outside at a corner touches the exterior there
+		{"pinched", []string{"###", "#.#", "##."}, [][]int{{6, 4}}},
+	}
+
+	for _, c := range cases {
+		polygons := Dissolve(gridBins(c.grid), 4)
+
+		if len(polygons) != len(c.rings) {
+			t.Errorf("%s: expected %d polygons, got %d", c.name, len(c.rings), len(polygons))
+			continue
+		}
+
+		for i, rings := range polygons {
+			if len(rings) != len(c.rings[i]) {
+				t.Errorf("%s: polygon %d: expected %d rings, got %d", c.name, i, len(c.rings[i]), len(rings))
+				continue
+			}
+
+			for j, ring := range rings {
+				if len(ring) != c.rings[i][j] {
+					t.Errorf("%s: polygon %d ring %d: expected %d positions, got %d", c.name, i, j, c.rings[i][j], len(ring))
+				}
+
+				if area := geo.SignedArea(ring); (j == 0) != (area > 0) {
+					t.Errorf("%s: polygon %d ring %d: expected exteriors counterclockwise and holes clockwise, got area %v", c.name, i, j, area)
+				}
+			}
+		}
+	}
+}
+
+func TestWriteMask(t *testing.T) {
+	collection := NewFeatureCollection("REF", gridBins([]string{"###", "#.#", "###"}))
+
+	var b bytes.Buffer
+	collection.WriteMask(&b)
+
+	var mask struct {
+		Features []struct {
+			Geometry struct {
+				Type        string
+				Coordinates [][][][]float64
+			}
+			Properties map[string]interface{}
+		}
+	}
+
+	if err := json.Unmarshal(b.Bytes(), &mask); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, b.String())
+	}
+
+	if len(mask.Features) != 1 || mask.Features[0].Geometry.Type != "MultiPolygon" {
+		t.Fatalf("expected a single MultiPolygon feature, got %s", b.String())
+	}
+
+	if rings := mask.Features[0].Geometry.Coordinates[0]; len(rings) != 2 || len(rings[0]) != 5 {
+		t.Errorf("expected a closed exterior with a hole, got %v", rings)
+	}
+
+	if bins := mask.Features[0].Properties["bins"]; bins != 8.0 {
+		t.Errorf("expected 8 bins, got %v", bins)
+	}
+
+	b.Reset()
+	NewFeatureCollection("REF", nil).WriteMask(&b)
+
+	if err := json.Unmarshal(b.Bytes(), &mask); err != nil || len(mask.Features) != 0 {
+		t.Errorf("expected no features without bins, got %s", b.String())
+	}
+}
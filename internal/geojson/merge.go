@@ -0,0 +1,132 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// MergedCollection is a single FeatureCollection of the features of several
+// products, each tagged with its product so clients can toggle them as
+// layers.
+type MergedCollection struct {
+	// Metadata is written as the collection's properties, if set
+	Metadata    *Metadata
+	Collections []*FeatureCollection
+	// Station is written once ahead of the features of every product, if
+	// set, rather than by each collection
+	Station *Station
+}
+
+// Merge merges the collections of several products covering the same
+// elevations, in order, and sets ProductProperty on each. The metadata is
+// taken from the first, with the velocity limits and convention of any VEL
+// collection, and marked truncated if any collection is.
+func Merge(collections []*FeatureCollection) *MergedCollection {
+	merged := &MergedCollection{Collections: collections}
+
+	for _, collection := range collections {
+		collection.Properties.ProductProperty = true
+	}
+
+	if len(collections) > 0 {
+		merged.Metadata = collections[0].Metadata
+		merged.Station = collections[0].Station
+	}
+
+	// products are scaled differently, so levels can't share a scale
+	if merged.Metadata != nil && merged.Metadata.Scale != nil {
+		metadata := *merged.Metadata
+		metadata.Scale = nil
+		metadata.Offset = nil
+		merged.Metadata = &metadata
+	}
+
+	for _, collection := range collections {
+		if merged.Metadata != nil && !merged.Metadata.Truncated && collection.Metadata != nil && collection.Metadata.Truncated {
+			metadata := *merged.Metadata
+			metadata.Truncated = true
+			merged.Metadata = &metadata
+		}
+	}
+
+	for _, collection := range collections {
+		if merged.Metadata == nil || merged.Metadata.NyquistVelocity != nil {
+			break
+		}
+
+		if collection.Metadata != nil && collection.Metadata.NyquistVelocity != nil {
+			metadata := *merged.Metadata
+			metadata.NyquistVelocity = collection.Metadata.NyquistVelocity
+			metadata.UnambiguousRange = collection.Metadata.UnambiguousRange
+			metadata.VelocityConvention = collection.Metadata.VelocityConvention
+			merged.Metadata = &metadata
+		}
+	}
+
+	return merged
+}
+
+// FeatureCount returns the number of features written for the merged
+// collection.
+func (mc *MergedCollection) FeatureCount() int {
+	count := 0
+
+	if mc.Station != nil {
+		count++
+	}
+
+	for _, collection := range mc.Collections {
+		count += collection.binFeatureCount()
+	}
+
+	return count
+}
+
+// Write encodes the merged collection as GeoJSON to w. Write errors are left
+// to w, as with FeatureCollection.Write.
+func (mc *MergedCollection) Write(w io.Writer) {
+	fmt.Fprintf(w, "{\"type\":\"FeatureCollection\",")
+
+	if mc.Metadata != nil {
+		properties, _ := json.Marshal(mc.Metadata)
+		fmt.Fprintf(w, "\"properties\":%s,", properties)
+	}
+
+	fmt.Fprintf(w, "\"features\":[")
+
+	first := true
+
+	if mc.Station != nil {
+		(&FeatureCollection{Properties: mc.Collections[0].Properties, Station: mc.Station}).appendStation(w)
+		first = false
+	}
+
+	for _, collection := range mc.Collections {
+		if collection.binFeatureCount() == 0 {
+			continue
+		}
+
+		if !first {
+			fmt.Fprint(w, ",")
+		}
+
+		first = false
+
+		collection.appendFeatures(w)
+	}
+
+	fmt.Fprintf(w, "]}")
+}
+
+// WriteSeq encodes the features of each product in turn as a GeoJSON text
+// sequence, as FeatureCollection.WriteSeq.
+func (mc *MergedCollection) WriteSeq(w io.Writer) {
+	if mc.Station != nil {
+		(&FeatureCollection{Properties: mc.Collections[0].Properties, Station: mc.Station}).appendStationRecord(w)
+	}
+
+	for _, collection := range mc.Collections {
+		collection.appendSeqFeatures(w)
+	}
+}
@@ -0,0 +1,72 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/twpayne/go-proj/v10"
+)
+
+func TestMerge(t *testing.T) {
+	empty := NewFeatureCollection("RHO", rowOfBins(0))
+	merged := Merge([]*FeatureCollection{NewFeatureCollection("REF", rowOfBins(3)), empty, NewFeatureCollection("VEL", rowOfBins(2))})
+
+	var b bytes.Buffer
+	merged.Write(&b)
+
+	var collection struct {
+		Features []struct {
+			Properties map[string]interface{}
+		}
+	}
+
+	if err := json.Unmarshal(b.Bytes(), &collection); err != nil {
+		t.Fatalf("invalid JSON: %v\n%s", err, b.String())
+	}
+
+	if len(collection.Features) != 5 || merged.FeatureCount() != 5 {
+		t.Fatalf("expected 5 features, got %d written and %d counted", len(collection.Features), merged.FeatureCount())
+	}
+
+	for i, feature := range collection.Features {
+		product, key := "REF", "ref"
+
+		if i >= 3 {
+			product, key = "VEL", "vel"
+		}
+
+		if feature.Properties["product"] != product {
+			t.Errorf("feature %d: expected product %v, got %v", i, product, feature.Properties["product"])
+		}
+
+		if _, ok := feature.Properties[key]; !ok {
+			t.Errorf("feature %d: expected a %v value, got %v", i, key, feature.Properties)
+		}
+	}
+}
+
+func TestMergeStation(t *testing.T) {
+	collections := []*FeatureCollection{NewFeatureCollection("REF", rowOfBins(3)), NewFeatureCollection("VEL", rowOfBins(2))}
+
+	for _, collection := range collections {
+		collection.SetStation("KFTG", proj.NewCoord(-104.5458, 39.7866, 0, 0))
+	}
+
+	merged := Merge(collections)
+
+	var b bytes.Buffer
+	merged.Write(&b)
+
+	if stations := strings.Count(b.String(), `"feature":"station"`); stations != 1 || merged.FeatureCount() != 6 {
+		t.Errorf("expected the station written once of 6 features, got %d of %d", stations, merged.FeatureCount())
+	}
+
+	b.Reset()
+	merged.WriteSeq(&b)
+
+	if stations := strings.Count(b.String(), `"feature":"station"`); stations != 1 {
+		t.Errorf("expected the station written once, got %d", stations)
+	}
+}
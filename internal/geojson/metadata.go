@@ -0,0 +1,107 @@
+package geojson
+
+import (
+	"strings"
+	"time"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+)
+
+// Metadata describes the source of a FeatureCollection, written as its
+// top level properties.
+type Metadata struct {
+	Station string `json:"station"`
+	// Time is the collection time of the scan's first radial
+	Time time.Time `json:"time"`
+	// VolumeTime is the collection time of the volume's first radial, the
+	// same for every elevation of a volume, if set
+	VolumeTime *time.Time `json:"volume_time,omitempty"`
+	VCP        int        `json:"vcp"`
+	// ElevationAngle is omitted when a collection holds several elevations
+	ElevationAngle *float32 `json:"elevation_angle,omitempty"`
+	// NyquistVelocity in m/s and UnambiguousRange in km are the limits
+	// beyond which velocities alias and echoes fold, set for VEL and SRV and
+	// omitted with several elevations, as ElevationAngle
+	NyquistVelocity  *float32 `json:"nyquist_velocity,omitempty"`
+	UnambiguousRange *float32 `json:"unambiguous_range_km,omitempty"`
+	// VelocityConvention is the sign of radial velocities, set for VEL and
+	// SRV to VelocityTowardRadar
+	VelocityConvention string `json:"velocity_convention,omitempty"`
+	// RadarLocation is the position of the radar in the output coordinates,
+	// x or longitude first, set with the station's Point feature
+	RadarLocation []float64 `json:"radar_location,omitempty"`
+	// Truncated is set when bins were dropped to cap the bins written,
+	// counted in bins rather than the features of buckets, see
+	// FeatureCollection.Truncate
+	Truncated bool `json:"truncated,omitempty"`
+	// Scale and Offset convert the raw data levels of collections of them
+	// to values, (level - offset) / scale
+	Scale  *float32 `json:"scale,omitempty"`
+	Offset *float32 `json:"offset,omitempty"`
+	// ValueScale and ValueOffset transformed the values of the collection
+	// from the product's unit, value * value_scale + value_offset
+	ValueScale  *float32 `json:"value_scale,omitempty"`
+	ValueOffset *float32 `json:"value_offset,omitempty"`
+}
+
+// VelocityTowardRadar is the sign convention of NEXRAD radial velocities,
+// negative toward the radar (inbound) and positive away from it (outbound).
+const VelocityTowardRadar = "negative_toward_radar"
+
+// NewMetadata returns the metadata of an elevation scan, taken from its
+// first radial.
+func NewMetadata(scan []*archive2.Message31) *Metadata {
+	if len(scan) == 0 {
+		return nil
+	}
+
+	radial := scan[0]
+	elevationAngle := radial.Header.ElevationAngle
+
+	return &Metadata{
+		Station:        strings.TrimRight(string(radial.Header.RadarIdentifier[:]), "\x00 "),
+		Time:           radial.Header.Date(),
+		VCP:            int(radial.VolumeData.VolumeCoveragePatternNumber),
+		ElevationAngle: &elevationAngle,
+	}
+}
+
+// NewProductMetadata returns the metadata of an elevation scan of a product,
+// the metadata of NewMetadata with the Nyquist velocity and unambiguous range
+// of the first radial and the velocity convention for VEL and SRV.
+func NewProductMetadata(scan []*archive2.Message31, product string) *Metadata {
+	metadata := NewMetadata(scan)
+
+	if metadata == nil || (product != "VEL" && product != "SRV") {
+		return metadata
+	}
+
+	nyquist := scan[0].RadialData.Nyquist()
+	unambiguousRange := scan[0].RadialData.UnambiguousRangeKm()
+
+	metadata.NyquistVelocity = &nyquist
+	metadata.UnambiguousRange = &unambiguousRange
+	metadata.VelocityConvention = VelocityTowardRadar
+
+	return metadata
+}
+
+// SetScale sets the scale and offset of the product's moment in the first
+// radial of the scan, for a collection of raw data levels.
+func (m *Metadata) SetScale(scan []*archive2.Message31, product string) {
+	if len(scan) == 0 {
+		return
+	}
+
+	moment, err := scan[0].DataMomentForProduct(product)
+
+	if err != nil {
+		return
+	}
+
+	scale := moment.Scale
+	offset := moment.Offset
+
+	m.Scale = &scale
+	m.Offset = &offset
+}
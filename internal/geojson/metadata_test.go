@@ -0,0 +1,90 @@
+package geojson
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+)
+
+func TestNewProductMetadata(t *testing.T) {
+	scan := []*archive2.Message31{{RadialData: archive2.RadialData{NyquistVelocity: 2650, UnambiguousRange: 1150}}}
+
+	vel := NewProductMetadata(scan, "VEL")
+
+	if vel.NyquistVelocity == nil || *vel.NyquistVelocity != 26.5 {
+		t.Errorf("expected Nyquist velocity 26.5, got %v", vel.NyquistVelocity)
+	}
+
+	if vel.UnambiguousRange == nil || *vel.UnambiguousRange != 115 {
+		t.Errorf("expected unambiguous range 115, got %v", vel.UnambiguousRange)
+	}
+
+	properties, _ := json.Marshal(vel)
+
+	if !strings.Contains(string(properties), `"nyquist_velocity":26.5,"unambiguous_range_km":115`) {
+		t.Errorf("expected velocity limits in properties, got %s", properties)
+	}
+
+	ref := NewProductMetadata(scan, "REF")
+
+	if ref.NyquistVelocity != nil || ref.UnambiguousRange != nil {
+		t.Errorf("expected no velocity limits for REF")
+	}
+
+	// metadata of merged products has the limits of the VEL collection
+	refCollection := &FeatureCollection{Metadata: ref}
+	velCollection := &FeatureCollection{Metadata: vel}
+
+	if merged := Merge([]*FeatureCollection{refCollection, velCollection}); merged.Metadata.NyquistVelocity == nil {
+		t.Errorf("expected merged metadata to have the Nyquist velocity")
+	}
+
+	if refCollection.Metadata.NyquistVelocity != nil {
+		t.Errorf("expected the REF metadata to be unchanged")
+	}
+}
+
+func TestSetScale(t *testing.T) {
+	moment := &archive2.DataMoment{GenericDataMoment: archive2.GenericDataMoment{Scale: 2, Offset: 66}}
+	scan := []*archive2.Message31{{ReflectivityData: moment}}
+
+	metadata := NewProductMetadata(scan, "REF")
+	metadata.SetScale(scan, "REF")
+
+	if metadata.Scale == nil || *metadata.Scale != 2 || metadata.Offset == nil || *metadata.Offset != 66 {
+		t.Fatalf("expected scale 2 and offset 66, got %v and %v", metadata.Scale, metadata.Offset)
+	}
+
+	// levels of several products have no one scale
+	merged := Merge([]*FeatureCollection{{Metadata: metadata}, {Metadata: metadata}})
+
+	if merged.Metadata.Scale != nil || merged.Metadata.Offset != nil {
+		t.Errorf("expected merged metadata to have no scale")
+	}
+
+	if metadata.Scale == nil {
+		t.Errorf("expected the metadata to be unchanged")
+	}
+}
+
+func TestVelocityConvention(t *testing.T) {
+	scan := []*archive2.Message31{{}}
+
+	for _, product := range []string{"VEL", "SRV"} {
+		if convention := NewProductMetadata(scan, product).VelocityConvention; convention != VelocityTowardRadar {
+			t.Errorf("%v: expected convention %v, got %q", product, VelocityTowardRadar, convention)
+		}
+	}
+
+	if convention := NewProductMetadata(scan, "REF").VelocityConvention; convention != "" {
+		t.Errorf("expected no convention for REF, got %q", convention)
+	}
+
+	properties, _ := json.Marshal(NewProductMetadata(scan, "VEL"))
+
+	if !strings.Contains(string(properties), `"velocity_convention":"negative_toward_radar"`) {
+		t.Errorf("expected the convention in properties, got %s", properties)
+	}
+}
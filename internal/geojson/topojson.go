@@ -0,0 +1,199 @@
+package geojson
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+)
+
+// point is a quantized TopoJSON position.
+type point struct {
+	X int64
+	Y int64
+}
+
+// edge is an arc between two quantized positions, keyed in drawing order.
+type edge struct {
+	From point
+	To   point
+}
+
+// topology quantizes bin corners onto a grid and shares the edges of touching
+// bins as arcs, as described by the TopoJSON specification.
+type topology struct {
+	MinX  float64
+	MinY  float64
+	Scale float64
+	Arcs  []edge
+	index map[edge]int
+}
+
+func newTopology(bins []*geo.Bin, precision int) *topology {
+	t := &topology{
+		MinX:  math.Inf(1),
+		MinY:  math.Inf(1),
+		Scale: math.Pow(10, -float64(precision)),
+		index: make(map[edge]int),
+	}
+
+	for _, bin := range bins {
+		for _, c := range bin.Coords {
+			t.MinX = math.Min(t.MinX, c.X())
+			t.MinY = math.Min(t.MinY, c.Y())
+		}
+	}
+
+	return t
+}
+
+func (t *topology) quantize(x float64, y float64) point {
+	return point{
+		X: int64(math.Round((x - t.MinX) / t.Scale)),
+		Y: int64(math.Round((y - t.MinY) / t.Scale)),
+	}
+}
+
+// ring returns the arc indexes of the bin's ring, A, B, D, C, reusing an
+// existing arc, reversed as its one's complement, where a neighbor already
+// drew the same edge. Edges collapsed to a single position are dropped, and
+// nil is returned if fewer than three edges remain.
+func (t *topology) ring(bin *geo.Bin) []int {
+	corners := bin.Ring()
+	arcs := make([]int, 0, len(corners))
+
+	for i := range corners {
+		from := t.quantize(corners[i].X(), corners[i].Y())
+		to := t.quantize(corners[(i+1)%len(corners)].X(), corners[(i+1)%len(corners)].Y())
+
+		if from == to {
+			continue
+		}
+
+		if index, ok := t.index[edge{From: from, To: to}]; ok {
+			arcs = append(arcs, index)
+		} else if index, ok := t.index[edge{From: to, To: from}]; ok {
+			arcs = append(arcs, ^index)
+		} else {
+			t.index[edge{From: from, To: to}] = len(t.Arcs)
+			arcs = append(arcs, len(t.Arcs))
+			t.Arcs = append(t.Arcs, edge{From: from, To: to})
+		}
+	}
+
+	if len(arcs) < 3 {
+		return nil
+	}
+
+	return arcs
+}
+
+func appendRing(w io.Writer, arcs []int) {
+	fmt.Fprint(w, "[[")
+
+	for i, arc := range arcs {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+
+		fmt.Fprintf(w, "%d", arc)
+	}
+
+	fmt.Fprint(w, "]]")
+}
+
+// WriteTopo encodes the FeatureCollection as TopoJSON to w, with a single
+// GeometryCollection object named after the lowercase product. Coordinates
+// are quantized to Properties.Precision decimals, and edges shared by
+// neighboring bins are written once. Write errors are left to w, as with
+// Write.
+func (fc *FeatureCollection) WriteTopo(w io.Writer) {
+	t := newTopology(fc.Bins, fc.Properties.Precision)
+
+	fmt.Fprint(w, "{\"type\":\"Topology\",")
+
+	if fc.Metadata != nil {
+		properties, _ := json.Marshal(fc.Metadata)
+		fmt.Fprintf(w, "\"properties\":%s,", properties)
+	}
+
+	if len(fc.Bins) > 0 {
+		fmt.Fprintf(w, "\"transform\":{\"scale\":[%v,%v],\"translate\":[%v,%v]},", t.Scale, t.Scale, t.MinX, t.MinY)
+	}
+
+	fmt.Fprintf(w, "\"objects\":{\"%s\":{\"type\":\"GeometryCollection\",\"geometries\":[", strings.ToLower(fc.Properties.Product))
+
+	first := true
+
+	if fc.BucketSize > 0 {
+		for _, b := range buckets(fc.Bins, fc.BucketSize) {
+			polygons := make([][]int, 0, len(b.Bins))
+
+			for _, bin := range b.Bins {
+				if arcs := t.ring(bin); arcs != nil {
+					polygons = append(polygons, arcs)
+				}
+			}
+
+			if len(polygons) == 0 {
+				continue
+			}
+
+			if !first {
+				fmt.Fprint(w, ",")
+			}
+
+			first = false
+
+			fmt.Fprint(w, "{\"type\":\"MultiPolygon\",\"arcs\":[")
+
+			for i, arcs := range polygons {
+				if i > 0 {
+					fmt.Fprint(w, ",")
+				}
+
+				appendRing(w, arcs)
+			}
+
+			fmt.Fprint(w, "],\"properties\":{")
+			appendBucketProperties(w, &fc.Properties, b, fc.BucketSize)
+			fmt.Fprint(w, "}}")
+		}
+	} else {
+		for _, bin := range fc.Bins {
+			arcs := t.ring(bin)
+
+			if arcs == nil {
+				continue
+			}
+
+			if !first {
+				fmt.Fprint(w, ",")
+			}
+
+			first = false
+
+			fmt.Fprint(w, "{\"type\":\"Polygon\",\"arcs\":")
+			appendRing(w, arcs)
+			fmt.Fprint(w, ",\"properties\":{")
+			bin.AppendProperties(w, &fc.Properties)
+			fmt.Fprint(w, "}}")
+		}
+	}
+
+	fmt.Fprint(w, "]}},\"arcs\":[")
+
+	for i, arc := range t.Arcs {
+		if i > 0 {
+			fmt.Fprint(w, ",")
+		}
+
+		// positions are delta encoded from the previous position in the arc
+		fmt.Fprintf(w, "[[%d,%d],[%d,%d]]", arc.From.X, arc.From.Y, arc.To.X-arc.From.X, arc.To.Y-arc.From.Y)
+	}
+
+	fmt.Fprint(w, "]}")
+}
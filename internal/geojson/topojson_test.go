@@ -0,0 +1,69 @@
+package geojson
+
+import (
+	"bytes"
+	"encoding/json"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// rowOfBins returns n square bins side by side, each sharing an edge with the
+// next.
+func rowOfBins(n int) []*geo.Bin {
+	bins := make([]*geo.Bin, 0, n)
+
+	for i := 0; i < n; i++ {
+		x := -105 + float64(i)*0.01
+
+		bins = append(bins, geo.NewBin(
+			proj.NewCoord(x, 40, 0, 0),
+			proj.NewCoord(x+0.01, 40, 0, 0),
+			proj.NewCoord(x, 40.01, 0, 0),
+			proj.NewCoord(x+0.01, 40.01, 0, 0),
+			float32(i*10),
+		))
+	}
+
+	return bins
+}
+
+func TestWriteTopoSharesEdges(t *testing.T) {
+	for _, bucketSize := range []float32{0, 20} {
+		fc := NewFeatureCollection("REF", rowOfBins(5))
+		fc.BucketSize = bucketSize
+
+		var b bytes.Buffer
+		fc.WriteTopo(&b)
+
+		var topology struct {
+			Type    string
+			Arcs    [][][]int64
+			Objects map[string]struct {
+				Geometries []struct {
+					Type string
+				}
+			}
+		}
+
+		if err := json.Unmarshal(b.Bytes(), &topology); err != nil {
+			t.Fatalf("bucket %v: invalid JSON: %v\n%s", bucketSize, err, b.String())
+		}
+
+		// 4 edges per bin, less one shared edge between each pair
+		if len(topology.Arcs) != 5*4-4 {
+			t.Errorf("bucket %v: expected %d arcs, got %d", bucketSize, 5*4-4, len(topology.Arcs))
+		}
+
+		geometries := topology.Objects["ref"].Geometries
+
+		if bucketSize == 0 && len(geometries) != 5 {
+			t.Errorf("expected 5 polygons, got %d", len(geometries))
+		}
+
+		if bucketSize > 0 && (len(geometries) != 3 || geometries[0].Type != "MultiPolygon") {
+			t.Errorf("expected 3 multipolygons, got %d", len(geometries))
+		}
+	}
+}
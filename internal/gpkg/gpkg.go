@@ -0,0 +1,235 @@
+// Package gpkg writes bins as an OGC GeoPackage, an SQLite database with a
+// feature table of polygons and an R*Tree spatial index per layer.
+package gpkg
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+const (
+	// applicationID and userVersion identify a GeoPackage 1.2 database
+	applicationID = 0x47504B47
+	userVersion   = 10200
+
+	srsID = 4326
+
+	wkbPolygon      = 3
+	wkbMultiPolygon = 6
+
+	// geometryFlags marks little endian geometries with an xy envelope
+	geometryFlags = 0x03
+)
+
+// projection is the WKT of WGS84 geographic coordinates, the only spatial
+// reference system of the layers.
+const projection = `GEOGCS["WGS 84",DATUM["WGS_1984",SPHEROID["WGS 84",6378137,298.257223563,AUTHORITY["EPSG","7030"]],AUTHORITY["EPSG","6326"]],PRIMEM["Greenwich",0,AUTHORITY["EPSG","8901"]],UNIT["degree",0.0174532925199433,AUTHORITY["EPSG","9122"]],AUTHORITY["EPSG","4326"]]`
+
+// The tables every GeoPackage has, as the specification defines them.
+const (
+	createSpatialRefSys = `CREATE TABLE gpkg_spatial_ref_sys (srs_name TEXT NOT NULL, srs_id INTEGER NOT NULL PRIMARY KEY, organization TEXT NOT NULL, organization_coordsys_id INTEGER NOT NULL, definition TEXT NOT NULL, description TEXT)`
+	createContents      = `CREATE TABLE gpkg_contents (table_name TEXT NOT NULL PRIMARY KEY, data_type TEXT NOT NULL, identifier TEXT UNIQUE, description TEXT DEFAULT '', last_change DATETIME NOT NULL DEFAULT (strftime('%Y-%m-%dT%H:%M:%fZ','now')), min_x DOUBLE, min_y DOUBLE, max_x DOUBLE, max_y DOUBLE, srs_id INTEGER, CONSTRAINT fk_gc_r_srs_id FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys(srs_id))`
+	createGeometryCols  = `CREATE TABLE gpkg_geometry_columns (table_name TEXT NOT NULL, column_name TEXT NOT NULL, geometry_type_name TEXT NOT NULL, srs_id INTEGER NOT NULL, z TINYINT NOT NULL, m TINYINT NOT NULL, CONSTRAINT pk_geom_cols PRIMARY KEY (table_name, column_name), CONSTRAINT uk_gc_table_name UNIQUE (table_name), CONSTRAINT fk_gc_tn FOREIGN KEY (table_name) REFERENCES gpkg_contents(table_name), CONSTRAINT fk_gc_srs FOREIGN KEY (srs_id) REFERENCES gpkg_spatial_ref_sys (srs_id))`
+	createExtensions    = `CREATE TABLE gpkg_extensions (table_name TEXT, column_name TEXT, extension_name TEXT NOT NULL, definition TEXT NOT NULL, scope TEXT NOT NULL, CONSTRAINT ge_tce UNIQUE (table_name, column_name, extension_name))`
+
+	rtreeExtension  = "gpkg_rtree_index"
+	rtreeDefinition = "http://www.geopackage.org/spec120/#extension_rtree"
+)
+
+// Layer is a feature table of bins.
+type Layer struct {
+	// Name is the table name, and identifies the layer
+	Name        string
+	Description string
+	Bins        []*geo.Bin
+	Props       *geo.FeatureProperties
+}
+
+// Write writes a GeoPackage of the layers, each a table of bin polygons with
+// the value keyed by the lowercase product, the unit, and the elevation
+// number if the layer's props.Elevation is set, and an R*Tree index of the
+// polygons. The file is written once, so the triggers the R*Tree extension
+// uses to keep the index current on edits are left out.
+func Write(w io.Writer, layers []*Layer) error {
+	sorted := make([]*Layer, len(layers))
+	copy(sorted, layers)
+
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	for i := 1; i < len(sorted); i++ {
+		if sorted[i].Name == sorted[i-1].Name {
+			return fmt.Errorf("duplicate layer %v", sorted[i].Name)
+		}
+	}
+
+	d := newDatabase()
+
+	d.createTable("gpkg_spatial_ref_sys", createSpatialRefSys, []row{
+		{-1, record("Undefined cartesian SRS", nil, "NONE", int64(-1), "undefined", "undefined cartesian coordinate reference system")},
+		{0, record("Undefined geographic SRS", nil, "NONE", int64(0), "undefined", "undefined geographic coordinate reference system")},
+		{srsID, record("WGS 84 geodetic", nil, "EPSG", int64(srsID), projection, "longitude/latitude coordinates in decimal degrees on the WGS 84 spheroid")},
+	})
+
+	now := time.Now().UTC().Format("2006-01-02T15:04:05.000Z")
+
+	contents := make([]row, len(sorted))
+	geometryColumns := make([]row, len(sorted))
+	extensions := make([]row, len(sorted))
+
+	for i, layer := range sorted {
+		rowID := int64(i + 1)
+		bounds := writeLayer(d, layer)
+
+		var minX, minY, maxX, maxY interface{}
+
+		if len(layer.Bins) > 0 {
+			minX, minY, maxX, maxY = bounds.MinX, bounds.MinY, bounds.MaxX, bounds.MaxY
+		}
+
+		contents[i] = row{rowID, record(layer.Name, "features", layer.Name, layer.Description, now, minX, minY, maxX, maxY, int64(srsID))}
+		geometryColumns[i] = row{rowID, record(layer.Name, "geom", "MULTIPOLYGON", int64(srsID), int64(0), int64(0))}
+		extensions[i] = row{rowID, record(layer.Name, "geom", rtreeExtension, rtreeDefinition, "write-only")}
+	}
+
+	d.createTable("gpkg_contents", createContents, contents)
+
+	d.createAutoIndex("gpkg_contents", 1, keys(sorted))
+	d.createAutoIndex("gpkg_contents", 2, keys(sorted))
+
+	d.createTable("gpkg_geometry_columns", createGeometryCols, geometryColumns)
+	d.createAutoIndex("gpkg_geometry_columns", 1, keys(sorted, "geom"))
+	d.createAutoIndex("gpkg_geometry_columns", 2, keys(sorted))
+
+	d.createTable("gpkg_extensions", createExtensions, extensions)
+	d.createAutoIndex("gpkg_extensions", 1, keys(sorted, "geom", rtreeExtension))
+
+	_, err := w.Write(d.bytes())
+
+	return err
+}
+
+// keys returns the index keys of the layer names followed by the fixed
+// values, one per row of a table with a row per layer.
+func keys(layers []*Layer, values ...string) []indexKey {
+	k := make([]indexKey, len(layers))
+
+	for i, layer := range layers {
+		k[i] = indexKey{append([]string{layer.Name}, values...), int64(i + 1)}
+	}
+
+	return k
+}
+
+// writeLayer writes the feature table of a layer and its R*Tree, returning
+// the bounds of its bins.
+func writeLayer(d *database, layer *Layer) envelope {
+	column := quote(strings.ToLower(layer.Props.Product))
+	decimals := layer.Props.ValueDecimals()
+	unit := layer.Props.Unit()
+
+	sql := fmt.Sprintf("CREATE TABLE %v (fid INTEGER PRIMARY KEY, geom MULTIPOLYGON, %v REAL, unit TEXT", quote(layer.Name), column)
+
+	if layer.Props.Elevation {
+		sql += ", elevation INTEGER"
+	}
+
+	features := make([]row, len(layer.Bins))
+	entries := make([]rtreeEntry, len(layer.Bins))
+	bounds := newEnvelope()
+
+	for i, bin := range layer.Bins {
+		// values are rounded as in other formats, not written at float32
+		// precision
+		value, _ := strconv.ParseFloat(fmt.Sprintf("%.*f", decimals, bin.Value), 64)
+
+		geometry, box := geometry(bin.Polygons())
+
+		values := []interface{}{nil, geometry, value, unit}
+
+		if layer.Props.Elevation {
+			values = append(values, int64(bin.Elevation))
+		}
+
+		features[i] = row{int64(i + 1), record(values...)}
+		entries[i] = rtreeEntry{int64(i + 1), box}
+		bounds.extend(box)
+	}
+
+	d.createTable(layer.Name, sql+")", features)
+
+	rtree := "rtree_" + layer.Name + "_geom"
+	nodes, rowids, parents := rtreeTables(entries)
+
+	d.createVirtualTable(rtree, fmt.Sprintf("CREATE VIRTUAL TABLE %v USING rtree(id, minx, maxx, miny, maxy)", quote(rtree)))
+	d.createTable(rtree+"_node", fmt.Sprintf("CREATE TABLE %v(nodeno INTEGER PRIMARY KEY,data)", quote(rtree+"_node")), nodes)
+	d.createTable(rtree+"_rowid", fmt.Sprintf("CREATE TABLE %v(rowid INTEGER PRIMARY KEY,nodeno)", quote(rtree+"_rowid")), rowids)
+	d.createTable(rtree+"_parent", fmt.Sprintf("CREATE TABLE %v(nodeno INTEGER PRIMARY KEY,parentnode)", quote(rtree+"_parent")), parents)
+
+	return bounds
+}
+
+func quote(name string) string {
+	return `"` + strings.Replace(name, `"`, `""`, -1) + `"`
+}
+
+// geometry returns a GeoPackage geometry of the polygons, a header with the
+// envelope followed by a little endian WKB MultiPolygon of closed rings, and
+// the envelope.
+func geometry(polygons [][]proj.Coord) ([]byte, envelope) {
+	box := newEnvelope()
+
+	for _, ring := range polygons {
+		for _, c := range ring {
+			box.extend(envelope{c.X(), c.X(), c.Y(), c.Y()})
+		}
+	}
+
+	b := []byte{'G', 'P', 0, geometryFlags}
+	b = appendLittle(b, uint32(srsID))
+
+	for _, v := range []float64{box.MinX, box.MaxX, box.MinY, box.MaxY} {
+		b = appendLittle(b, math.Float64bits(v))
+	}
+
+	b = append(b, 1)
+	b = appendLittle(b, uint32(wkbMultiPolygon), uint32(len(polygons)))
+
+	for _, ring := range polygons {
+		b = append(b, 1)
+		b = appendLittle(b, uint32(wkbPolygon), uint32(1), uint32(len(ring)+1))
+
+		for i := 0; i <= len(ring); i++ {
+			c := ring[i%len(ring)]
+			b = appendLittle(b, math.Float64bits(c.X()), math.Float64bits(c.Y()))
+		}
+	}
+
+	return b, box
+}
+
+// appendLittle appends little endian uint32 and uint64 values.
+func appendLittle(b []byte, values ...interface{}) []byte {
+	for _, value := range values {
+		switch v := value.(type) {
+		case uint32:
+			var buf [4]byte
+			binary.LittleEndian.PutUint32(buf[:], v)
+			b = append(b, buf[:]...)
+		case uint64:
+			var buf [8]byte
+			binary.LittleEndian.PutUint64(buf[:], v)
+			b = append(b, buf[:]...)
+		}
+	}
+
+	return b
+}
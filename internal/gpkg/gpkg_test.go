@@ -0,0 +1,154 @@
+package gpkg
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+func TestWrite(t *testing.T) {
+	bins := []*geo.Bin{
+		geo.NewBin(proj.NewCoord(-105, 40, 0, 0), proj.NewCoord(-104.99, 40, 0, 0), proj.NewCoord(-104.99, 40.01, 0, 0), proj.NewCoord(-105, 40.01, 0, 0), 20),
+		geo.NewBin(proj.NewCoord(-104.99, 40, 0, 0), proj.NewCoord(-104.98, 40, 0, 0), proj.NewCoord(-104.98, 40.01, 0, 0), proj.NewCoord(-104.99, 40.01, 0, 0), 42.5),
+	}
+
+	layers := []*Layer{
+		{Name: "ref_1", Bins: bins, Props: &geo.FeatureProperties{Product: "REF"}},
+		{Name: "ref_2", Bins: []*geo.Bin{}, Props: &geo.FeatureProperties{Product: "REF"}},
+	}
+
+	var b bytes.Buffer
+
+	if err := Write(&b, layers); err != nil {
+		t.Fatal(err)
+	}
+
+	file := b.Bytes()
+
+	if !bytes.HasPrefix(file, []byte("SQLite format 3\x00")) {
+		t.Fatalf("expected an SQLite database")
+	}
+
+	if pages := binary.BigEndian.Uint32(file[28:]); int(pages)*pageSize != len(file) {
+		t.Errorf("expected %d pages in the header, got %d", len(file)/pageSize, pages)
+	}
+
+	if id := binary.BigEndian.Uint32(file[68:]); id != applicationID {
+		t.Errorf("expected GeoPackage application id, got %x", id)
+	}
+
+	for _, table := range []string{"gpkg_contents", "gpkg_geometry_columns", `"ref_1"`, `"rtree_ref_2_geom"`} {
+		if !bytes.Contains(file, []byte("CREATE VIRTUAL TABLE "+table)) && !bytes.Contains(file, []byte("CREATE TABLE "+table)) {
+			t.Errorf("expected schema to create %v", table)
+		}
+	}
+
+	if err := Write(&b, append(layers, layers[0])); err == nil {
+		t.Errorf("expected error for duplicate layers")
+	}
+}
+
+func TestGeometry(t *testing.T) {
+	bin := geo.NewBin(proj.NewCoord(-105, 40, 0, 0), proj.NewCoord(-104.99, 40, 0, 0), proj.NewCoord(-104.99, 40.01, 0, 0), proj.NewCoord(-105, 40.01, 0, 0), 20)
+
+	g, box := geometry(bin.Polygons())
+
+	if box != (envelope{-105, -104.99, 40, 40.01}) {
+		t.Errorf("unexpected envelope %v", box)
+	}
+
+	// header and envelope, then a MultiPolygon of one closed 5 point ring
+	if len(g) != 8+32+9+9+4+5*16 {
+		t.Fatalf("expected %d bytes, got %d", 8+32+9+9+4+5*16, len(g))
+	}
+
+	if g[3] != geometryFlags || binary.LittleEndian.Uint32(g[4:]) != srsID {
+		t.Errorf("unexpected header %v", g[:8])
+	}
+
+	if kind := binary.LittleEndian.Uint32(g[41:]); kind != wkbMultiPolygon {
+		t.Errorf("expected MultiPolygon, got %d", kind)
+	}
+
+	// the ring is closed by the first corner
+	first, last := g[len(g)-5*16:len(g)-4*16], g[len(g)-16:]
+
+	if !bytes.Equal(first, last) {
+		t.Errorf("expected ring to be closed")
+	}
+}
+
+func TestVarint(t *testing.T) {
+	cases := map[uint64][]byte{
+		0:                  {0x00},
+		127:                {0x7f},
+		128:                {0x81, 0x00},
+		240:                {0x81, 0x70},
+		16384:              {0x81, 0x80, 0x00},
+		math.MaxUint64:     {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff},
+		uint64(1<<56 - 1):  {0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0x7f},
+		uint64(1 << 56):    {0x80, 0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x00},
+		uint64(1<<63 + 42): {0xc0, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x80, 0x2a},
+	}
+
+	for v, expected := range cases {
+		if got := appendVarint(nil, v); !bytes.Equal(got, expected) {
+			t.Errorf("%d: expected %x, got %x", v, expected, got)
+		}
+	}
+}
+
+func TestRecord(t *testing.T) {
+	r := record(nil, int64(1), int64(300), 1.5, "ab", []byte{7})
+
+	expected := []byte{
+		// header length and serial types
+		7, 0, 9, 2, 7, 17, 14,
+		0x01, 0x2c,
+		0x3f, 0xf8, 0, 0, 0, 0, 0, 0,
+		'a', 'b',
+		7,
+	}
+
+	if !bytes.Equal(r, expected) {
+		t.Errorf("expected %v, got %v", expected, r)
+	}
+}
+
+func TestRTreeTables(t *testing.T) {
+	entries := make([]rtreeEntry, 3000)
+
+	for i := range entries {
+		x, y := float64(i%60), float64(i/60)
+		entries[i] = rtreeEntry{int64(i + 1), envelope{x, x + 1, y, y + 1}}
+	}
+
+	nodes, rowids, parents := rtreeTables(entries)
+
+	// 59 leaves, 2 interior nodes and the root
+	if len(nodes) != 62 {
+		t.Errorf("expected 62 nodes, got %d", len(nodes))
+	}
+
+	if len(rowids) != len(entries) {
+		t.Errorf("expected %d rowids, got %d", len(entries), len(rowids))
+	}
+
+	if len(parents) != len(nodes)-1 {
+		t.Errorf("expected a parent for all %d nodes but the root, got %d", len(nodes)-1, len(parents))
+	}
+
+	if nodes[0].RowID != 1 {
+		t.Errorf("expected root to be node 1, got %d", nodes[0].RowID)
+	}
+
+	for i := 1; i < len(rowids); i++ {
+		if rowids[i].RowID <= rowids[i-1].RowID {
+			t.Fatalf("expected rowids in order")
+		}
+	}
+}
@@ -0,0 +1,196 @@
+package gpkg
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+const (
+	// maxCells is the most entries of an R*Tree node, as SQLite allots them
+	maxCells = 51
+	// cellLen is the length of a 2 dimensional cell, a rowid and the 32 bit
+	// bounds of each dimension
+	cellLen = 8 + 4*4
+	// nodeLen is the length of each node, read by SQLite from the root
+	nodeLen = 4 + cellLen*maxCells
+)
+
+// envelope is a bounding box.
+type envelope struct {
+	MinX, MaxX, MinY, MaxY float64
+}
+
+func newEnvelope() envelope {
+	return envelope{math.Inf(1), math.Inf(-1), math.Inf(1), math.Inf(-1)}
+}
+
+func (e *envelope) extend(o envelope) {
+	e.MinX, e.MaxX = math.Min(e.MinX, o.MinX), math.Max(e.MaxX, o.MaxX)
+	e.MinY, e.MaxY = math.Min(e.MinY, o.MinY), math.Max(e.MaxY, o.MaxY)
+}
+
+func (e envelope) center() (float64, float64) {
+	return (e.MinX + e.MaxX) / 2, (e.MinY + e.MaxY) / 2
+}
+
+// rtreeEntry is an entry of an R*Tree node, a feature or a child node by id.
+type rtreeEntry struct {
+	ID  int64
+	Box envelope
+}
+
+// rtreeTables returns the rows of the _node, _rowid and _parent shadow
+// tables of an SQLite R*Tree indexing the entries, packed a level at a time
+// by sort-tile-recursive. The root is node 1.
+func rtreeTables(entries []rtreeEntry) ([]row, []row, []row) {
+	levels := [][][]rtreeEntry{pack(entries)}
+
+	for len(levels[len(levels)-1]) > 1 {
+		below := levels[len(levels)-1]
+		parents := make([]rtreeEntry, len(below))
+
+		for i, children := range below {
+			box := newEnvelope()
+
+			for _, child := range children {
+				box.extend(child.Box)
+			}
+
+			parents[i] = rtreeEntry{Box: box}
+		}
+
+		// children are numbered after their parents once every level is
+		// packed, so parents refer to them by their index in the level for
+		// now
+		levels = append(levels, pack(indexed(parents)))
+	}
+
+	// number the nodes from the root down
+	numbers := make([][]int64, len(levels))
+	next := int64(1)
+
+	for depth := len(levels) - 1; depth >= 0; depth-- {
+		numbers[depth] = make([]int64, len(levels[depth]))
+
+		for i := range levels[depth] {
+			numbers[depth][i] = next
+			next++
+		}
+	}
+
+	nodes := make([]row, 0, next-1)
+	rowids := make([]row, 0, len(entries))
+	parents := make([]row, 0, next-2)
+
+	for depth := len(levels) - 1; depth >= 0; depth-- {
+		for i, cells := range levels[depth] {
+			number := numbers[depth][i]
+			data := make([]byte, nodeLen)
+
+			if number == 1 {
+				binary.BigEndian.PutUint16(data, uint16(len(levels)-1))
+			}
+
+			binary.BigEndian.PutUint16(data[2:], uint16(len(cells)))
+
+			for j, cell := range cells {
+				id := cell.ID
+
+				if depth > 0 {
+					id = numbers[depth-1][cell.ID]
+					parents = append(parents, row{id, record(nil, number)})
+				} else {
+					rowids = append(rowids, row{id, record(nil, number)})
+				}
+
+				c := data[4+j*cellLen:]
+				binary.BigEndian.PutUint64(c, uint64(id))
+				binary.BigEndian.PutUint32(c[8:], math.Float32bits(roundDown(cell.Box.MinX)))
+				binary.BigEndian.PutUint32(c[12:], math.Float32bits(roundUp(cell.Box.MaxX)))
+				binary.BigEndian.PutUint32(c[16:], math.Float32bits(roundDown(cell.Box.MinY)))
+				binary.BigEndian.PutUint32(c[20:], math.Float32bits(roundUp(cell.Box.MaxY)))
+			}
+
+			nodes = append(nodes, row{number, record(nil, data)})
+		}
+	}
+
+	sort.Slice(rowids, func(i, j int) bool { return rowids[i].RowID < rowids[j].RowID })
+	sort.Slice(parents, func(i, j int) bool { return parents[i].RowID < parents[j].RowID })
+
+	return nodes, rowids, parents
+}
+
+// indexed returns the entries with their index as id.
+func indexed(entries []rtreeEntry) []rtreeEntry {
+	for i := range entries {
+		entries[i].ID = int64(i)
+	}
+
+	return entries
+}
+
+// pack groups entries into nodes of at most maxCells, sorting them into
+// vertical slices by x and each slice by y so nodes are compact. There is
+// always at least one node, the root of an empty tree having no entries.
+func pack(entries []rtreeEntry) [][]rtreeEntry {
+	if len(entries) <= maxCells {
+		return [][]rtreeEntry{entries}
+	}
+
+	sorted := make([]rtreeEntry, len(entries))
+	copy(sorted, entries)
+
+	sort.SliceStable(sorted, func(i, j int) bool {
+		x1, _ := sorted[i].Box.center()
+		x2, _ := sorted[j].Box.center()
+
+		return x1 < x2
+	})
+
+	count := (len(sorted) + maxCells - 1) / maxCells
+	slices := int(math.Ceil(math.Sqrt(float64(count))))
+	sliceLen := slices * maxCells
+
+	nodes := make([][]rtreeEntry, 0, count)
+
+	for start := 0; start < len(sorted); start += sliceLen {
+		slice := sorted[start:int(math.Min(float64(start+sliceLen), float64(len(sorted))))]
+
+		sort.SliceStable(slice, func(i, j int) bool {
+			_, y1 := slice[i].Box.center()
+			_, y2 := slice[j].Box.center()
+
+			return y1 < y2
+		})
+
+		for i := 0; i < len(slice); i += maxCells {
+			nodes = append(nodes, slice[i:int(math.Min(float64(i+maxCells), float64(len(slice))))])
+		}
+	}
+
+	return nodes
+}
+
+// roundDown and roundUp convert bounds to the 32 bit floats of R*Tree cells,
+// rounding outward so the cell still contains the feature.
+func roundDown(v float64) float32 {
+	f := float32(v)
+
+	if float64(f) > v {
+		f = math.Nextafter32(f, float32(math.Inf(-1)))
+	}
+
+	return f
+}
+
+func roundUp(v float64) float32 {
+	f := float32(v)
+
+	if float64(f) < v {
+		f = math.Nextafter32(f, float32(math.Inf(1)))
+	}
+
+	return f
+}
@@ -0,0 +1,531 @@
+package gpkg
+
+import (
+	"encoding/binary"
+	"math"
+	"sort"
+)
+
+// The database is written as SQLite file format 4, the subset needed for a
+// database created once: table and index B-trees with overflow pages, and no
+// free pages.
+const (
+	pageSize = 4096
+	// headerLen is the length of the database header at the start of page 1
+	headerLen = 100
+
+	pageIndexInterior = 0x02
+	pageTableInterior = 0x05
+	pageIndexLeaf     = 0x0A
+	pageTableLeaf     = 0x0D
+
+	// maxTableLocal and maxIndexLocal are the most payload stored in a cell
+	// before spilling to overflow pages, with no bytes reserved per page
+	maxTableLocal = pageSize - 35
+	maxIndexLocal = (pageSize-12)*64/255 - 23
+	minLocal      = (pageSize-12)*32/255 - 23
+
+	// sqliteVersion is written as the version of the library that last wrote
+	// the file
+	sqliteVersion = 3040001
+)
+
+// row is a row of a table B-tree, its rowid and record.
+type row struct {
+	RowID  int64
+	Record []byte
+}
+
+// indexKey is an entry of an index B-tree, the indexed text columns and the
+// rowid of the row they belong to.
+type indexKey struct {
+	Values []string
+	RowID  int64
+}
+
+// object is a row of sqlite_schema, a table or index and the statement that
+// created it. Automatic indexes have no statement.
+type object struct {
+	Type  string
+	Name  string
+	Table string
+	Root  int
+	SQL   string
+}
+
+type database struct {
+	// pages are numbered from 1, pages[0] being page 1
+	pages   [][]byte
+	objects []object
+}
+
+// newDatabase returns an empty database, with page 1 reserved for the schema.
+func newDatabase() *database {
+	d := &database{}
+	d.allocate()
+
+	return d
+}
+
+func (d *database) allocate() int {
+	d.pages = append(d.pages, make([]byte, pageSize))
+
+	return len(d.pages)
+}
+
+func (d *database) page(n int) []byte {
+	return d.pages[n-1]
+}
+
+// createTable writes a table of rows, which must be in rowid order, and adds
+// it to the schema.
+func (d *database) createTable(name string, sql string, rows []row) {
+	d.objects = append(d.objects, object{"table", name, name, d.tableTree(rows, 0), sql})
+}
+
+// createVirtualTable adds a virtual table to the schema. Its contents are
+// written to its shadow tables.
+func (d *database) createVirtualTable(name string, sql string) {
+	d.objects = append(d.objects, object{"table", name, name, 0, sql})
+}
+
+// createAutoIndex writes the index SQLite creates for the nth UNIQUE or
+// PRIMARY KEY constraint of a table, which must follow the table in the
+// schema. Keys must be unique.
+func (d *database) createAutoIndex(table string, n int, keys []indexKey) {
+	sort.Slice(keys, func(i, j int) bool {
+		for k := range keys[i].Values {
+			if keys[i].Values[k] != keys[j].Values[k] {
+				return keys[i].Values[k] < keys[j].Values[k]
+			}
+		}
+
+		return keys[i].RowID < keys[j].RowID
+	})
+
+	records := make([][]byte, len(keys))
+
+	for i, key := range keys {
+		values := make([]interface{}, 0, len(key.Values)+1)
+
+		for _, v := range key.Values {
+			values = append(values, v)
+		}
+
+		records[i] = record(append(values, key.RowID)...)
+	}
+
+	name := "sqlite_autoindex_" + table + "_" + string(rune('0'+n))
+	d.objects = append(d.objects, object{"index", name, table, d.indexTree(records, nil, 0), ""})
+}
+
+// bytes writes the schema to page 1 and returns the database file.
+func (d *database) bytes() []byte {
+	rows := make([]row, len(d.objects))
+
+	for i, o := range d.objects {
+		var sql interface{}
+
+		if o.SQL != "" {
+			sql = o.SQL
+		}
+
+		rows[i] = row{int64(i + 1), record(o.Type, o.Name, o.Table, int64(o.Root), sql)}
+	}
+
+	d.tableTree(rows, 1)
+
+	h := d.page(1)[:headerLen]
+
+	copy(h, "SQLite format 3\x00")
+	binary.BigEndian.PutUint16(h[16:], pageSize)
+	// legacy journal, no reserved bytes, and the fixed payload fractions
+	copy(h[18:], []byte{1, 1, 0, 64, 32, 32})
+	// file change counter
+	binary.BigEndian.PutUint32(h[24:], 1)
+	binary.BigEndian.PutUint32(h[28:], uint32(len(d.pages)))
+	// schema cookie and format
+	binary.BigEndian.PutUint32(h[40:], 1)
+	binary.BigEndian.PutUint32(h[44:], 4)
+	// UTF-8
+	binary.BigEndian.PutUint32(h[56:], 1)
+	binary.BigEndian.PutUint32(h[60:], userVersion)
+	binary.BigEndian.PutUint32(h[68:], applicationID)
+	// the page count is valid for this change counter
+	binary.BigEndian.PutUint32(h[92:], 1)
+	binary.BigEndian.PutUint32(h[96:], sqliteVersion)
+
+	file := make([]byte, 0, len(d.pages)*pageSize)
+
+	for _, p := range d.pages {
+		file = append(file, p...)
+	}
+
+	return file
+}
+
+// node is the cells of a B-tree page and its right-most child, before it is
+// written.
+type node struct {
+	Cells [][]byte
+	Right int
+}
+
+// fits returns true if a cell of length n can be added to a page of cells
+// with a header of length header, leaving space for the database header
+// where the page may be page 1.
+func fits(cells [][]byte, header int, n int, reserve int) bool {
+	used := reserve + header + 2*(len(cells)+1) + n
+
+	for _, c := range cells {
+		used += len(c)
+	}
+
+	return used <= pageSize
+}
+
+// tableTree writes a table B-tree of the rows, in rowid order, and returns
+// its root page, root if not 0.
+func (d *database) tableTree(rows []row, root int) int {
+	reserve := 0
+
+	if root == 1 {
+		reserve = headerLen
+	}
+
+	kind := byte(pageTableLeaf)
+	nodes := []node{{}}
+	// the largest rowid under each node, the key of its parent's cell
+	keys := []int64{0}
+
+	for _, r := range rows {
+		local, overflow := d.spill(r.Record, maxTableLocal)
+
+		cell := appendVarint(nil, uint64(len(r.Record)))
+		cell = appendVarint(cell, uint64(r.RowID))
+		cell = append(cell, local...)
+
+		if overflow != 0 {
+			cell = appendUint32(cell, overflow)
+		}
+
+		last := &nodes[len(nodes)-1]
+
+		if len(last.Cells) > 0 && !fits(last.Cells, 8, len(cell), reserve) {
+			nodes = append(nodes, node{})
+			keys = append(keys, 0)
+			last = &nodes[len(nodes)-1]
+		}
+
+		last.Cells = append(last.Cells, cell)
+		keys[len(keys)-1] = r.RowID
+	}
+
+	for len(nodes) > 1 {
+		pages := d.writeLevel(nodes, kind)
+
+		kind = pageTableInterior
+		parents := []node{{}}
+		parentKeys := []int64{0}
+
+		for i, page := range pages {
+			last := &parents[len(parents)-1]
+
+			if last.Right != 0 {
+				cell := appendUint32(nil, last.Right)
+				cell = appendVarint(cell, uint64(parentKeys[len(parentKeys)-1]))
+
+				if fits(last.Cells, 12, len(cell), reserve) {
+					last.Cells = append(last.Cells, cell)
+				} else {
+					parents = append(parents, node{})
+					parentKeys = append(parentKeys, 0)
+					last = &parents[len(parents)-1]
+				}
+			}
+
+			last.Right = page
+			parentKeys[len(parentKeys)-1] = keys[i]
+		}
+
+		nodes, keys = parents, parentKeys
+	}
+
+	if root == 0 {
+		root = d.allocate()
+	}
+
+	d.writePage(root, kind, nodes[0])
+
+	return root
+}
+
+// indexTree writes an index B-tree of the records, in key order, and returns
+// its root page, root if not 0. children are the pages between the records
+// for the interior levels, nil for the leaves.
+func (d *database) indexTree(records [][]byte, children []int, root int) int {
+	kind := byte(pageIndexLeaf)
+	header := 8
+
+	if children != nil {
+		kind = pageIndexInterior
+		header = 12
+	}
+
+	nodes := []node{{}}
+	// the records between the nodes, moved up to the parent level
+	dividers := make([][]byte, 0)
+
+	for i := 0; i < len(records); i++ {
+		cell := make([]byte, 0)
+
+		if children != nil {
+			cell = appendUint32(cell, children[i])
+		}
+
+		local, overflow := d.spill(records[i], maxIndexLocal)
+
+		cell = appendVarint(cell, uint64(len(records[i])))
+		cell = append(cell, local...)
+
+		if overflow != 0 {
+			cell = appendUint32(cell, overflow)
+		}
+
+		last := &nodes[len(nodes)-1]
+
+		if len(last.Cells) == 0 || fits(last.Cells, header, len(cell), 0) {
+			last.Cells = append(last.Cells, cell)
+			continue
+		}
+
+		// the record that doesn't fit divides this node from the next, unless
+		// it is the last, which needs a node of its own; then the one before
+		// it divides them
+		divider := i
+
+		if i == len(records)-1 && len(last.Cells) > 1 {
+			divider = i - 1
+			last.Cells = last.Cells[:len(last.Cells)-1]
+		}
+
+		if children != nil {
+			last.Right = children[divider]
+		}
+
+		dividers = append(dividers, records[divider])
+		nodes = append(nodes, node{})
+		i = divider
+	}
+
+	if children != nil {
+		nodes[len(nodes)-1].Right = children[len(children)-1]
+	}
+
+	if len(nodes) == 1 {
+		if root == 0 {
+			root = d.allocate()
+		}
+
+		d.writePage(root, kind, nodes[0])
+
+		return root
+	}
+
+	return d.indexTree(dividers, d.writeLevel(nodes, kind), root)
+}
+
+// writeLevel writes the nodes of a B-tree level to new pages, returning the
+// pages.
+func (d *database) writeLevel(nodes []node, kind byte) []int {
+	pages := make([]int, len(nodes))
+
+	for i, n := range nodes {
+		pages[i] = d.allocate()
+		d.writePage(pages[i], kind, n)
+	}
+
+	return pages
+}
+
+// writePage writes the header, cell pointers and cells of a B-tree page, the
+// cells packed at the end of the page.
+func (d *database) writePage(n int, kind byte, contents node) {
+	p := d.page(n)
+	offset := 0
+
+	if n == 1 {
+		offset = headerLen
+	}
+
+	p[offset] = kind
+	binary.BigEndian.PutUint16(p[offset+3:], uint16(len(contents.Cells)))
+
+	pointers := offset + 8
+
+	if kind == pageTableInterior || kind == pageIndexInterior {
+		binary.BigEndian.PutUint32(p[offset+8:], uint32(contents.Right))
+		pointers += 4
+	}
+
+	end := pageSize
+
+	for i, cell := range contents.Cells {
+		end -= len(cell)
+		copy(p[end:], cell)
+		binary.BigEndian.PutUint16(p[pointers+2*i:], uint16(end))
+	}
+
+	binary.BigEndian.PutUint16(p[offset+5:], uint16(end))
+}
+
+// spill returns the part of a payload stored in its cell, writing the rest
+// to a chain of overflow pages, and the first overflow page, or 0 if it fits
+// in the cell.
+func (d *database) spill(payload []byte, maxLocal int) ([]byte, int) {
+	if len(payload) <= maxLocal {
+		return payload, 0
+	}
+
+	local := minLocal + (len(payload)-minLocal)%(pageSize-4)
+
+	if local > maxLocal {
+		local = minLocal
+	}
+
+	first := 0
+	var previous []byte
+
+	for rest := payload[local:]; len(rest) > 0; {
+		n := d.allocate()
+
+		if previous == nil {
+			first = n
+		} else {
+			binary.BigEndian.PutUint32(previous, uint32(n))
+		}
+
+		// each overflow page starts with the next page of the chain
+		previous = d.page(n)[:4]
+		rest = rest[copy(d.page(n)[4:], rest):]
+	}
+
+	return payload[:local], first
+}
+
+// record encodes values as an SQLite record, a header of serial types and
+// the values. Values are nil, int64, float64, string or []byte.
+func record(values ...interface{}) []byte {
+	types := make([]byte, 0, len(values))
+	body := make([]byte, 0)
+
+	for _, value := range values {
+		switch v := value.(type) {
+		case nil:
+			types = appendVarint(types, 0)
+		case int64:
+			t, n := integerType(v)
+			types = appendVarint(types, t)
+
+			for i := n - 1; i >= 0; i-- {
+				body = append(body, byte(v>>(8*uint(i))))
+			}
+		case float64:
+			types = appendVarint(types, 7)
+			body = appendUint64(body, math.Float64bits(v))
+		case string:
+			types = appendVarint(types, uint64(2*len(v)+13))
+			body = append(body, v...)
+		case []byte:
+			types = appendVarint(types, uint64(2*len(v)+12))
+			body = append(body, v...)
+		default:
+			panic("unsupported record value")
+		}
+	}
+
+	// the header length includes its own varint
+	length := len(types) + 1
+
+	if len(appendVarint(nil, uint64(length))) > 1 {
+		length = len(types) + len(appendVarint(nil, uint64(length+1)))
+	}
+
+	r := appendVarint(nil, uint64(length))
+	r = append(r, types...)
+
+	return append(r, body...)
+}
+
+// integerType returns the serial type of an integer and the number of bytes
+// it is stored in.
+func integerType(v int64) (uint64, int) {
+	switch {
+	case v == 0:
+		return 8, 0
+	case v == 1:
+		return 9, 0
+	case v >= -1<<7 && v < 1<<7:
+		return 1, 1
+	case v >= -1<<15 && v < 1<<15:
+		return 2, 2
+	case v >= -1<<23 && v < 1<<23:
+		return 3, 3
+	case v >= -1<<31 && v < 1<<31:
+		return 4, 4
+	case v >= -1<<47 && v < 1<<47:
+		return 5, 6
+	default:
+		return 6, 8
+	}
+}
+
+// appendVarint appends an SQLite variable length integer, big-endian groups
+// of 7 bits with the high bit set on all but the last byte, and all 8 bits of
+// a ninth byte.
+func appendVarint(b []byte, v uint64) []byte {
+	if v > 1<<56-1 {
+		var buf [9]byte
+
+		buf[8] = byte(v)
+		v >>= 8
+
+		for i := 7; i >= 0; i-- {
+			buf[i] = byte(v&0x7f) | 0x80
+			v >>= 7
+		}
+
+		return append(b, buf[:]...)
+	}
+
+	var buf [8]byte
+	n := len(buf)
+
+	for {
+		n--
+		buf[n] = byte(v&0x7f) | 0x80
+		v >>= 7
+
+		if v == 0 {
+			break
+		}
+	}
+
+	buf[len(buf)-1] &= 0x7f
+
+	return append(b, buf[n:]...)
+}
+
+func appendUint32(b []byte, v int) []byte {
+	var buf [4]byte
+	binary.BigEndian.PutUint32(buf[:], uint32(v))
+
+	return append(b, buf[:]...)
+}
+
+func appendUint64(b []byte, v uint64) []byte {
+	var buf [8]byte
+	binary.BigEndian.PutUint64(buf[:], v)
+
+	return append(b, buf[:]...)
+}
@@ -0,0 +1,81 @@
+package gpkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// TestSQLite opens a written GeoPackage with the sqlite3 command line shell,
+// checking the file and its R*Tree indexes and querying the features, as a
+// reader using SQLite itself would.
+func TestSQLite(t *testing.T) {
+	sqlite3, err := exec.LookPath("sqlite3")
+
+	if err != nil {
+		t.Skip("sqlite3 not found on PATH")
+	}
+
+	// enough bins to fill several pages of the table and of the index
+	bins := make([]*geo.Bin, 0, 3000)
+
+	for i := 0; i < cap(bins); i++ {
+		x, y := -105+0.01*float64(i%60), 40+0.01*float64(i/60)
+		bins = append(bins, geo.NewBin(proj.NewCoord(x, y, 0, 0), proj.NewCoord(x+0.01, y, 0, 0), proj.NewCoord(x+0.01, y+0.01, 0, 0), proj.NewCoord(x, y+0.01, 0, 0), float32(i%70)))
+	}
+
+	layers := []*Layer{
+		{Name: "ref_1", Bins: bins, Props: &geo.FeatureProperties{Product: "REF"}},
+		{Name: "ref_2", Bins: bins[:1], Props: &geo.FeatureProperties{Product: "REF"}},
+		{Name: "ref_3", Bins: []*geo.Bin{}, Props: &geo.FeatureProperties{Product: "REF"}},
+	}
+
+	var b bytes.Buffer
+
+	if err := Write(&b, layers); err != nil {
+		t.Fatal(err)
+	}
+
+	filename := filepath.Join(t.TempDir(), "radar.gpkg")
+
+	if err := os.WriteFile(filename, b.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	queries := []struct {
+		sql      string
+		expected string
+	}{
+		{"PRAGMA integrity_check", "ok"},
+		{"PRAGMA application_id", fmt.Sprint(applicationID)},
+		{"SELECT rtreecheck('rtree_ref_1_geom')", "ok"},
+		{"SELECT rtreecheck('rtree_ref_2_geom')", "ok"},
+		{"SELECT rtreecheck('rtree_ref_3_geom')", "ok"},
+		{"SELECT count(*), max(ref), min(fid), max(fid) FROM ref_1", "3000|69.0|1|3000"},
+		{"SELECT count(*) FROM ref_3", "0"},
+		{"SELECT hex(substr(geom, 1, 2)), ref, unit FROM ref_2", "4750|0.0|dBZ"},
+		{"SELECT table_name, data_type FROM gpkg_contents ORDER BY table_name", "ref_1|features\nref_2|features\nref_3|features"},
+		// the index finds the features whose bounds intersect a box
+		{"SELECT count(*) FROM rtree_ref_1_geom WHERE maxx >= -104.505 AND minx <= -104.495 AND maxy >= 40.205 AND miny <= 40.215", "4"},
+		{"SELECT count(*) FROM ref_1 JOIN rtree_ref_1_geom r ON r.id = fid WHERE r.minx = -105 AND r.miny = 40", "1"},
+	}
+
+	for _, q := range queries {
+		out, err := exec.Command(sqlite3, "-readonly", filename, q.sql).CombinedOutput()
+
+		if err != nil {
+			t.Fatalf("%v: %s: %s", q.sql, err, out)
+		}
+
+		if got := strings.TrimSpace(string(out)); got != q.expected {
+			t.Errorf("%v: expected %q, got %q", q.sql, q.expected, got)
+		}
+	}
+}
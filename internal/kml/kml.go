@@ -0,0 +1,147 @@
+// Package kml writes bins as KML placemarks for Google Earth, or zipped as
+// KMZ.
+package kml
+
+import (
+	"archive/zip"
+	"bufio"
+	"encoding/xml"
+	"fmt"
+	"image/color"
+	"io"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// fillOpacity is the alpha of polygon fills, matching the GeoJSON fill-opacity
+const fillOpacity = 0xcc
+
+// Write writes a KML document named name with a polygon placemark per bin,
+// filled with the colormap color of its value. Bins below the colormap are
+// transparent and left out. Placemarks carry the value keyed by the lowercase
+// product, the unit, and the elevation number if props.Elevation is set. If
+// props.Height is set, each polygon is drawn at the height of its beam center
+// above sea level, props.AntennaHeight plus the bin's height above the radar,
+// for a 3D view of the scan.
+func Write(w io.Writer, bins []*geo.Bin, props *geo.FeatureProperties, cm *colormap.Colormap, name string) error {
+	b := bufio.NewWriter(w)
+
+	fmt.Fprint(b, xml.Header)
+	fmt.Fprint(b, "<kml xmlns=\"http://www.opengis.net/kml/2.2\"><Document><name>")
+	xml.EscapeText(b, []byte(name))
+	fmt.Fprint(b, "</name>")
+
+	styles := make(map[string]bool, len(cm.Breaks))
+
+	for _, brk := range cm.Breaks {
+		if styles[styleID(brk.Color)] {
+			continue
+		}
+
+		styles[styleID(brk.Color)] = true
+
+		fmt.Fprintf(b, "<Style id=\"%s\"><LineStyle><width>0</width></LineStyle><PolyStyle><color>%s</color><outline>0</outline></PolyStyle></Style>", styleID(brk.Color), kmlColor(brk.Color))
+	}
+
+	key := strings.ToLower(props.Product)
+	unit := props.Unit()
+	decimals := props.ValueDecimals()
+
+	for _, bin := range bins {
+		c, ok := cm.Color(bin.Value)
+
+		if !ok {
+			continue
+		}
+
+		fmt.Fprintf(b, "<Placemark><styleUrl>#%s</styleUrl><ExtendedData>", styleID(c))
+		fmt.Fprintf(b, "<Data name=\"%s\"><value>%.*f</value></Data>", key, decimals, bin.Value)
+		fmt.Fprintf(b, "<Data name=\"unit\"><value>%s</value></Data>", unit)
+
+		if props.Elevation {
+			fmt.Fprintf(b, "<Data name=\"elevation\"><value>%d</value></Data>", bin.Elevation)
+		}
+
+		fmt.Fprint(b, "</ExtendedData>")
+
+		polygons := bin.Polygons()
+
+		if len(polygons) > 1 {
+			fmt.Fprint(b, "<MultiGeometry>")
+		}
+
+		for _, ring := range polygons {
+			appendPolygon(b, ring, bin, props)
+		}
+
+		if len(polygons) > 1 {
+			fmt.Fprint(b, "</MultiGeometry>")
+		}
+
+		fmt.Fprint(b, "</Placemark>")
+	}
+
+	fmt.Fprint(b, "</Document></kml>\n")
+
+	return b.Flush()
+}
+
+// WriteKMZ writes the KML document zipped as doc.kml, the entry Google Earth
+// opens in a KMZ.
+func WriteKMZ(w io.Writer, bins []*geo.Bin, props *geo.FeatureProperties, cm *colormap.Colormap, name string) error {
+	z := zip.NewWriter(w)
+
+	doc, err := z.Create("doc.kml")
+
+	if err != nil {
+		return err
+	}
+
+	if err := Write(doc, bins, props, cm, name); err != nil {
+		return err
+	}
+
+	return z.Close()
+}
+
+// appendPolygon writes the ring as a closed KML polygon, at the bin's beam
+// height above sea level if props.Height is set or clamped to the ground
+// otherwise. Heights are absolute, as the beam is above the radar rather than
+// the terrain under each bin.
+func appendPolygon(w io.Writer, ring []proj.Coord, bin *geo.Bin, props *geo.FeatureProperties) {
+	fmt.Fprint(w, "<Polygon>")
+
+	if props.Height {
+		fmt.Fprint(w, "<altitudeMode>absolute</altitudeMode>")
+	}
+
+	fmt.Fprint(w, "<outerBoundaryIs><LinearRing><coordinates>")
+
+	for i := 0; i <= len(ring); i++ {
+		c := ring[i%len(ring)]
+
+		if i > 0 {
+			fmt.Fprint(w, " ")
+		}
+
+		if props.Height {
+			fmt.Fprintf(w, "%.*f,%.*f,%.0f", props.Precision, c.X(), props.Precision, c.Y(), props.AntennaHeight+bin.Height)
+		} else {
+			fmt.Fprintf(w, "%.*f,%.*f", props.Precision, c.X(), props.Precision, c.Y())
+		}
+	}
+
+	fmt.Fprint(w, "</coordinates></LinearRing></outerBoundaryIs></Polygon>")
+}
+
+func styleID(c color.RGBA) string {
+	return fmt.Sprintf("c%02x%02x%02x", c.R, c.G, c.B)
+}
+
+// kmlColor formats a color as KML's aabbggrr.
+func kmlColor(c color.RGBA) string {
+	return fmt.Sprintf("%02x%02x%02x%02x", fillOpacity, c.B, c.G, c.R)
+}
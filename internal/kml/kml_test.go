@@ -0,0 +1,138 @@
+package kml
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+type document struct {
+	Name       string  `xml:"Document>name"`
+	Styles     []style `xml:"Document>Style"`
+	Placemarks []struct {
+		StyleURL     string   `xml:"styleUrl"`
+		Data         []string `xml:"ExtendedData>Data>value"`
+		AltitudeMode string   `xml:"Polygon>altitudeMode"`
+		Coordinates  string   `xml:"Polygon>outerBoundaryIs>LinearRing>coordinates"`
+	} `xml:"Document>Placemark"`
+}
+
+type style struct {
+	ID    string `xml:"id,attr"`
+	Color string `xml:"PolyStyle>color"`
+}
+
+func testBins() []*geo.Bin {
+	bins := []*geo.Bin{
+		geo.NewBin(proj.NewCoord(-105, 40, 0, 0), proj.NewCoord(-104.99, 40, 0, 0), proj.NewCoord(-105, 40.01, 0, 0), proj.NewCoord(-104.99, 40.01, 0, 0), 42.5),
+		// below the first reflectivity break
+		geo.NewBin(proj.NewCoord(-104.99, 40, 0, 0), proj.NewCoord(-104.98, 40, 0, 0), proj.NewCoord(-104.99, 40.01, 0, 0), proj.NewCoord(-104.98, 40.01, 0, 0), 2),
+	}
+
+	bins[0].Height = 1234.4
+
+	return bins
+}
+
+func TestWrite(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := Write(&b, testBins(), &geo.FeatureProperties{Product: "REF", Precision: 2, Height: true, AntennaHeight: 1700}, colormap.Reflectivity, "KFTG REF"); err != nil {
+		t.Fatal(err)
+	}
+
+	var doc document
+
+	if err := xml.Unmarshal(b.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Name != "KFTG REF" {
+		t.Errorf("expected document name KFTG REF, got %v", doc.Name)
+	}
+
+	if len(doc.Styles) != len(colormap.Reflectivity.Breaks) {
+		t.Errorf("expected a style per colormap break, got %d", len(doc.Styles))
+	}
+
+	if len(doc.Placemarks) != 1 {
+		t.Fatalf("expected the bin below the colormap to be left out, got %d placemarks", len(doc.Placemarks))
+	}
+
+	placemark := doc.Placemarks[0]
+
+	// 40 dBZ is 0xe5bc00, written as aabbggrr
+	if placemark.StyleURL != "#ce5bc00" {
+		t.Errorf("expected style #ce5bc00, got %v", placemark.StyleURL)
+	}
+
+	for _, s := range doc.Styles {
+		if s.ID == "ce5bc00" && s.Color != "cc00bce5" {
+			t.Errorf("expected color cc00bce5, got %v", s.Color)
+		}
+	}
+
+	if strings.Join(placemark.Data, " ") != "42.5 dBZ" {
+		t.Errorf("expected value and unit 42.5 dBZ, got %v", placemark.Data)
+	}
+
+	if placemark.AltitudeMode != "absolute" {
+		t.Errorf("expected altitude mode absolute, got %v", placemark.AltitudeMode)
+	}
+
+	// closed, at the beam height above the antenna
+	expected := "-105.00,40.00,2934 -104.99,40.00,2934 -104.99,40.01,2934 -105.00,40.01,2934 -105.00,40.00,2934"
+
+	if placemark.Coordinates != expected {
+		t.Errorf("expected coordinates %v, got %v", expected, placemark.Coordinates)
+	}
+}
+
+func TestWriteKMZ(t *testing.T) {
+	var b bytes.Buffer
+
+	if err := WriteKMZ(&b, testBins(), &geo.FeatureProperties{Product: "REF", Precision: 4}, colormap.Reflectivity, "KFTG REF"); err != nil {
+		t.Fatal(err)
+	}
+
+	z, err := zip.NewReader(bytes.NewReader(b.Bytes()), int64(b.Len()))
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(z.File) != 1 || z.File[0].Name != "doc.kml" {
+		t.Fatalf("expected a single doc.kml entry, got %v", z.File)
+	}
+
+	f, err := z.File[0].Open()
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	data, err := io.ReadAll(f)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var doc document
+
+	if err := xml.Unmarshal(data, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Placemarks) != 1 {
+		t.Errorf("expected 1 placemark, got %d", len(doc.Placemarks))
+	}
+}
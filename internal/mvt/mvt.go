@@ -0,0 +1,405 @@
+// Package mvt encodes bins as Mapbox Vector Tiles.
+package mvt
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+)
+
+const (
+	// Extent is the number of tile coordinate units across a tile
+	Extent = 4096
+	// Buffer is the number of tile coordinate units polygons extend past the
+	// tile edge, hiding seams between clipped polygons when rendered
+	Buffer = 64
+	// MaxZoom is the highest supported zoom level
+	MaxZoom = 22
+)
+
+// command ids and the polygon geometry type, from the MVT specification
+const (
+	moveTo      = 1
+	lineTo      = 2
+	closePath   = 7
+	typePolygon = 3
+)
+
+// point is a position in world tile units, or tile coordinates once clipped.
+type point struct {
+	X float64
+	Y float64
+}
+
+type tileKey struct {
+	X int
+	Y int
+}
+
+// layer accumulates the features of one tile, with the keys and values
+// dictionaries shared by its features.
+type layer struct {
+	Features [][]byte
+	Keys     []string
+	Values   [][]byte
+	keyIndex map[string]int
+	valIndex map[string]int
+}
+
+func newLayer() *layer {
+	return &layer{
+		keyIndex: make(map[string]int),
+		valIndex: make(map[string]int),
+	}
+}
+
+// tag returns the key and value indexes of a feature attribute, adding them to
+// the layer's dictionaries if needed.
+func (l *layer) tag(key string, value []byte) (int, int) {
+	k, ok := l.keyIndex[key]
+
+	if !ok {
+		k = len(l.Keys)
+		l.keyIndex[key] = k
+		l.Keys = append(l.Keys, key)
+	}
+
+	v, ok := l.valIndex[string(value)]
+
+	if !ok {
+		v = len(l.Values)
+		l.valIndex[string(value)] = v
+		l.Values = append(l.Values, value)
+	}
+
+	return k, v
+}
+
+// WriteTiles clips the bins to every tile they cover at the zoom level and
+// writes each tile to dir/zoom/x/y.pbf, with a single layer named after the
+// lowercase product. Features carry the value keyed by the lowercase product,
+// the unit, and the elevation number if props.Elevation is set.
+func WriteTiles(dir string, bins []*geo.Bin, props *geo.FeatureProperties, zoom int) error {
+	if zoom < 0 || zoom > MaxZoom {
+		return fmt.Errorf("zoom %d out of range 0-%d", zoom, MaxZoom)
+	}
+
+	tiles := make(map[tileKey]*layer)
+	n := 1 << uint(zoom)
+
+	for _, bin := range bins {
+		ring := make([]point, 0, 4)
+
+		for _, c := range bin.Ring() {
+			ring = append(ring, project(c.X(), c.Y(), n))
+		}
+
+		minX, minY, maxX, maxY := bounds(ring)
+
+		for x := clamp(int(math.Floor(minX)), n); x <= clamp(int(math.Floor(maxX)), n); x++ {
+			for y := clamp(int(math.Floor(minY)), n); y <= clamp(int(math.Floor(maxY)), n); y++ {
+				geometry := encodeRing(clip(toTile(ring, x, y)))
+
+				if geometry == nil {
+					continue
+				}
+
+				key := tileKey{X: x, Y: y}
+
+				l, ok := tiles[key]
+
+				if !ok {
+					l = newLayer()
+					tiles[key] = l
+				}
+
+				l.Features = append(l.Features, encodeFeature(l, bin, props, geometry))
+			}
+		}
+	}
+
+	name := strings.ToLower(props.Product)
+
+	for key, l := range tiles {
+		path := filepath.Join(dir, fmt.Sprint(zoom), fmt.Sprint(key.X), fmt.Sprintf("%d.pbf", key.Y))
+
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			return err
+		}
+
+		if err := os.WriteFile(path, encodeTile(name, l), 0644); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// project converts longitude and latitude to spherical mercator world
+// coordinates in tile units, with y increasing southward.
+func project(lon float64, lat float64, n int) point {
+	latRadians := lat * math.Pi / 180
+
+	return point{
+		X: (lon + 180) / 360 * float64(n),
+		Y: (1 - math.Log(math.Tan(latRadians)+1/math.Cos(latRadians))/math.Pi) / 2 * float64(n),
+	}
+}
+
+func bounds(ring []point) (float64, float64, float64, float64) {
+	minX, minY := math.Inf(1), math.Inf(1)
+	maxX, maxY := math.Inf(-1), math.Inf(-1)
+
+	for _, p := range ring {
+		minX, minY = math.Min(minX, p.X), math.Min(minY, p.Y)
+		maxX, maxY = math.Max(maxX, p.X), math.Max(maxY, p.Y)
+	}
+
+	return minX, minY, maxX, maxY
+}
+
+func clamp(tile int, n int) int {
+	if tile < 0 {
+		return 0
+	}
+
+	if tile >= n {
+		return n - 1
+	}
+
+	return tile
+}
+
+// toTile converts world coordinates to the coordinates of tile x, y.
+func toTile(ring []point, x int, y int) []point {
+	local := make([]point, len(ring))
+
+	for i, p := range ring {
+		local[i] = point{X: (p.X - float64(x)) * Extent, Y: (p.Y - float64(y)) * Extent}
+	}
+
+	return local
+}
+
+// clip clips the ring to the tile and its buffer with the Sutherland-Hodgman
+// algorithm.
+func clip(ring []point) []point {
+	const lower, upper = -Buffer, Extent + Buffer
+
+	edges := []struct {
+		inside    func(p point) bool
+		intersect func(a point, b point) point
+	}{
+		{
+			func(p point) bool { return p.X >= lower },
+			func(a point, b point) point { return point{X: lower, Y: a.Y + (b.Y-a.Y)*(lower-a.X)/(b.X-a.X)} },
+		},
+		{
+			func(p point) bool { return p.X <= upper },
+			func(a point, b point) point { return point{X: upper, Y: a.Y + (b.Y-a.Y)*(upper-a.X)/(b.X-a.X)} },
+		},
+		{
+			func(p point) bool { return p.Y >= lower },
+			func(a point, b point) point { return point{X: a.X + (b.X-a.X)*(lower-a.Y)/(b.Y-a.Y), Y: lower} },
+		},
+		{
+			func(p point) bool { return p.Y <= upper },
+			func(a point, b point) point { return point{X: a.X + (b.X-a.X)*(upper-a.Y)/(b.Y-a.Y), Y: upper} },
+		},
+	}
+
+	for _, edge := range edges {
+		if len(ring) == 0 {
+			return ring
+		}
+
+		clipped := make([]point, 0, len(ring)+1)
+		previous := ring[len(ring)-1]
+
+		for _, current := range ring {
+			if edge.inside(current) {
+				if !edge.inside(previous) {
+					clipped = append(clipped, edge.intersect(previous, current))
+				}
+
+				clipped = append(clipped, current)
+			} else if edge.inside(previous) {
+				clipped = append(clipped, edge.intersect(previous, current))
+			}
+
+			previous = current
+		}
+
+		ring = clipped
+	}
+
+	return ring
+}
+
+func zigzag(v int64) uint32 {
+	return uint32((v << 1) ^ (v >> 63))
+}
+
+func command(id uint32, count int) uint32 {
+	return id&0x7 | uint32(count)<<3
+}
+
+// encodeRing rounds the ring to integer tile coordinates and encodes it as
+// polygon geometry commands, or returns nil if fewer than three distinct
+// positions remain. Exterior rings must have a positive area in tile
+// coordinates, so the ring is reversed if needed.
+func encodeRing(ring []point) []uint32 {
+	rounded := make([][2]int64, 0, len(ring))
+
+	for _, p := range ring {
+		q := [2]int64{int64(math.Round(p.X)), int64(math.Round(p.Y))}
+
+		if len(rounded) == 0 || rounded[len(rounded)-1] != q {
+			rounded = append(rounded, q)
+		}
+	}
+
+	for len(rounded) > 1 && rounded[0] == rounded[len(rounded)-1] {
+		rounded = rounded[:len(rounded)-1]
+	}
+
+	if len(rounded) < 3 {
+		return nil
+	}
+
+	var area int64
+
+	for i := range rounded {
+		a, b := rounded[i], rounded[(i+1)%len(rounded)]
+		area += a[0]*b[1] - b[0]*a[1]
+	}
+
+	if area == 0 {
+		return nil
+	}
+
+	if area < 0 {
+		for i, j := 0, len(rounded)-1; i < j; i, j = i+1, j-1 {
+			rounded[i], rounded[j] = rounded[j], rounded[i]
+		}
+	}
+
+	geometry := make([]uint32, 0, 2*len(rounded)+3)
+	var cursor [2]int64
+
+	for i, p := range rounded {
+		if i == 0 {
+			geometry = append(geometry, command(moveTo, 1))
+		} else if i == 1 {
+			geometry = append(geometry, command(lineTo, len(rounded)-1))
+		}
+
+		geometry = append(geometry, zigzag(p[0]-cursor[0]), zigzag(p[1]-cursor[1]))
+		cursor = p
+	}
+
+	return append(geometry, command(closePath, 1))
+}
+
+func encodeFeature(l *layer, bin *geo.Bin, props *geo.FeatureProperties, geometry []uint32) []byte {
+	tags := make([]uint32, 0, 6)
+
+	k, v := l.tag(strings.ToLower(props.Product), floatValue(bin.Value))
+	tags = append(tags, uint32(k), uint32(v))
+
+	k, v = l.tag("unit", stringValue(props.Unit()))
+	tags = append(tags, uint32(k), uint32(v))
+
+	if props.Elevation {
+		k, v = l.tag("elevation", uintValue(uint64(bin.Elevation)))
+		tags = append(tags, uint32(k), uint32(v))
+	}
+
+	var feature []byte
+	feature = appendPacked(feature, 2, tags)
+	feature = appendVarintField(feature, 3, typePolygon)
+	feature = appendPacked(feature, 4, geometry)
+
+	return feature
+}
+
+func encodeTile(name string, l *layer) []byte {
+	var encoded []byte
+
+	encoded = appendVarintField(encoded, 15, 2)
+	encoded = appendBytes(encoded, 1, []byte(name))
+
+	for _, feature := range l.Features {
+		encoded = appendBytes(encoded, 2, feature)
+	}
+
+	for _, key := range l.Keys {
+		encoded = appendBytes(encoded, 3, []byte(key))
+	}
+
+	for _, value := range l.Values {
+		encoded = appendBytes(encoded, 4, value)
+	}
+
+	encoded = appendVarintField(encoded, 5, Extent)
+
+	return appendBytes(nil, 3, encoded)
+}
+
+// protobuf wire types
+const (
+	wireVarint  = 0
+	wireBytes   = 2
+	wireFixed32 = 5
+)
+
+func appendVarint(b []byte, v uint64) []byte {
+	for v >= 0x80 {
+		b = append(b, byte(v)|0x80)
+		v >>= 7
+	}
+
+	return append(b, byte(v))
+}
+
+func appendKey(b []byte, field int, wireType int) []byte {
+	return appendVarint(b, uint64(field<<3|wireType))
+}
+
+func appendVarintField(b []byte, field int, v uint64) []byte {
+	return appendVarint(appendKey(b, field, wireVarint), v)
+}
+
+func appendBytes(b []byte, field int, v []byte) []byte {
+	b = appendVarint(appendKey(b, field, wireBytes), uint64(len(v)))
+
+	return append(b, v...)
+}
+
+func appendPacked(b []byte, field int, values []uint32) []byte {
+	var packed []byte
+
+	for _, v := range values {
+		packed = appendVarint(packed, uint64(v))
+	}
+
+	return appendBytes(b, field, packed)
+}
+
+func stringValue(s string) []byte {
+	return appendBytes(nil, 1, []byte(s))
+}
+
+func floatValue(f float32) []byte {
+	bits := math.Float32bits(f)
+
+	return append(appendKey(nil, 2, wireFixed32), byte(bits), byte(bits>>8), byte(bits>>16), byte(bits>>24))
+}
+
+func uintValue(v uint64) []byte {
+	return appendVarintField(nil, 5, v)
+}
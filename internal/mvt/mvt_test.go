@@ -0,0 +1,35 @@
+package mvt
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestEncodeRing(t *testing.T) {
+	// counterclockwise in tile coordinates, so reversed to a positive area
+	ring := []point{{0, 0}, {0, 10}, {10, 10}, {10, 0}}
+
+	want := []uint32{
+		command(moveTo, 1), zigzag(10), zigzag(0),
+		command(lineTo, 3), zigzag(0), zigzag(10), zigzag(-10), zigzag(0), zigzag(0), zigzag(-10),
+		command(closePath, 1),
+	}
+
+	if got := encodeRing(ring); !reflect.DeepEqual(got, want) {
+		t.Errorf("expected %v, got %v", want, got)
+	}
+}
+
+func TestClip(t *testing.T) {
+	ring := clip([]point{{-1000, -1000}, {5000, -1000}, {5000, 5000}, {-1000, 5000}})
+
+	for _, p := range ring {
+		if p.X < -Buffer || p.X > Extent+Buffer || p.Y < -Buffer || p.Y > Extent+Buffer {
+			t.Errorf("point %v outside of the buffered tile", p)
+		}
+	}
+
+	if len(ring) != 4 {
+		t.Errorf("expected 4 points, got %d", len(ring))
+	}
+}
@@ -0,0 +1,154 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// TIFF field types
+const (
+	tiffASCII  = 2
+	tiffShort  = 3
+	tiffLong   = 4
+	tiffDouble = 12
+)
+
+// GeoKey values for a WGS84 geographic raster, see the GeoTIFF specification
+const (
+	geoKeyModelType       = 1024
+	geoKeyRasterType      = 1025
+	geoKeyGeographicType  = 2048
+	modelTypeGeographic   = 2
+	rasterPixelIsArea     = 1
+	geographicTypeWGS84   = 4326
+	tiffHeaderLen         = 8
+	tiffIFDEntryLen       = 12
+	tiffSampleFormatFloat = 3
+)
+
+type tiffEntry struct {
+	tag   uint16
+	kind  uint16
+	count uint32
+	data  []byte
+}
+
+func shortEntry(tag uint16, values ...uint16) tiffEntry {
+	data := make([]byte, 2*len(values))
+
+	for i, v := range values {
+		binary.LittleEndian.PutUint16(data[2*i:], v)
+	}
+
+	return tiffEntry{tag, tiffShort, uint32(len(values)), data}
+}
+
+func longEntry(tag uint16, value uint32) tiffEntry {
+	data := make([]byte, 4)
+	binary.LittleEndian.PutUint32(data, value)
+
+	return tiffEntry{tag, tiffLong, 1, data}
+}
+
+func doubleEntry(tag uint16, values ...float64) tiffEntry {
+	var b bytes.Buffer
+	binary.Write(&b, binary.LittleEndian, values)
+
+	return tiffEntry{tag, tiffDouble, uint32(len(values)), b.Bytes()}
+}
+
+func asciiEntry(tag uint16, value string) tiffEntry {
+	data := append([]byte(value), 0)
+
+	return tiffEntry{tag, tiffASCII, uint32(len(data)), data}
+}
+
+// WriteGeoTIFF writes the grid as a single band, uncompressed, float32
+// GeoTIFF in WGS84 geographic coordinates, with NoData set as the GDAL nodata
+// value.
+func WriteGeoTIFF(w io.Writer, g *Grid) error {
+	imageLen := uint32(4 * len(g.Values))
+
+	entries := []tiffEntry{
+		longEntry(256, uint32(g.Width)),  // ImageWidth
+		longEntry(257, uint32(g.Height)), // ImageLength
+		shortEntry(258, 32),              // BitsPerSample
+		shortEntry(259, 1),               // Compression, none
+		shortEntry(262, 1),               // PhotometricInterpretation, BlackIsZero
+		longEntry(273, 0),                // StripOffsets, set below
+		shortEntry(277, 1),               // SamplesPerPixel
+		longEntry(278, uint32(g.Height)), // RowsPerStrip
+		longEntry(279, imageLen),         // StripByteCounts
+		shortEntry(284, 1),               // PlanarConfiguration, chunky
+		shortEntry(339, tiffSampleFormatFloat),
+		doubleEntry(33550, g.Resolution, g.Resolution, 0),  // ModelPixelScale
+		doubleEntry(33922, 0, 0, 0, g.MinLon, g.MaxLat, 0), // ModelTiepoint
+		shortEntry(34735, // GeoKeyDirectory
+			1, 1, 0, 3,
+			geoKeyModelType, 0, 1, modelTypeGeographic,
+			geoKeyRasterType, 0, 1, rasterPixelIsArea,
+			geoKeyGeographicType, 0, 1, geographicTypeWGS84,
+		),
+		asciiEntry(42113, fmt.Sprintf("%d", NoData)), // GDAL_NODATA
+	}
+
+	sort.Slice(entries, func(i, j int) bool { return entries[i].tag < entries[j].tag })
+
+	ifdLen := uint32(2 + tiffIFDEntryLen*len(entries) + 4)
+
+	// values that don't fit in an entry follow the IFD, then the image
+	var extra bytes.Buffer
+	offsets := make([]uint32, len(entries))
+
+	for i, e := range entries {
+		if len(e.data) > 4 {
+			offsets[i] = tiffHeaderLen + ifdLen + uint32(extra.Len())
+			extra.Write(e.data)
+
+			if extra.Len()%2 != 0 {
+				extra.WriteByte(0)
+			}
+		}
+	}
+
+	imageOffset := tiffHeaderLen + ifdLen + uint32(extra.Len())
+
+	var b bytes.Buffer
+
+	b.WriteString("II")
+	binary.Write(&b, binary.LittleEndian, uint16(42))
+	binary.Write(&b, binary.LittleEndian, uint32(tiffHeaderLen))
+	binary.Write(&b, binary.LittleEndian, uint16(len(entries)))
+
+	for i, e := range entries {
+		if e.tag == 273 {
+			binary.LittleEndian.PutUint32(e.data, imageOffset)
+		}
+
+		binary.Write(&b, binary.LittleEndian, e.tag)
+		binary.Write(&b, binary.LittleEndian, e.kind)
+		binary.Write(&b, binary.LittleEndian, e.count)
+
+		if len(e.data) > 4 {
+			binary.Write(&b, binary.LittleEndian, offsets[i])
+		} else {
+			value := make([]byte, 4)
+			copy(value, e.data)
+			b.Write(value)
+		}
+	}
+
+	// no further IFDs
+	binary.Write(&b, binary.LittleEndian, uint32(0))
+
+	b.Write(extra.Bytes())
+
+	if _, err := w.Write(b.Bytes()); err != nil {
+		return err
+	}
+
+	return binary.Write(w, binary.LittleEndian, g.Values)
+}
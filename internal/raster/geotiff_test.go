@@ -0,0 +1,123 @@
+package raster
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+)
+
+func TestWriteGeoTIFF(t *testing.T) {
+	g := &Grid{
+		Width:      3,
+		Height:     2,
+		MinLon:     -105,
+		MaxLat:     40,
+		Resolution: 0.25,
+		Values:     []float32{1, 2, NoData, 4, 5, 6},
+	}
+
+	var b bytes.Buffer
+
+	if err := WriteGeoTIFF(&b, g); err != nil {
+		t.Fatal(err)
+	}
+
+	data := b.Bytes()
+	le := binary.LittleEndian
+
+	if string(data[:2]) != "II" || le.Uint16(data[2:]) != 42 {
+		t.Fatalf("expected a little endian TIFF header, got %q", data[:4])
+	}
+
+	ifd := le.Uint32(data[4:])
+	count := int(le.Uint16(data[ifd:]))
+
+	type entry struct {
+		kind  uint16
+		count uint32
+		value []byte
+	}
+
+	entries := make(map[uint16]entry, count)
+	previous := uint16(0)
+
+	for i := 0; i < count; i++ {
+		e := data[int(ifd)+2+i*tiffIFDEntryLen:]
+		tag := le.Uint16(e)
+
+		// TIFF requires entries sorted by tag
+		if tag <= previous {
+			t.Errorf("entry %d: tag %d follows tag %d", i, tag, previous)
+		}
+
+		previous = tag
+		entries[tag] = entry{le.Uint16(e[2:]), le.Uint32(e[4:]), e[8:12]}
+	}
+
+	if next := le.Uint32(data[int(ifd)+2+count*tiffIFDEntryLen:]); next != 0 {
+		t.Errorf("expected a single IFD, got the next at %d", next)
+	}
+
+	// values longer than 4 bytes are at the offset held in the entry
+	doubles := func(tag uint16) []float64 {
+		e, ok := entries[tag]
+
+		if !ok || e.kind != tiffDouble {
+			t.Fatalf("expected a double entry for tag %d", tag)
+		}
+
+		values := make([]float64, e.count)
+		offset := le.Uint32(e.value)
+
+		for i := range values {
+			values[i] = math.Float64frombits(le.Uint64(data[int(offset)+8*i:]))
+		}
+
+		return values
+	}
+
+	for tag, expected := range map[uint16][]float64{
+		33550: {0.25, 0.25, 0},        // ModelPixelScale
+		33922: {0, 0, 0, -105, 40, 0}, // ModelTiepoint, the north west corner
+	} {
+		values := doubles(tag)
+
+		if len(values) != len(expected) {
+			t.Fatalf("tag %d: expected %v, got %v", tag, expected, values)
+		}
+
+		for i := range expected {
+			if values[i] != expected[i] {
+				t.Errorf("tag %d: expected %v, got %v", tag, expected, values)
+				break
+			}
+		}
+	}
+
+	for tag, expected := range map[uint16]uint32{256: 3, 257: 2, 278: 2, 279: 24} {
+		if e := entries[tag]; e.kind != tiffLong || le.Uint32(e.value) != expected {
+			t.Errorf("tag %d: expected %d, got %d", tag, expected, le.Uint32(e.value))
+		}
+	}
+
+	nodata := entries[42113]
+	offset := le.Uint32(nodata.value)
+
+	if text := string(data[offset : offset+nodata.count]); text != "-9999\x00" {
+		t.Errorf("expected GDAL_NODATA -9999, got %q", text)
+	}
+
+	// the strip follows everything else and holds the values row major
+	strip := le.Uint32(entries[273].value)
+
+	if int(strip)+4*len(g.Values) != len(data) {
+		t.Fatalf("expected the strip at %d to end the file of %d bytes", strip, len(data))
+	}
+
+	for i, expected := range g.Values {
+		if v := math.Float32frombits(le.Uint32(data[int(strip)+4*i:])); v != expected {
+			t.Errorf("value %d: expected %v, got %v", i, expected, v)
+		}
+	}
+}
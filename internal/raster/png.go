@@ -0,0 +1,46 @@
+package raster
+
+import (
+	"fmt"
+	"image"
+	"image/png"
+	"io"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
+)
+
+// WritePNG renders the grid as a PNG, coloring each cell with the colormap.
+// Cells without data or below the colormap are transparent.
+func WritePNG(w io.Writer, g *Grid, cm *colormap.Colormap) error {
+	img := image.NewRGBA(image.Rect(0, 0, g.Width, g.Height))
+
+	for row := 0; row < g.Height; row++ {
+		for col := 0; col < g.Width; col++ {
+			value := g.Values[row*g.Width+col]
+
+			if value == NoData {
+				continue
+			}
+
+			if c, ok := cm.Color(value); ok {
+				img.SetRGBA(col, row, c)
+			}
+		}
+	}
+
+	return png.Encode(w, img)
+}
+
+// WriteWorldFile writes the world file georeferencing an image of the grid,
+// e.g. a .pgw alongside a PNG.
+func WriteWorldFile(w io.Writer, g *Grid) error {
+	// pixel size, rotation terms, then the center of the upper left pixel
+	_, err := fmt.Fprintf(w, "%.10f\n0\n0\n%.10f\n%.10f\n%.10f\n",
+		g.Resolution,
+		-g.Resolution,
+		g.MinLon+g.Resolution/2,
+		g.MaxLat-g.Resolution/2,
+	)
+
+	return err
+}
@@ -0,0 +1,67 @@
+package raster
+
+import (
+	"bytes"
+	"image/png"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/colormap"
+)
+
+func TestWritePNG(t *testing.T) {
+	// a value colored by the colormap, one below its first break, and a
+	// cell without data
+	g := &Grid{
+		Width:      3,
+		Height:     1,
+		MinLon:     -105,
+		MaxLat:     40,
+		Resolution: 0.01,
+		Values:     []float32{42.5, 2, NoData},
+	}
+
+	var b bytes.Buffer
+
+	if err := WritePNG(&b, g, colormap.Reflectivity); err != nil {
+		t.Fatal(err)
+	}
+
+	img, err := png.Decode(&b)
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if bounds := img.Bounds(); bounds.Dx() != 3 || bounds.Dy() != 1 {
+		t.Fatalf("expected a 3x1 image, got %v", bounds)
+	}
+
+	expected, _ := colormap.Reflectivity.Color(42.5)
+
+	if r, gr, bl, a := img.At(0, 0).RGBA(); r>>8 != uint32(expected.R) || gr>>8 != uint32(expected.G) || bl>>8 != uint32(expected.B) || a>>8 != uint32(expected.A) {
+		t.Errorf("expected %v, got %v", expected, img.At(0, 0))
+	}
+
+	for col := 1; col < 3; col++ {
+		if _, _, _, a := img.At(col, 0).RGBA(); a != 0 {
+			t.Errorf("col %d: expected a transparent pixel, got %v", col, img.At(col, 0))
+		}
+	}
+}
+
+func TestWriteWorldFile(t *testing.T) {
+	g := &Grid{Width: 3, Height: 2, MinLon: -105, MaxLat: 40, Resolution: 0.01}
+
+	var b bytes.Buffer
+
+	if err := WriteWorldFile(&b, g); err != nil {
+		t.Fatal(err)
+	}
+
+	// the center of the upper left pixel, half a cell in from the corner
+	expected := "0.0100000000\n0\n0\n-0.0100000000\n-104.9950000000\n39.9950000000\n"
+
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
@@ -0,0 +1,155 @@
+package raster
+
+import (
+	"math"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// NoData marks grid cells not covered by any bin.
+const NoData = -9999
+
+// Grid is a regular longitude/latitude grid of product values, stored row
+// major from the north west corner.
+type Grid struct {
+	Width  int
+	Height int
+	// MinLon is the longitude of the western edge of the grid
+	MinLon float64
+	// MaxLat is the latitude of the northern edge of the grid
+	MaxLat float64
+	// Resolution is the size of a cell in degrees
+	Resolution float64
+	Values     []float32
+}
+
+// Rasterize burns the value of each bin into every cell whose center lies
+// within the bin, keeping the largest value where bins overlap. Bins smaller
+// than a cell are burned into the cell containing their center.
+func Rasterize(bins []*geo.Bin, resolution float64) *Grid {
+	minLon, minLat := math.Inf(1), math.Inf(1)
+	maxLon, maxLat := math.Inf(-1), math.Inf(-1)
+
+	for _, bin := range bins {
+		for _, c := range bin.Coords {
+			minLon = math.Min(minLon, c.X())
+			minLat = math.Min(minLat, c.Y())
+			maxLon = math.Max(maxLon, c.X())
+			maxLat = math.Max(maxLat, c.Y())
+		}
+	}
+
+	if len(bins) == 0 {
+		minLon, minLat, maxLon, maxLat = 0, 0, 0, 0
+	}
+
+	g := &Grid{
+		Width:      int(math.Ceil((maxLon-minLon)/resolution)) + 1,
+		Height:     int(math.Ceil((maxLat-minLat)/resolution)) + 1,
+		MinLon:     minLon,
+		MaxLat:     maxLat,
+		Resolution: resolution,
+	}
+
+	g.Values = make([]float32, g.Width*g.Height)
+
+	for i := range g.Values {
+		g.Values[i] = NoData
+	}
+
+	for _, bin := range bins {
+		g.burn(bin)
+	}
+
+	return g
+}
+
+func (g *Grid) burn(bin *geo.Bin) {
+	ring := bin.Ring()
+
+	west, east := math.Inf(1), math.Inf(-1)
+	south, north := math.Inf(1), math.Inf(-1)
+	centerLon, centerLat := 0.0, 0.0
+
+	for _, c := range bin.Coords {
+		west = math.Min(west, c.X())
+		east = math.Max(east, c.X())
+		south = math.Min(south, c.Y())
+		north = math.Max(north, c.Y())
+		centerLon += c.X() / float64(len(bin.Coords))
+		centerLat += c.Y() / float64(len(bin.Coords))
+	}
+
+	burned := false
+
+	for row := g.row(north); row <= g.row(south); row++ {
+		for col := g.col(west); col <= g.col(east); col++ {
+			lon := g.MinLon + (float64(col)+0.5)*g.Resolution
+			lat := g.MaxLat - (float64(row)+0.5)*g.Resolution
+
+			if geo.RingContains(ring, lon, lat) {
+				g.set(row, col, bin.Value)
+				burned = true
+			}
+		}
+	}
+
+	if !burned {
+		g.set(g.row(centerLat), g.col(centerLon), bin.Value)
+	}
+}
+
+func (g *Grid) row(lat float64) int {
+	return int((g.MaxLat - lat) / g.Resolution)
+}
+
+func (g *Grid) col(lon float64) int {
+	return int((lon - g.MinLon) / g.Resolution)
+}
+
+func (g *Grid) set(row int, col int, value float32) {
+	if row < 0 || row >= g.Height || col < 0 || col >= g.Width {
+		return
+	}
+
+	i := row*g.Width + col
+
+	if g.Values[i] == NoData || value > g.Values[i] {
+		g.Values[i] = value
+	}
+}
+
+// Bins returns a square bin for each cell of the grid holding a value, e.g.
+// to write a composite of several elevations as vector features. The bins
+// carry only their value, as a cell has no single elevation, azimuth or
+// range.
+func (g *Grid) Bins() []*geo.Bin {
+	bins := make([]*geo.Bin, 0)
+
+	for row := 0; row < g.Height; row++ {
+		north := g.MaxLat - float64(row)*g.Resolution
+		south := north - g.Resolution
+
+		for col := 0; col < g.Width; col++ {
+			value := g.Values[row*g.Width+col]
+
+			if value == NoData {
+				continue
+			}
+
+			west := g.MinLon + float64(col)*g.Resolution
+			east := west + g.Resolution
+
+			bins = append(bins, geo.NewBin(
+				proj.NewCoord(west, south, 0, 0),
+				proj.NewCoord(east, south, 0, 0),
+				proj.NewCoord(west, north, 0, 0),
+				proj.NewCoord(east, north, 0, 0),
+				value,
+			))
+		}
+	}
+
+	return bins
+}
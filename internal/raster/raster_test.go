@@ -0,0 +1,104 @@
+package raster
+
+import (
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// square returns a bin spanning west to east and south to north.
+func square(west float64, south float64, east float64, north float64, value float32) *geo.Bin {
+	return geo.NewBin(
+		proj.NewCoord(west, south, 0, 0),
+		proj.NewCoord(east, south, 0, 0),
+		proj.NewCoord(west, north, 0, 0),
+		proj.NewCoord(east, north, 0, 0),
+		value,
+	)
+}
+
+func TestRasterize(t *testing.T) {
+	bins := []*geo.Bin{
+		// drawn first, so the larger value is kept rather than the last
+		square(1, 1, 3, 3, 20),
+		square(0, 0, 2, 2, 10),
+		// smaller than a cell, covering no cell center
+		square(2.6, 0.1, 2.8, 0.3, 5),
+	}
+
+	g := Rasterize(bins, 1)
+
+	if g.Width != 4 || g.Height != 4 {
+		t.Fatalf("expected a 4x4 grid, got %dx%d", g.Width, g.Height)
+	}
+
+	if g.MinLon != 0 || g.MaxLat != 3 || g.Resolution != 1 {
+		t.Errorf("expected the north west corner at 0, 3 with 1 degree cells, got %v, %v with %v", g.MinLon, g.MaxLat, g.Resolution)
+	}
+
+	expected := []float32{
+		NoData, 20, 20, NoData,
+		10, 20, 20, NoData,
+		10, 10, 5, NoData,
+		NoData, NoData, NoData, NoData,
+	}
+
+	for i := range expected {
+		if g.Values[i] != expected[i] {
+			t.Errorf("row %d col %d: expected %v, got %v", i/g.Width, i%g.Width, expected[i], g.Values[i])
+		}
+	}
+}
+
+func TestRasterizeEmpty(t *testing.T) {
+	g := Rasterize(nil, 0.5)
+
+	if g.Width != 1 || g.Height != 1 || g.Values[0] != NoData {
+		t.Errorf("expected a single cell without data, got %dx%d %v", g.Width, g.Height, g.Values)
+	}
+}
+
+func TestCompositeBins(t *testing.T) {
+	// the same cell in two elevations, and one cell only in the second, as
+	// --merge-elevations-to-max rasterizes them
+	lower := []*geo.Bin{square(0, 0, 1, 1, 10)}
+	upper := []*geo.Bin{square(0, 0, 1, 1, 30), square(1, 0, 2, 1, 15)}
+
+	bins := Rasterize(append(lower, upper...), 1).Bins()
+
+	if len(bins) != 2 {
+		t.Fatalf("expected a bin per cell with data, got %d", len(bins))
+	}
+
+	expected := []struct {
+		west  float64
+		value float32
+	}{
+		{0, 30},
+		{1, 15},
+	}
+
+	for i, e := range expected {
+		bin := bins[i]
+
+		if bin.Value != e.value {
+			t.Errorf("cell %d: expected the largest value %v, got %v", i, e.value, bin.Value)
+		}
+
+		ring := bin.Ring()
+
+		// counterclockwise from the south west corner
+		corners := [][2]float64{{e.west, 0}, {e.west + 1, 0}, {e.west + 1, 1}, {e.west, 1}}
+
+		if len(ring) != len(corners) {
+			t.Fatalf("cell %d: expected %d corners, got %d", i, len(corners), len(ring))
+		}
+
+		for j, c := range corners {
+			if ring[j].X() != c[0] || ring[j].Y() != c[1] {
+				t.Errorf("cell %d corner %d: expected %v, got %v, %v", i, j, c, ring[j].X(), ring[j].Y())
+			}
+		}
+	}
+}
@@ -0,0 +1,231 @@
+// Package shapefile writes bins as an Esri Shapefile, the .shp geometry, .shx
+// index, and .dbf attribute table.
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"strings"
+	"time"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+// Projection is the WKT of WGS84 geographic coordinates, written to the .prj
+// alongside the shapefile.
+const Projection = `GEOGCS["GCS_WGS_1984",DATUM["D_WGS_1984",SPHEROID["WGS_1984",6378137.0,298.257223563]],PRIMEM["Greenwich",0.0],UNIT["Degree",0.0174532925199433]]`
+
+const (
+	fileCode      = 9994
+	version       = 1000
+	shapePolygon  = 5
+	headerLen     = 100
+	dbfVersion    = 0x03
+	dbfHeaderLen  = 32
+	dbfFieldLen   = 32
+	dbfTerminator = 0x0D
+	dbfEOF        = 0x1A
+)
+
+// field is a column of the attribute table.
+type field struct {
+	Name     string
+	Type     byte
+	Length   int
+	Decimals int
+	Value    func(bin *geo.Bin) string
+}
+
+type bounds struct {
+	MinX, MinY, MaxX, MaxY float64
+}
+
+func newBounds() bounds {
+	return bounds{math.Inf(1), math.Inf(1), math.Inf(-1), math.Inf(-1)}
+}
+
+func (b *bounds) extend(c proj.Coord) {
+	b.MinX, b.MinY = math.Min(b.MinX, c.X()), math.Min(b.MinY, c.Y())
+	b.MaxX, b.MaxY = math.Max(b.MaxX, c.X()), math.Max(b.MaxY, c.Y())
+}
+
+// Write writes one polygon record per bin to shp and its index to shx, with
+// the value keyed by the lowercase product, the unit, and the elevation
+// number if props.Elevation is set as attributes in dbf.
+func Write(shp io.Writer, shx io.Writer, dbf io.Writer, bins []*geo.Bin, props *geo.FeatureProperties) error {
+	var records bytes.Buffer
+	var index bytes.Buffer
+
+	total := newBounds()
+
+	for i, bin := range bins {
+		content := polygonRecord(bin, &total)
+
+		// offsets and lengths are in 16 bit words
+		binary.Write(&index, binary.BigEndian, []int32{int32((headerLen + records.Len()) / 2), int32(len(content) / 2)})
+
+		binary.Write(&records, binary.BigEndian, []int32{int32(i + 1), int32(len(content) / 2)})
+		records.Write(content)
+	}
+
+	if len(bins) == 0 {
+		total = bounds{}
+	}
+
+	if _, err := shp.Write(header(headerLen+records.Len(), total)); err != nil {
+		return err
+	}
+
+	if _, err := shp.Write(records.Bytes()); err != nil {
+		return err
+	}
+
+	if _, err := shx.Write(header(headerLen+index.Len(), total)); err != nil {
+		return err
+	}
+
+	if _, err := shx.Write(index.Bytes()); err != nil {
+		return err
+	}
+
+	return writeTable(dbf, bins, fields(props))
+}
+
+// header returns the main file header shared by the .shp and .shx, for a file
+// of length bytes.
+func header(length int, b bounds) []byte {
+	var h bytes.Buffer
+
+	binary.Write(&h, binary.BigEndian, int32(fileCode))
+	h.Write(make([]byte, 20))
+	binary.Write(&h, binary.BigEndian, int32(length/2))
+	binary.Write(&h, binary.LittleEndian, []int32{version, shapePolygon})
+	binary.Write(&h, binary.LittleEndian, []float64{b.MinX, b.MinY, b.MaxX, b.MaxY, 0, 0, 0, 0})
+
+	return h.Bytes()
+}
+
+// polygonRecord returns the contents of a polygon record for the bin, with a
+// part for each side of the antimeridian if it crosses it. Shapefile exterior
+// rings are clockwise, so the counterclockwise rings are reversed.
+func polygonRecord(bin *geo.Bin, total *bounds) []byte {
+	polygons := bin.Polygons()
+
+	box := newBounds()
+	parts := make([]int32, 0, len(polygons))
+	points := make([]float64, 0)
+
+	for _, ring := range polygons {
+		parts = append(parts, int32(len(points)/2))
+
+		for i := len(ring); i >= 0; i-- {
+			// closed, starting and ending at the first corner
+			c := ring[i%len(ring)]
+			box.extend(c)
+			total.extend(c)
+			points = append(points, c.X(), c.Y())
+		}
+	}
+
+	var content bytes.Buffer
+
+	binary.Write(&content, binary.LittleEndian, int32(shapePolygon))
+	binary.Write(&content, binary.LittleEndian, []float64{box.MinX, box.MinY, box.MaxX, box.MaxY})
+	binary.Write(&content, binary.LittleEndian, []int32{int32(len(parts)), int32(len(points) / 2)})
+	binary.Write(&content, binary.LittleEndian, parts)
+	binary.Write(&content, binary.LittleEndian, points)
+
+	return content.Bytes()
+}
+
+func fields(props *geo.FeatureProperties) []field {
+	decimals := props.ValueDecimals()
+	unit := props.Unit()
+
+	columns := []field{
+		{
+			Name:     strings.ToLower(props.Product),
+			Type:     'N',
+			Length:   12,
+			Decimals: decimals,
+			Value:    func(bin *geo.Bin) string { return fmt.Sprintf("%.*f", decimals, bin.Value) },
+		},
+		{
+			Name:   "unit",
+			Type:   'C',
+			Length: 8,
+			Value:  func(bin *geo.Bin) string { return unit },
+		},
+	}
+
+	if props.Elevation {
+		columns = append(columns, field{
+			Name:   "elevation",
+			Type:   'N',
+			Length: 3,
+			Value:  func(bin *geo.Bin) string { return fmt.Sprint(bin.Elevation) },
+		})
+	}
+
+	return columns
+}
+
+// writeTable writes the dBASE III attribute table, one row per bin in record
+// order.
+func writeTable(w io.Writer, bins []*geo.Bin, columns []field) error {
+	recordLen := 1
+
+	for _, column := range columns {
+		recordLen += column.Length
+	}
+
+	var b bytes.Buffer
+
+	now := time.Now().UTC()
+
+	b.Write([]byte{dbfVersion, byte(now.Year() - 1900), byte(now.Month()), byte(now.Day())})
+	binary.Write(&b, binary.LittleEndian, int32(len(bins)))
+	binary.Write(&b, binary.LittleEndian, []int16{int16(dbfHeaderLen + dbfFieldLen*len(columns) + 1), int16(recordLen)})
+	b.Write(make([]byte, 20))
+
+	for _, column := range columns {
+		descriptor := make([]byte, dbfFieldLen)
+		copy(descriptor[:10], column.Name)
+		descriptor[11] = column.Type
+		descriptor[16] = byte(column.Length)
+		descriptor[17] = byte(column.Decimals)
+		b.Write(descriptor)
+	}
+
+	b.WriteByte(dbfTerminator)
+
+	for _, bin := range bins {
+		// not deleted
+		b.WriteByte(' ')
+
+		for _, column := range columns {
+			value := column.Value(bin)
+
+			if len(value) > column.Length {
+				value = value[:column.Length]
+			}
+
+			if column.Type == 'N' {
+				// numbers are right aligned, text left aligned
+				fmt.Fprintf(&b, "%*s", column.Length, value)
+			} else {
+				fmt.Fprintf(&b, "%-*s", column.Length, value)
+			}
+		}
+	}
+
+	b.WriteByte(dbfEOF)
+
+	_, err := w.Write(b.Bytes())
+
+	return err
+}
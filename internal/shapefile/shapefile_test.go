@@ -0,0 +1,51 @@
+package shapefile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/twpayne/go-proj/v10"
+)
+
+func TestWrite(t *testing.T) {
+	bins := []*geo.Bin{
+		geo.NewBin(proj.NewCoord(-105, 40, 0, 0), proj.NewCoord(-104.99, 40, 0, 0), proj.NewCoord(-105, 40.01, 0, 0), proj.NewCoord(-104.99, 40.01, 0, 0), 20),
+		geo.NewBin(proj.NewCoord(-104.99, 40, 0, 0), proj.NewCoord(-104.98, 40, 0, 0), proj.NewCoord(-104.99, 40.01, 0, 0), proj.NewCoord(-104.98, 40.01, 0, 0), 42.5),
+	}
+
+	var shp, shx, dbf bytes.Buffer
+
+	if err := Write(&shp, &shx, &dbf, bins, &geo.FeatureProperties{Product: "REF"}); err != nil {
+		t.Fatal(err)
+	}
+
+	// header, then per record an 8 byte header and a 128 byte polygon of one
+	// closed 5 point ring
+	if shp.Len() != 100+2*(8+128) {
+		t.Errorf("expected .shp of %d bytes, got %d", 100+2*(8+128), shp.Len())
+	}
+
+	if words := binary.BigEndian.Uint32(shp.Bytes()[24:]); int(words)*2 != shp.Len() {
+		t.Errorf("expected .shp header length %d words, got %d", shp.Len()/2, words)
+	}
+
+	if shx.Len() != 100+2*8 {
+		t.Errorf("expected .shx of %d bytes, got %d", 100+2*8, shx.Len())
+	}
+
+	// second record starts after the first
+	if offset := binary.BigEndian.Uint32(shx.Bytes()[108:]); offset != (100+8+128)/2 {
+		t.Errorf("expected second record at word %d, got %d", (100+8+128)/2, offset)
+	}
+
+	// header, 2 field descriptors and terminator, 2 records and end of file
+	if dbf.Len() != 32+2*32+1+2*(1+12+8)+1 {
+		t.Errorf("expected .dbf of %d bytes, got %d", 32+2*32+1+2*(1+12+8)+1, dbf.Len())
+	}
+
+	if !bytes.Contains(dbf.Bytes(), []byte("        42.5dBZ     ")) {
+		t.Errorf("expected the value and unit of the second bin in the .dbf")
+	}
+}
@@ -0,0 +1,340 @@
+// Package nexrad converts NEXRAD Level 2 (Archive II) data to GeoJSON.
+package nexrad
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geo"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+)
+
+// Options controls which product, values and elevations are converted.
+type Options = geo.RadarToJSONOptions
+
+// FeatureCollection is the GeoJSON of a product's bins, see
+// StreamArchiveProducts.
+type FeatureCollection = geojson.FeatureCollection
+
+// BoundingBox limits output to a geographic region, see Options.BoundingBox.
+type BoundingBox = geo.BoundingBox
+
+// Center is the origin of the plane coordinates are written on in meters, see
+// Options.Center.
+type Center = geo.Center
+
+// StormMotion is subtracted from velocities for SRV, see Options.StormMotion.
+type StormMotion = geo.StormMotion
+
+// Extract reads an archive 2 data file, panicking if it is corrupt.
+func Extract(f io.ReadSeeker) *archive2.Archive2 {
+	return archive2.Extract(f)
+}
+
+// Read reads an archive 2 data file, returning an error rather than
+// panicking if it is corrupt, or if the volume is empty or truncated.
+func Read(f io.ReadSeeker) (*archive2.Archive2, error) {
+	return archive2.Read(f)
+}
+
+// Convert georeferences a single elevation scan and returns it as a
+// FeatureCollection. opts.Elevations is ignored.
+func Convert(scan []*archive2.Message31, opts Options) (*geojson.FeatureCollection, error) {
+	return ConvertContext(context.Background(), scan, opts)
+}
+
+// ConvertContext is Convert, stopping between radials and returning
+// ctx.Err() once ctx is done, e.g. when the client of a server converting on
+// request disconnects.
+func ConvertContext(ctx context.Context, scan []*archive2.Message31, opts Options) (*geojson.FeatureCollection, error) {
+	if len(scan) == 0 {
+		return nil, errors.New("scan contains no radials")
+	}
+
+	opts = normalize(opts)
+
+	bins, err := geo.GeoreferenceScanContext(ctx, scan, &opts)
+
+	if err != nil {
+		return nil, err
+	}
+
+	return newCollection(scan, &opts, bins), nil
+}
+
+// ForEachBin georeferences a single elevation scan and calls fn with the
+// longitudes and latitudes of each bin's corners, counterclockwise, and its
+// value, as each batch of radials is georeferenced, without building a
+// FeatureCollection. opts.Elevations is ignored.
+func ForEachBin(scan []*archive2.Message31, opts Options, fn func(lon [4]float64, lat [4]float64, value float32)) error {
+	if len(scan) == 0 {
+		return errors.New("scan contains no radials")
+	}
+
+	opts = normalize(opts)
+
+	// a batch of radials at a time, so the scan's bins are never all held
+	return geo.StreamScan(scan, &opts, func(bins []*geo.Bin) error {
+		for _, bin := range bins {
+			var lon, lat [4]float64
+
+			for i, c := range bin.Ring() {
+				lon[i], lat[i] = c.X(), c.Y()
+			}
+
+			fn(lon, lat, bin.Value)
+		}
+
+		return nil
+	})
+}
+
+// ConvertArchive converts every elevation in opts.Elevations and returns a
+// FeatureCollection per elevation number.
+func ConvertArchive(ar2 *archive2.Archive2, opts Options) (map[int]*geojson.FeatureCollection, error) {
+	return ConvertArchiveContext(context.Background(), ar2, opts)
+}
+
+// ConvertArchiveContext is ConvertArchive, stopping the elevations being
+// georeferenced between radials and returning ctx.Err() once ctx is done.
+func ConvertArchiveContext(ctx context.Context, ar2 *archive2.Archive2, opts Options) (map[int]*geojson.FeatureCollection, error) {
+	products, err := ConvertArchiveProductsContext(ctx, ar2, []Options{opts})
+
+	if err != nil {
+		return nil, err
+	}
+
+	return products[strings.ToUpper(opts.Product)], nil
+}
+
+// ConvertArchiveProducts converts several products in a single pass over the
+// archive, returning a FeatureCollection per product, in upper case, and
+// elevation number. Each Options selects its own Product, Minimum, Maximum and Dealias; the
+// elevations and bin geometry are taken from the first, see
+// geo.RadarToProductBins.
+func ConvertArchiveProducts(ar2 *archive2.Archive2, opts []Options) (map[string]map[int]*geojson.FeatureCollection, error) {
+	return ConvertArchiveProductsContext(context.Background(), ar2, opts)
+}
+
+// ConvertArchiveProductsContext is ConvertArchiveProducts, stopping the
+// elevations being georeferenced between radials and returning ctx.Err() once
+// ctx is done.
+func ConvertArchiveProductsContext(ctx context.Context, ar2 *archive2.Archive2, opts []Options) (map[string]map[int]*geojson.FeatureCollection, error) {
+	if len(opts) == 0 {
+		return nil, errors.New("no products to convert")
+	}
+
+	if err := CheckElevations(ar2, opts[0].Elevations); err != nil {
+		return nil, err
+	}
+
+	options := make([]*geo.RadarToJSONOptions, len(opts))
+
+	for i := range opts {
+		o := normalize(opts[i])
+		options[i] = &o
+	}
+
+	scans, err := geo.RadarToProductBinsContext(ctx, ar2, options)
+
+	if err != nil {
+		return nil, err
+	}
+
+	products := make(map[string]map[int]*geojson.FeatureCollection, len(scans))
+
+	for _, o := range options {
+		elevations, ok := scans[o.Product]
+
+		if !ok {
+			continue
+		}
+
+		collections := make(map[int]*geojson.FeatureCollection, len(elevations))
+
+		volumeTime := ar2.VolumeTime()
+
+		for elevation, bins := range elevations {
+			collection := newCollection(ar2.ElevationScans[elevation], o, bins)
+
+			if collection.Metadata != nil {
+				collection.Metadata.VolumeTime = &volumeTime
+			}
+
+			collections[elevation] = collection
+		}
+
+		products[o.Product] = collections
+	}
+
+	return products, nil
+}
+
+// StreamArchiveProducts converts several products as ConvertArchiveProducts,
+// but an elevation at a time, calling fn with a FeatureCollection of each
+// product's bins for every geo.StreamRadials radials rather than returning
+// them, so only a batch's bins are held, e.g. to write GeoJSON text sequences
+// of large volumes. Each collection has the metadata and properties of its
+// elevation's collection from ConvertArchiveProducts, and those passed for
+// an elevation, in turn, hold its bins. MaxFeatures caps the bins passed for
+// each product and elevation, marking the batches it drops bins from
+// truncated. An error from fn
+// stops converting and is returned.
+func StreamArchiveProducts(ar2 *archive2.Archive2, opts []Options, fn func(elevation int, collection *FeatureCollection) error) error {
+	return StreamArchiveProductsContext(context.Background(), ar2, opts, fn)
+}
+
+// StreamArchiveProductsContext is StreamArchiveProducts, stopping between
+// radials and returning ctx.Err() once ctx is done.
+func StreamArchiveProductsContext(ctx context.Context, ar2 *archive2.Archive2, opts []Options, fn func(elevation int, collection *FeatureCollection) error) error {
+	if len(opts) == 0 {
+		return errors.New("no products to convert")
+	}
+
+	if err := CheckElevations(ar2, opts[0].Elevations); err != nil {
+		return err
+	}
+
+	options := make([]*geo.RadarToJSONOptions, len(opts))
+
+	for i := range opts {
+		o := normalize(opts[i])
+		options[i] = &o
+	}
+
+	volumeTime := ar2.VolumeTime()
+
+	// the collection each batch of the elevation being converted copies,
+	// and the bins passed, by product
+	current := 0
+	collections := make(map[string]*geojson.FeatureCollection, len(options))
+	passed := make(map[string]int, len(options))
+
+	return geo.StreamProductBinsContext(ctx, ar2, options, func(elevation int, products map[string][]*geo.Bin) error {
+		if elevation != current {
+			current = elevation
+			collections = make(map[string]*geojson.FeatureCollection, len(options))
+			passed = make(map[string]int, len(options))
+		}
+
+		for _, o := range options {
+			bins, ok := products[o.Product]
+
+			if !ok {
+				continue
+			}
+
+			collection, ok := collections[o.Product]
+
+			if !ok {
+				collection = newCollection(ar2.ElevationScans[elevation], o, nil)
+
+				if collection.Metadata != nil {
+					collection.Metadata.VolumeTime = &volumeTime
+				}
+
+				collections[o.Product] = collection
+			}
+
+			batch := *collection
+			batch.Bins = bins
+
+			if o.MaxFeatures > 0 {
+				batch.Truncate(o.MaxFeatures - passed[o.Product])
+			}
+
+			passed[o.Product] += len(batch.Bins)
+
+			if err := fn(elevation, &batch); err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// normalize returns the options with the product in upper case, as products
+// are named throughout, so "vel" converts as VEL. Products are matched
+// ignoring case only here, where options enter the library.
+func normalize(opts Options) Options {
+	opts.Product = strings.ToUpper(opts.Product)
+
+	return opts
+}
+
+// newCollection returns the FeatureCollection of a scan's bins with the
+// metadata of the scan, the scale and offset of raw data levels, and the
+// transform of transformed values, capped at opts.MaxFeatures.
+func newCollection(scan []*archive2.Message31, opts *Options, bins []*geo.Bin) *geojson.FeatureCollection {
+	collection := geojson.NewFeatureCollection(opts.Product, bins)
+	collection.Metadata = geojson.NewProductMetadata(scan, opts.Product)
+	collection.Properties.Raw = opts.Raw
+
+	if len(scan) > 0 {
+		collection.Properties.AntennaHeight = float64(scan[0].VolumeData.SiteHeight) + float64(scan[0].VolumeData.FeedhornHeight)
+	}
+
+	if opts.Raw && collection.Metadata != nil {
+		collection.Metadata.SetScale(scan, opts.Product)
+	}
+
+	if opts.ValueScale != nil || opts.ValueOffset != 0 {
+		collection.Properties.Transformed = true
+
+		if collection.Metadata != nil {
+			scale := float32(1)
+
+			if opts.ValueScale != nil {
+				scale = *opts.ValueScale
+			}
+
+			offset := opts.ValueOffset
+			collection.Metadata.ValueScale = &scale
+			collection.Metadata.ValueOffset = &offset
+		}
+	}
+
+	if opts.MaxFeatures > 0 {
+		collection.Truncate(opts.MaxFeatures)
+	}
+
+	return collection
+}
+
+// CheckElevations returns an error listing the available elevations if any
+// of the elevations is not present in the archive.
+func CheckElevations(ar2 *archive2.Archive2, elevations []int) error {
+	for _, elevation := range elevations {
+		if _, ok := ar2.ElevationScans[elevation]; !ok {
+			return fmt.Errorf("elevation %d not present, available elevations are %s", elevation, formatElevations(ar2.Elevations()))
+		}
+	}
+
+	return nil
+}
+
+// formatElevations formats sorted elevation numbers compactly, e.g. "1-5, 7".
+func formatElevations(elevations []int) string {
+	ranges := make([]string, 0)
+
+	for i := 0; i < len(elevations); i++ {
+		start := elevations[i]
+
+		for i+1 < len(elevations) && elevations[i+1] == elevations[i]+1 {
+			i++
+		}
+
+		if start == elevations[i] {
+			ranges = append(ranges, fmt.Sprintf("%d", start))
+		} else {
+			ranges = append(ranges, fmt.Sprintf("%d-%d", start, elevations[i]))
+		}
+	}
+
+	return strings.Join(ranges, ", ")
+}
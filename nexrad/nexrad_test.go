@@ -0,0 +1,343 @@
+package nexrad
+
+import (
+	"context"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/jtleniger/go-nexrad-geojson/internal/archive2"
+	"github.com/jtleniger/go-nexrad-geojson/internal/geojson"
+)
+
+// velocityScan returns the fixture's scan with every gate of each radial 10
+// m/s outbound, a Nyquist velocity of 26.5 m/s and an unambiguous range of
+// 466 km.
+func velocityScan(t *testing.T) []*archive2.Message31 {
+	f, err := os.Open("../internal/archive2/testdata/fixture.ar2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	scan := Extract(f).ElevationScans[1]
+
+	for _, radial := range scan {
+		velocity := *radial.ReflectivityData
+		velocity.Scale, velocity.Offset = 2, 129
+		velocity.Data = make([]byte, len(velocity.Data))
+
+		for i := range velocity.Data {
+			velocity.Data[i] = 149
+		}
+
+		radial.VelocityData = &velocity
+		radial.RadialData.NyquistVelocity = 2650
+		radial.RadialData.UnambiguousRange = 4660
+	}
+
+	return scan
+}
+
+func TestForEachBin(t *testing.T) {
+	f, err := os.Open("../internal/archive2/testdata/fixture.ar2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ar2 := Extract(f)
+
+	values := make([]float32, 0)
+
+	err = ForEachBin(ar2.ElevationScans[1], Options{Product: "REF"}, func(lon, lat [4]float64, value float32) {
+		values = append(values, value)
+
+		for i := range lon {
+			// every corner is within a few km of the radar
+			if math.Abs(lon[i]-(-104.5458)) > 0.1 || math.Abs(lat[i]-39.7866) > 0.1 {
+				t.Errorf("corner %d at %v, %v is far from the radar", i, lon[i], lat[i])
+			}
+		}
+	})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// 4 radials of 4 gates above threshold
+	if len(values) != 16 {
+		t.Errorf("expected 16 bins, got %d", len(values))
+	}
+
+	if err := ForEachBin(nil, Options{Product: "REF"}, func(lon, lat [4]float64, value float32) {}); err == nil {
+		t.Error("expected an error for an empty scan")
+	}
+}
+
+func TestConvertArchiveContextCanceled(t *testing.T) {
+	f, err := os.Open("../internal/archive2/testdata/fixture.ar2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ar2 := Extract(f)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if _, err := ConvertArchiveContext(ctx, ar2, Options{Product: "REF", Elevations: []int{1}}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	if _, err := ConvertContext(ctx, ar2.ElevationScans[1], Options{Product: "REF"}); err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+
+	collections, err := ConvertArchiveContext(context.Background(), ar2, Options{Product: "REF", Elevations: []int{1}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(collections[1].Bins) != 16 {
+		t.Errorf("expected 16 bins, got %d", len(collections[1].Bins))
+	}
+}
+
+func TestStreamArchiveProducts(t *testing.T) {
+	f, err := os.Open("../internal/archive2/testdata/fixture.ar2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ar2 := Extract(f)
+
+	for _, maxFeatures := range []int{0, 10} {
+		bins := 0
+
+		err := StreamArchiveProducts(ar2, []Options{{Product: "ref", Elevations: []int{1}, MaxFeatures: maxFeatures}}, func(elevation int, collection *geojson.FeatureCollection) error {
+			if elevation != 1 || collection.Properties.Product != "REF" {
+				t.Errorf("expected REF of elevation 1, got %v of %d", collection.Properties.Product, elevation)
+			}
+
+			if collection.Metadata == nil || collection.Metadata.Station != "KFTG" || collection.Metadata.VolumeTime == nil {
+				t.Errorf("expected the metadata of the elevation, got %+v", collection.Metadata)
+			}
+
+			bins += len(collection.Bins)
+
+			return nil
+		})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		// 4 radials of 4 gates above threshold
+		expected := 16
+
+		if maxFeatures > 0 {
+			expected = maxFeatures
+		}
+
+		if bins != expected {
+			t.Errorf("max features %d: expected %d bins, got %d", maxFeatures, expected, bins)
+		}
+	}
+
+	if err := StreamArchiveProducts(ar2, []Options{{Product: "REF", Elevations: []int{9}}}, func(int, *geojson.FeatureCollection) error { return nil }); err == nil {
+		t.Error("expected an error for a missing elevation")
+	}
+}
+
+func TestConvertMaxFeatures(t *testing.T) {
+	f, err := os.Open("../internal/archive2/testdata/fixture.ar2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ar2 := Extract(f)
+
+	// the fixture's elevation has 16 bins, truncated only below them
+	tests := []struct {
+		maxFeatures int
+		bins        int
+		truncated   bool
+	}{
+		{10, 10, true},
+		{15, 15, true},
+		{16, 16, false},
+		{100, 16, false},
+	}
+
+	for _, test := range tests {
+		collections, err := ConvertArchive(ar2, Options{Product: "REF", Elevations: []int{1}, MaxFeatures: test.maxFeatures})
+
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		collection := collections[1]
+
+		if len(collection.Bins) != test.bins || collection.Metadata.Truncated != test.truncated {
+			t.Errorf("max features %d: expected %d bins, truncated %v, got %d, %v", test.maxFeatures, test.bins, test.truncated, len(collection.Bins), collection.Metadata.Truncated)
+		}
+	}
+}
+
+func TestConvertProductCase(t *testing.T) {
+	scan := velocityScan(t)
+
+	upper, err := Convert(scan, Options{Product: "VEL"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lower, err := Convert(scan, Options{Product: "vel"})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if lower.Properties.Product != "VEL" || lower.Properties.Unit() != upper.Properties.Unit() || lower.Properties.Unit() == "" {
+		t.Errorf("expected product VEL in %q, got %v in %q", upper.Properties.Unit(), lower.Properties.Product, lower.Properties.Unit())
+	}
+
+	m := lower.Metadata
+
+	if m == nil || m.VelocityConvention != upper.Metadata.VelocityConvention || m.NyquistVelocity == nil || *m.NyquistVelocity != 26.5 || m.UnambiguousRange == nil || *m.UnambiguousRange != 466 {
+		t.Errorf("expected the velocity metadata of VEL, got %+v", m)
+	}
+
+	if len(lower.Bins) != len(upper.Bins) {
+		t.Errorf("expected %d bins, got %d", len(upper.Bins), len(lower.Bins))
+	}
+
+	collections, err := ConvertArchiveProducts(&archive2.Archive2{ElevationScans: map[int][]*archive2.Message31{1: scan}}, []Options{{Product: "vel", Elevations: []int{1}}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if collections["VEL"][1] == nil {
+		t.Errorf("expected collections keyed by VEL, got %v", collections)
+	}
+
+	// the storm motion is subtracted from lower case srv
+	srv, err := Convert(scan, Options{Product: "srv", StormMotion: &StormMotion{Direction: 0, Speed: 10}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// a storm from the north is inbound along the radial toward 0 degrees
+	// and outbound along the one toward 180
+	expected := map[float32]float32{0: 20, 90: 10, 180: 0, 270: 10}
+
+	if len(srv.Bins) == 0 {
+		t.Fatal("expected SRV bins")
+	}
+
+	for _, bin := range srv.Bins {
+		if math.Abs(float64(bin.Value-expected[bin.Azimuth])) > 1e-3 {
+			t.Errorf("azimuth %v: expected %v, got %v", bin.Azimuth, expected[bin.Azimuth], bin.Value)
+			break
+		}
+	}
+
+	// derived products have no raw levels, whatever the case
+	for _, radial := range scan {
+		radial.PhiData = radial.ReflectivityData
+	}
+
+	if _, err := Convert(scan, Options{Product: "kdp", Raw: true}); err == nil || !strings.Contains(err.Error(), "derived") {
+		t.Errorf("expected an error for raw kdp, which is derived, got %v", err)
+	}
+}
+
+func TestConvertSRV(t *testing.T) {
+	ar2 := &archive2.Archive2{ElevationScans: map[int][]*archive2.Message31{1: velocityScan(t)}}
+
+	collections, err := ConvertArchive(ar2, Options{Product: "SRV", Elevations: []int{1}, StormMotion: &StormMotion{Direction: 180, Speed: 5}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collection := collections[1]
+
+	if collection == nil || len(collection.Bins) == 0 {
+		t.Fatalf("expected SRV bins of elevation 1, got %v", collections)
+	}
+
+	if collection.Properties.Unit() != "m/s" {
+		t.Errorf("expected m/s, got %q", collection.Properties.Unit())
+	}
+
+	// a storm from the south is outbound along the radial toward 0 degrees
+	// and inbound along the one toward 180, of the 10 m/s outbound gates
+	expected := map[float32]float32{0: 5, 90: 10, 180: 15, 270: 10}
+
+	for _, bin := range collection.Bins {
+		if math.Abs(float64(bin.Value-expected[bin.Azimuth])) > 1e-3 {
+			t.Errorf("azimuth %v: expected %v, got %v", bin.Azimuth, expected[bin.Azimuth], bin.Value)
+		}
+	}
+
+	if _, err := ConvertArchive(ar2, Options{Product: "SRV", Elevations: []int{1}}); err == nil {
+		t.Error("expected an error for SRV without a storm motion")
+	}
+}
+
+func TestConvertCenter(t *testing.T) {
+	f, err := os.Open("../internal/archive2/testdata/fixture.ar2")
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer f.Close()
+
+	ar2 := Extract(f)
+
+	// centered on the radar, the bins are within a few km of the origin
+	collection, err := Convert(ar2.ElevationScans[1], Options{Product: "REF", Center: &Center{Lat: 39.7866, Lon: -104.5458}})
+
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(collection.Bins) != 16 {
+		t.Fatalf("expected 16 bins, got %d", len(collection.Bins))
+	}
+
+	farthest := 0.0
+
+	for _, bin := range collection.Bins {
+		for _, c := range bin.Ring() {
+			farthest = math.Max(farthest, math.Hypot(c.X(), c.Y()))
+		}
+	}
+
+	// the first gate starts 2 km out, rather than at degrees of longitude
+	// and latitude
+	if farthest < 2000 || farthest > 5000 {
+		t.Errorf("expected meters within a few km of the radar, got a corner %v from it", farthest)
+	}
+}